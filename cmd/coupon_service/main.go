@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"log"
 	"os"
 	"os/signal"
@@ -9,9 +10,18 @@ import (
 	"time"
 
 	"coupon_service/internal/api"
+	"coupon_service/internal/api/auth"
+	"coupon_service/internal/bruteforce"
 	"coupon_service/internal/config"
+	"coupon_service/internal/entity"
 	"coupon_service/internal/repository/memdb"
+	"coupon_service/internal/revocation"
 	"coupon_service/internal/service"
+	"coupon_service/internal/signedcode"
+	"coupon_service/internal/tlsconfig"
+	"coupon_service/pkg"
+
+	"github.com/redis/go-redis/v9"
 )
 
 func main() {
@@ -19,9 +29,57 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	rounding, err := entity.ParseRoundingMode(cfg.Env.Rounding)
+	if err != nil {
+		log.Fatal(err)
+	}
 	repo := memdb.NewRepository()
-	svc := service.New(repo)
-	app := api.New(cfg, svc)
+	migrated, err := repo.MigrateCodes()
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Normalized %d stored coupon codes", migrated)
+	opts := []service.Option{
+		service.WithRounding(rounding),
+		service.WithCodePolicy(codePolicy(cfg)),
+		service.WithAPIKeys(memdb.NewAPIKeys()),
+	}
+	key := auditKey(cfg)
+	opts = append(opts, service.WithAuditLog(memdb.NewAuditLog(key), key))
+	if len(cfg.Env.SignedCodes.Keys) > 0 {
+		keys, err := signedcode.ParseKeys(cfg.Env.SignedCodes.Keys)
+		if err != nil {
+			log.Fatal(err)
+		}
+		codec, err := signedcode.New(keys, cfg.Env.SignedCodes.ActiveKey)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, service.WithSignedCodes(codec, memdb.NewLedger()))
+		log.Printf("Signed codes enabled with %d keys, signing with key %d", len(keys), cfg.Env.SignedCodes.ActiveKey)
+	}
+	revocations := revocationList(cfg)
+	opts = append(opts, service.WithRevocations(revocations, cfg.Env.Revocation.MaxLifetime))
+	svc := service.New(repo, opts...)
+	apiOpts := []api.Option{api.WithTokenVerifier(tokenVerifier(cfg)), api.WithRevocations(revocations)}
+	if path := cfg.Env.AuthConfig.PolicyFile; path != "" {
+		policy, err := auth.LoadAccessPolicy(path)
+		if err != nil {
+			log.Fatal(err)
+		}
+		apiOpts = append(apiOpts, api.WithAccessPolicy(policy))
+		log.Printf("Access policy loaded from %s", path)
+	}
+	switch cfg.Env.BruteForce.Store {
+	case "memory":
+	case "redis":
+		client := redisClient(cfg, "brute force store")
+		apiOpts = append(apiOpts, api.WithBruteForceStore(bruteforce.NewRedisStore(client, "coupon_service:bruteforce:")))
+	default:
+		log.Fatalf("unknown brute force store %q, expected memory or redis", cfg.Env.BruteForce.Store)
+	}
+	apiOpts = append(apiOpts, tlsOptions(cfg)...)
+	app := api.New(cfg, svc, apiOpts...)
 
 	appErr := make(chan error, 1)
 	go func() {
@@ -50,3 +108,133 @@ func main() {
 	log.Println("Shutting down server...")
 	app.Shutdown()
 }
+
+// auditKey returns the key of the audit chain hashes, or a random one when none is configured, in which case
+// the chains cannot be verified after a restart.
+func auditKey(cfg config.Config) []byte {
+	if cfg.Env.AuditKey == "" {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			log.Fatal(err)
+		}
+		log.Println("AUDIT_HMAC_KEY is not set, the audit log is chained with a random key until the next restart")
+		return key
+	}
+	key, err := entity.ParseAuditKey(cfg.Env.AuditKey)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return key
+}
+
+// redisClient returns a client of the Redis server of REDIS_ADDR, required by the user.
+func redisClient(cfg config.Config, user string) *redis.Client {
+	if cfg.Env.Redis.Addr == "" {
+		log.Fatalf("REDIS_ADDR is required by the redis %s", user)
+	}
+	return redis.NewClient(&redis.Options{
+		Addr:     cfg.Env.Redis.Addr,
+		Password: cfg.Env.Redis.Password,
+		DB:       cfg.Env.Redis.DB,
+	})
+}
+
+// revocationList returns the list of the revoked bearer tokens, kept in the configured store.
+func revocationList(cfg config.Config) *revocation.List {
+	c := cfg.Env.Revocation
+	var store revocation.Store
+	switch c.Store {
+	case "memory":
+		store = revocation.NewMemoryStore()
+	case "redis":
+		store = revocation.NewRedisStore(redisClient(cfg, "revocation store"), "coupon_service:revocation:")
+	default:
+		log.Fatalf("unknown revocation store %q, expected memory or redis", c.Store)
+	}
+	return revocation.NewList(store, c.CacheTTL)
+}
+
+// tlsOptions returns the options serving the API over TLS, and authenticating the callers with client certificates,
+// when configured.
+func tlsOptions(cfg config.Config) []api.Option {
+	c := cfg.Env.TLS
+	if c.CertFile == "" {
+		return nil
+	}
+	tlsConfig, err := tlsconfig.New(tlsconfig.Options{
+		CertFile:     c.CertFile,
+		KeyFile:      c.KeyFile,
+		MinVersion:   c.MinVersion,
+		CipherPolicy: c.CipherPolicy,
+		ClientCAFile: c.ClientCAFile,
+		ClientAuth:   c.ClientAuth,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("TLS enabled with the certificate of %s, TLS %s minimum", c.CertFile, c.MinVersion)
+	opts := []api.Option{api.WithTLS(tlsConfig)}
+	if c.ClientCAFile != "" {
+		log.Printf("Verifying client certificates issued by %s (%s)", c.ClientCAFile, c.ClientAuth)
+	}
+	if c.SubjectsFile != "" {
+		subjects, err := auth.LoadCertificateSubjects(c.SubjectsFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, api.WithClientCertificates(subjects))
+		log.Printf("Client certificates of %d subjects accepted", len(subjects))
+	}
+	return opts
+}
+
+// codePolicy builds the coupon code policy from the configuration, falling back to the
+// default profanity list when none is configured.
+func codePolicy(cfg config.Config) pkg.CodePolicy {
+	c := cfg.Env.CodePolicy
+	policy := pkg.CodePolicy{
+		MinLength:       c.MinLength,
+		MaxLength:       c.MaxLength,
+		ASCIIOnly:       c.ASCIIOnly,
+		Reserved:        c.Reserved,
+		BlockedWords:    c.BlockedWords,
+		Profanity:       c.Profanity,
+		GeneratedLength: c.GeneratedLength,
+		CheckCharacter:  c.CheckCharacter,
+	}
+	if len(policy.Profanity) == 0 {
+		policy.Profanity = pkg.DefaultProfanity
+	}
+	return policy
+}
+
+// tokenVerifier returns the verifier of the bearer tokens: the introspection endpoint of the authorization server
+// for opaque tokens, or the JWT secret and the keys of the JWKS, when one is configured.
+func tokenVerifier(cfg config.Config) auth.TokenAuthenticator {
+	c := cfg.Env.AuthConfig
+	policy := auth.Policy{
+		Algorithms: c.Algorithms,
+		Issuers:    c.Issuers,
+		Audiences:  c.Audiences,
+		Leeway:     c.Leeway,
+	}
+	if c.TokenMode == "introspection" {
+		log.Printf("Introspecting tokens with %s", c.IntrospectionURL)
+		return auth.NewIntrospector(c.IntrospectionURL, c.IntrospectionClientID, c.IntrospectionClientSecret, policy)
+	}
+
+	var keySet *auth.KeySet
+	if c.JWKSURL != "" {
+		var err error
+		keySet, err = auth.NewKeySet(c.JWKSURL, c.JWKSRefresh)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Verifying tokens with the JWKS of %s", c.JWKSURL)
+	}
+	verifier, err := auth.NewVerifier([]byte(c.JWTSecret), keySet, policy)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return verifier
+}
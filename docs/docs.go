@@ -15,6 +15,286 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/apikeys": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns every API key, oldest first, without their secrets.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "apikeys"
+                ],
+                "summary": "List the API keys",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/internal_api.APIKeyResponse"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Creates an API key for a machine to machine caller, granted the permissions of its scopes, which must be granted to the caller as well. The key is only returned in this response, only its hash being stored.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "apikeys"
+                ],
+                "summary": "Create an API key",
+                "parameters": [
+                    {
+                        "description": "Name, scopes and optional expiry of the key",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.CreateAPIKeyRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.APIKeyResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/apikeys/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Deletes the API key, which is rejected from then on.",
+                "tags": [
+                    "apikeys"
+                ],
+                "summary": "Revoke an API key",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "ID of the API key",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/audit": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Returns the recorded administrative changes, oldest first, optionally filtered by coupon code, actor and time range.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "audit"
+                ],
+                "summary": "Query the audit log",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Code of the changed coupon",
+                        "name": "code",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "ID of the user who made the change",
+                        "name": "actor",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Start of the time range, RFC 3339, inclusive",
+                        "name": "from",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "End of the time range, RFC 3339, inclusive",
+                        "name": "to",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/internal_api.AuditEventResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/audit/verify": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Walks the hash chain of the audit events of the tenant of the caller, oldest event first, and reports the first event whose hash does not match its content or whose link to the previous event is broken, or the last remaining event when the last events of the chain were removed.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "audit"
+                ],
+                "summary": "Verify the audit log",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.AuditVerificationResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    }
+                }
+            }
+        },
         "/coupon": {
             "post": {
                 "security": [
@@ -22,10 +302,13 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Creates a new coupon with the specified code, discount, and minimum basket value",
+                "description": "Creates a new coupon with the specified or a generated code, effect and, per supported currency, discount and minimum basket value",
                 "consumes": [
                     "application/json"
                 ],
+                "produces": [
+                    "application/json"
+                ],
                 "tags": [
                     "coupons"
                 ],
@@ -39,11 +322,20 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/internal_api.CreateCouponRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key identifying retries of the request, whose first response is replayed",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "201": {
-                        "description": "Created"
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.CouponResponse"
+                        }
                     },
                     "400": {
                         "description": "Bad Request",
@@ -62,18 +354,24 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/pkg.Error"
                         }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
                     }
                 }
             }
         },
-        "/coupon/validation": {
+        "/coupon/signed": {
             "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Applies a coupon code to a given basket value and returns the result or error",
+                "description": "Issues fixed discount codes signed with the active key, which encode their campaign, discount, expiry and serial and need no stored coupon. Each code can be applied once.",
                 "consumes": [
                     "application/json"
                 ],
@@ -83,23 +381,29 @@ const docTemplate = `{
                 "tags": [
                     "coupons"
                 ],
-                "summary": "Apply a coupon to a basket",
+                "summary": "Issue signed coupon codes",
                 "parameters": [
                     {
-                        "description": "Coupon code and basket value",
+                        "description": "Campaign, discount, expiry and serials of the codes",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/internal_api.ApplyCouponRequest"
+                            "$ref": "#/definitions/internal_api.IssueSignedCodesRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key identifying retries of the request, whose first response is replayed",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "OK",
+                    "201": {
+                        "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/internal_api.ApplyCouponResponse"
+                            "$ref": "#/definitions/internal_api.IssueSignedCodesResponse"
                         }
                     },
                     "400": {
@@ -114,8 +418,14 @@ const docTemplate = `{
                     "403": {
                         "description": "Forbidden"
                     },
-                    "404": {
-                        "description": "Not Found",
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
                         "schema": {
                             "$ref": "#/definitions/pkg.Error"
                         }
@@ -123,14 +433,14 @@ const docTemplate = `{
                 }
             }
         },
-        "/coupons": {
-            "get": {
+        "/coupon/validation": {
+            "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Retrieves coupon details for the provided list of coupons if they are all existent",
+                "description": "Applies a coupon code to a basket and returns the discount applied to the basket and to each of its lines, or an error",
                 "consumes": [
                     "application/json"
                 ],
@@ -140,26 +450,29 @@ const docTemplate = `{
                 "tags": [
                     "coupons"
                 ],
-                "summary": "Get coupons by codes",
+                "summary": "Apply a coupon to a basket",
                 "parameters": [
                     {
-                        "description": "List of coupon codes",
+                        "description": "Coupon code and basket value",
                         "name": "request",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/internal_api.GetCouponsRequest"
+                            "$ref": "#/definitions/internal_api.ApplyCouponRequest"
                         }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key identifying retries of the request, whose first response is replayed",
+                        "name": "Idempotency-Key",
+                        "in": "header"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/internal_api.CouponResponse"
-                            }
+                            "$ref": "#/definitions/internal_api.ApplyCouponResponse"
                         }
                     },
                     "400": {
@@ -179,20 +492,281 @@ const docTemplate = `{
                         "schema": {
                             "$ref": "#/definitions/pkg.Error"
                         }
-                    }
-                }
-            }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/coupons": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Retrieves coupon details for the provided list of coupons if they are all existent, keeping only the coupons with all the given labels and created by the given user, if any",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "coupons"
+                ],
+                "summary": "Get coupons by codes",
+                "parameters": [
+                    {
+                        "description": "List of coupon codes",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.GetCouponsRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/internal_api.CouponResponse"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/revocations/subjects": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Revokes every bearer token of the subject in the tenant of the caller issued before the given time, now by default, such as all the tokens of a user whose credentials leaked. Tokens without an issue time (iat) are revoked as well.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "revocations"
+                ],
+                "summary": "Revoke the bearer tokens of a subject",
+                "parameters": [
+                    {
+                        "description": "Subject and optional time before which its tokens were issued",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.RevokeSubjectTokensRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key identifying retries of the request, whose first response is replayed",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.TokenRevocationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    }
+                }
+            }
+        },
+        "/revocations/tokens": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Revokes the bearer token of the tenant of the caller with the given ID, which is rejected from then on until its expiry. Revocations are picked up by the other instances of the service within REVOCATION_CACHE_TTL.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "revocations"
+                ],
+                "summary": "Revoke a bearer token",
+                "parameters": [
+                    {
+                        "description": "ID and optional expiry of the token",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.RevokeTokenRequest"
+                        }
+                    },
+                    {
+                        "type": "string",
+                        "description": "Key identifying retries of the request, whose first response is replayed",
+                        "name": "Idempotency-Key",
+                        "in": "header"
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/internal_api.TokenRevocationResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized"
+                    },
+                    "403": {
+                        "description": "Forbidden"
+                    },
+                    "429": {
+                        "description": "Too Many Requests",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    },
+                    "501": {
+                        "description": "Not Implemented",
+                        "schema": {
+                            "$ref": "#/definitions/pkg.Error"
+                        }
+                    }
+                }
+            }
         }
     },
     "definitions": {
+        "internal_api.APIKeyResponse": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string"
+                },
+                "created_by": {
+                    "type": "string"
+                },
+                "expires_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "key": {
+                    "description": "Key is the secret to present in the X-API-Key header, only returned when the key is created.",
+                    "type": "string"
+                },
+                "last_used_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "scopes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
         "internal_api.ApplyCouponRequest": {
             "type": "object",
             "properties": {
                 "code": {
                     "type": "string"
                 },
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_api.BasketLine"
+                    }
+                },
                 "value": {
-                    "type": "integer"
+                    "$ref": "#/definitions/internal_api.Money"
                 }
             }
         },
@@ -203,45 +777,314 @@ const docTemplate = `{
                     "type": "boolean"
                 },
                 "applied_discount": {
-                    "type": "integer"
+                    "$ref": "#/definitions/internal_api.Money"
+                },
+                "lines": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_api.BasketLineResponse"
+                    }
                 },
                 "value": {
+                    "$ref": "#/definitions/internal_api.Money"
+                }
+            }
+        },
+        "internal_api.AuditEventResponse": {
+            "type": "object",
+            "properties": {
+                "action": {
+                    "type": "string",
+                    "enum": [
+                        "coupon.created",
+                        "signed_codes.issued",
+                        "api_key.created",
+                        "api_key.revoked",
+                        "token.revoked",
+                        "subject_tokens.revoked"
+                    ]
+                },
+                "actor": {
+                    "type": "string"
+                },
+                "after": {
+                    "type": "object"
+                },
+                "before": {
+                    "type": "object"
+                },
+                "coupon_code": {
+                    "type": "string"
+                },
+                "hash": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "prev_hash": {
+                    "type": "string"
+                },
+                "request_id": {
+                    "type": "string"
+                },
+                "time": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_api.AuditVerificationResponse": {
+            "type": "object",
+            "properties": {
+                "broken_event_id": {
+                    "type": "string"
+                },
+                "checked": {
+                    "type": "integer"
+                },
+                "reason": {
+                    "type": "string"
+                },
+                "valid": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "internal_api.BasketLine": {
+            "type": "object",
+            "properties": {
+                "quantity": {
+                    "type": "integer"
+                },
+                "sku": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string",
+                    "enum": [
+                        "product",
+                        "shipping"
+                    ]
+                },
+                "unit_price": {
+                    "$ref": "#/definitions/internal_api.Money"
+                }
+            }
+        },
+        "internal_api.BasketLineResponse": {
+            "type": "object",
+            "properties": {
+                "applied_discount": {
+                    "$ref": "#/definitions/internal_api.Money"
+                },
+                "effect": {
+                    "type": "string"
+                },
+                "quantity": {
                     "type": "integer"
+                },
+                "sku": {
+                    "type": "string"
+                },
+                "type": {
+                    "type": "string"
+                },
+                "unit_price": {
+                    "$ref": "#/definitions/internal_api.Money"
+                }
+            }
+        },
+        "internal_api.BuyXGetY": {
+            "type": "object",
+            "properties": {
+                "buy": {
+                    "type": "integer"
+                },
+                "discount_percent": {
+                    "type": "integer"
+                },
+                "get": {
+                    "type": "integer"
+                },
+                "skus": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
                 }
             }
         },
         "internal_api.CouponResponse": {
             "type": "object",
             "properties": {
+                "buy_x_get_y": {
+                    "$ref": "#/definitions/internal_api.BuyXGetY"
+                },
                 "code": {
                     "type": "string"
                 },
-                "discount": {
-                    "type": "integer"
+                "created_at": {
+                    "type": "string"
+                },
+                "created_by": {
+                    "type": "string"
+                },
+                "description": {
+                    "type": "string"
+                },
+                "effect": {
+                    "type": "string"
                 },
                 "id": {
                     "type": "string"
                 },
+                "labels": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "terms": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_api.CouponTerms"
+                    }
+                },
+                "updated_at": {
+                    "type": "string"
+                },
+                "updated_by": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_api.CouponTerms": {
+            "type": "object",
+            "properties": {
+                "discount": {
+                    "$ref": "#/definitions/internal_api.Money"
+                },
                 "minimum_basket_value": {
-                    "type": "integer"
+                    "$ref": "#/definitions/internal_api.Money"
+                },
+                "tiers": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_api.Tier"
+                    }
+                }
+            }
+        },
+        "internal_api.CreateAPIKeyRequest": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string",
+                    "example": "checkout backend"
+                },
+                "scopes": {
+                    "description": "Scopes are the permissions granted to the key.",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "coupons:apply",
+                        "coupons:read"
+                    ]
                 }
             }
         },
         "internal_api.CreateCouponRequest": {
             "type": "object",
             "properties": {
+                "buy_x_get_y": {
+                    "$ref": "#/definitions/internal_api.BuyXGetY"
+                },
                 "code": {
                     "type": "string"
                 },
-                "discount": {
+                "description": {
+                    "type": "string"
+                },
+                "effect": {
+                    "type": "string",
+                    "enum": [
+                        "fixed_discount",
+                        "free_shipping",
+                        "buy_x_get_y",
+                        "cheapest_item_free",
+                        "tiered_discount"
+                    ]
+                },
+                "generate_code": {
+                    "type": "boolean"
+                },
+                "labels": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "owner": {
+                    "type": "string"
+                },
+                "terms": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_api.CouponTerms"
+                    }
+                }
+            }
+        },
+        "internal_api.GetCouponsRequest": {
+            "type": "object",
+            "properties": {
+                "codes": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "created_by": {
+                    "type": "string"
+                },
+                "labels": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "internal_api.IssueSignedCodesRequest": {
+            "type": "object",
+            "properties": {
+                "campaign_id": {
                     "type": "integer"
                 },
-                "minimum_basket_value": {
+                "count": {
+                    "type": "integer"
+                },
+                "discount": {
+                    "$ref": "#/definitions/internal_api.Money"
+                },
+                "expires_on": {
+                    "type": "string",
+                    "example": "2026-12-31"
+                },
+                "serial_from": {
                     "type": "integer"
                 }
             }
         },
-        "internal_api.GetCouponsRequest": {
+        "internal_api.IssueSignedCodesResponse": {
             "type": "object",
             "properties": {
                 "codes": {
@@ -252,6 +1095,72 @@ const docTemplate = `{
                 }
             }
         },
+        "internal_api.Money": {
+            "type": "object",
+            "properties": {
+                "amount": {
+                    "type": "integer"
+                },
+                "currency": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_api.RevokeSubjectTokensRequest": {
+            "type": "object",
+            "properties": {
+                "issued_before": {
+                    "description": "IssuedBefore revokes the tokens issued before this time, now by default.",
+                    "type": "string"
+                },
+                "subject": {
+                    "type": "string",
+                    "example": "user123"
+                }
+            }
+        },
+        "internal_api.RevokeTokenRequest": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "description": "ExpiresAt is the expiry of the token, its exp claim, after which it no longer needs to be remembered.",
+                    "type": "string"
+                },
+                "jti": {
+                    "description": "JTI is the ID of the token, its jti claim.",
+                    "type": "string",
+                    "example": "5b0d2e47-8f5c-4a1e-a1f4-3c2b9e7d6a10"
+                }
+            }
+        },
+        "internal_api.Tier": {
+            "type": "object",
+            "properties": {
+                "discount": {
+                    "$ref": "#/definitions/internal_api.Money"
+                },
+                "minimum_basket_value": {
+                    "$ref": "#/definitions/internal_api.Money"
+                }
+            }
+        },
+        "internal_api.TokenRevocationResponse": {
+            "type": "object",
+            "properties": {
+                "expires_at": {
+                    "type": "string"
+                },
+                "issued_before": {
+                    "type": "string"
+                },
+                "jti": {
+                    "type": "string"
+                },
+                "subject": {
+                    "type": "string"
+                }
+            }
+        },
         "pkg.Error": {
             "type": "object",
             "properties": {
@@ -259,14 +1168,37 @@ const docTemplate = `{
                     "description": "Machine-readable error code.",
                     "type": "string"
                 },
+                "details": {
+                    "description": "Optional additional information helping the client to recover from the error.",
+                    "type": "object",
+                    "additionalProperties": {}
+                },
                 "error": {
                     "description": "Optional wrapped error."
                 },
+                "fields": {
+                    "description": "Optional field-level validation errors.",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/pkg.FieldError"
+                    }
+                },
                 "message": {
                     "description": "Human-readable error message.",
                     "type": "string"
                 }
             }
+        },
+        "pkg.FieldError": {
+            "type": "object",
+            "properties": {
+                "field": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
         }
     }
 }`
@@ -276,7 +1208,7 @@ var SwaggerInfo = &swag.Spec{
 	Version:          "1.0",
 	Host:             "localhost:8080",
 	BasePath:         "/api/",
-	Schemes:          []string{"http"},
+	Schemes:          []string{"http", "https"},
 	Title:            "Coupon Service API",
 	Description:      "This is an API for managing coupons.",
 	InfoInstanceName: "swagger",
@@ -2,6 +2,7 @@ package api
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"log"
@@ -9,17 +10,76 @@ import (
 	"time"
 
 	"coupon_service/internal/api/auth"
+	"coupon_service/internal/bruteforce"
 	"coupon_service/internal/config"
+	"coupon_service/internal/idempotency"
+	"coupon_service/internal/ratelimit"
 	"coupon_service/internal/service"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
 type API struct {
-	srv *http.Server
-	mux *gin.Engine
-	svc service.CouponService
-	cfg config.Config
+	srv              *http.Server
+	mux              *gin.Engine
+	svc              service.CouponService
+	cfg              config.Config
+	guard            *bruteforce.Guard
+	adminLimiter     *ratelimit.Limiter
+	userLimiter      *ratelimit.Limiter
+	idempotencyStore idempotency.Store
+	tokenVerifier    auth.TokenAuthenticator
+	accessPolicy     *auth.AccessPolicy
+	revocations      auth.RevocationChecker
+	tlsConfig        *tls.Config
+	certificates     auth.CertificateSubjects
+}
+
+// Option customizes the API created by New.
+type Option func(*API)
+
+// WithBruteForceStore sets the store of the brute force protection, in memory by default.
+func WithBruteForceStore(store bruteforce.Store) Option {
+	return func(a *API) {
+		a.guard = bruteforce.New(store, bruteForceConfig(a.cfg))
+	}
+}
+
+// WithTokenVerifier sets the verifier of the bearer tokens, an auth.Verifier of JWTs or an auth.Introspector
+// of opaque tokens, HS256 with JWT_SECRET by default.
+func WithTokenVerifier(verifier auth.TokenAuthenticator) Option {
+	return func(a *API) {
+		a.tokenVerifier = verifier
+	}
+}
+
+// WithAccessPolicy sets the permissions granted to roles and scopes, auth.DefaultAccessPolicy by default.
+func WithAccessPolicy(policy *auth.AccessPolicy) Option {
+	return func(a *API) {
+		a.accessPolicy = policy
+	}
+}
+
+// WithRevocations rejects the bearer tokens revoked before their expiry, which are not checked by default.
+func WithRevocations(revocations auth.RevocationChecker) Option {
+	return func(a *API) {
+		a.revocations = revocations
+	}
+}
+
+// WithTLS serves the API over TLS with the configuration instead of plain HTTP.
+func WithTLS(cfg *tls.Config) Option {
+	return func(a *API) {
+		a.tlsConfig = cfg
+	}
+}
+
+// WithClientCertificates authenticates the callers presenting a verified client certificate, without a bearer token
+// or an API key, as the identity of its subject. Client certificates are ignored by default.
+func WithClientCertificates(subjects auth.CertificateSubjects) Option {
+	return func(a *API) {
+		a.certificates = subjects
+	}
 }
 
 // New creates a new API instance with the provided configuration and service.
@@ -29,18 +89,45 @@ type API struct {
 // @description This is an API for managing coupons.
 // @host localhost:8080
 // @BasePath /api/
-// @schemes http
-func New(cfg config.Config, svc service.CouponService) *API {
+// @schemes http https
+func New(cfg config.Config, svc service.CouponService, opts ...Option) *API {
 	router := SetupRouter(cfg)
 
 	api := &API{
-		mux: router,
-		cfg: cfg,
-		svc: svc,
+		mux:              router,
+		cfg:              cfg,
+		svc:              svc,
+		guard:            bruteforce.New(bruteforce.NewMemoryStore(), bruteForceConfig(cfg)),
+		adminLimiter:     newLimiter(cfg.Env.RateLimit.AdminPerMinute, cfg.Env.RateLimit.AdminBurst),
+		userLimiter:      newLimiter(cfg.Env.RateLimit.UserPerMinute, cfg.Env.RateLimit.UserBurst),
+		idempotencyStore: idempotency.NewMemoryStore(),
+		tokenVerifier:    auth.NewHMACVerifier([]byte(cfg.Env.AuthConfig.JWTSecret)),
+		accessPolicy:     auth.DefaultAccessPolicy(),
+	}
+	for _, opt := range opts {
+		opt(api)
 	}
 	return api.withServer().withRoutes()
 }
 
+// newLimiter returns the limiter of a route group, or nil when rate limiting is disabled by a zero rate.
+func newLimiter(perMinute, burst int) *ratelimit.Limiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return ratelimit.NewLimiter(ratelimit.Limit{PerMinute: perMinute, Burst: max(burst, 1)})
+}
+
+func bruteForceConfig(cfg config.Config) bruteforce.Config {
+	c := cfg.Env.BruteForce
+	return bruteforce.Config{
+		Threshold:   c.Threshold,
+		BaseLockout: c.BaseLockout,
+		MaxLockout:  c.MaxLockout,
+		Window:      c.Window,
+	}
+}
+
 // SetupRouter sets gin router according to the environment.
 func SetupRouter(cfg config.Config) *gin.Engine {
 	env := cfg.Env.Environment
@@ -57,23 +144,33 @@ func SetupRouter(cfg config.Config) *gin.Engine {
 
 	var router *gin.Engine
 	if env == config.ProductionEnv {
-		router = setupProductionRouter()
+		router = setupProductionRouter(cfg.Env.AuthConfig.TenantHeader)
 	} else {
 		router = setupDevRouter()
 	}
+	// the client IP, which callers are locked out and rate limited by, is only taken from the X-Forwarded-For
+	// header of trusted proxies, so that callers cannot choose it; no proxy is trusted by default
+	if err := router.SetTrustedProxies(cfg.Env.TrustedProxies); err != nil {
+		log.Printf("invalid trusted proxies, trusting none: %v", err)
+		_ = router.SetTrustedProxies(nil)
+	}
 	return router
 }
 
-// production-specific auth
-func setupProductionRouter() *gin.Engine {
+// production-specific auth, browsers being allowed to send the tenant header if one is configured
+func setupProductionRouter(tenantHeader string) *gin.Engine {
 	router := gin.New()
 	router.Use(gin.Recovery())
 
+	allowHeaders := []string{"Authorization", "X-API-Key", "Content-Type", "Idempotency-Key", "X-Request-ID"}
+	if tenantHeader != "" {
+		allowHeaders = append(allowHeaders, tenantHeader)
+	}
 	router.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"https://schwarz.es"}, // just an example, adapt to real needs
-		AllowMethods:     []string{"GET", "POST"},
-		AllowHeaders:     []string{"Authorization", "Content-Type"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "POST", "DELETE"},
+		AllowHeaders:     allowHeaders,
+		ExposeHeaders:    []string{"Content-Length", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After", "Idempotent-Replayed", "X-Request-ID"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -99,29 +196,66 @@ func (a *API) withServer() *API {
 		WriteTimeout:      10 * time.Second, // Max time to write the response
 		IdleTimeout:       20 * time.Second, // Max time for idle Keep-Alive connections
 		ReadHeaderTimeout: 3 * time.Second,  // Max time to read request headers
+		TLSConfig:         a.tlsConfig,
 	}
 	return a
 }
 
 func (a *API) withRoutes() *API {
-	authMiddleware := auth.TokenMiddleware(a.cfg.Env.AuthConfig.JWTSecret)
+	authOpts := []auth.MiddlewareOption{
+		auth.WithRolesClaim(a.cfg.Env.AuthConfig.RolesClaim),
+		// machine to machine callers authenticate with API keys instead of bearer tokens
+		auth.WithAPIKeys(a.svc),
+		// coupons, API keys and audit events are isolated per tenant
+		auth.WithTenant(a.cfg.Env.AuthConfig.TenantClaim, a.cfg.Env.AuthConfig.TenantHeader),
+	}
+	if a.revocations != nil {
+		authOpts = append(authOpts, auth.WithRevocations(a.revocations))
+	}
+	if a.certificates != nil {
+		authOpts = append(authOpts, auth.WithClientCertificates(a.certificates))
+	}
+	if c := a.cfg.Env.AuthConfig; c.DevMode {
+		log.Printf("WARNING: development auth mode enabled, requests without credentials are authenticated as %q with roles %v, "+
+			"never enable AUTH_DEV_MODE where the service is reachable by real callers", c.DevUser, c.DevRoles)
+		authOpts = append(authOpts, auth.WithDevIdentity(auth.DevIdentity{UserID: c.DevUser, Roles: c.DevRoles, TenantID: c.DevTenantID}))
+	}
+	authMiddleware := auth.TokenMiddleware(a.tokenVerifier, authOpts...)
+
+	// retries of POST requests with an Idempotency-Key are answered with the first response
+	idempotencyMiddleware := idempotent(a.idempotencyStore, a.cfg.Env.IdempotencyTTL)
 
 	apiGroup := a.mux.Group("/api")
-	apiGroup.Use(authMiddleware)
+	apiGroup.Use(requestID(), authMiddleware)
 
-	// Admin-only endpoints
+	// routes declare the permissions they require, granted to roles and scopes by the access policy
+	require := a.accessPolicy.Require
+
+	// Administrative endpoints
 	adminGroup := apiGroup.Group("")
-	adminGroup.Use(auth.RequireRoles(auth.RoleAdmin))
+	adminGroup.Use(rateLimit(a.adminLimiter))
 	{
-		adminGroup.POST("/coupon", a.CreateCoupon)
+		adminGroup.POST("/coupon", require(auth.PermCouponsCreate), idempotencyMiddleware, a.CreateCoupon)
+		adminGroup.POST("/coupon/signed", require(auth.PermCouponsCreate), idempotencyMiddleware, a.IssueSignedCodes)
+		adminGroup.GET("/audit", require(auth.PermAuditRead), a.GetAuditEvents)
+		adminGroup.GET("/audit/verify", require(auth.PermAuditRead), a.VerifyAuditLog)
+		// responses carrying the secret of a key are never stored for replay, so creating keys is not idempotent
+		adminGroup.POST("/apikeys", require(auth.PermAPIKeysManage), a.CreateAPIKey)
+		adminGroup.GET("/apikeys", require(auth.PermAPIKeysManage), a.GetAPIKeys)
+		adminGroup.DELETE("/apikeys/:id", require(auth.PermAPIKeysManage), a.RevokeAPIKey)
+		adminGroup.POST("/revocations/tokens", require(auth.PermTokensRevoke), idempotencyMiddleware, a.RevokeToken)
+		adminGroup.POST("/revocations/subjects", require(auth.PermTokensRevoke), idempotencyMiddleware, a.RevokeSubjectTokens)
 	}
 
-	// Endpoints that both users and admins can access
+	// Endpoints used by shoppers
 	userGroup := apiGroup.Group("")
-	userGroup.Use(auth.RequireRoles(auth.RoleUser, auth.RoleAdmin))
+	userGroup.Use(rateLimit(a.userLimiter))
 	{
-		userGroup.POST("/coupon/validation", a.ApplyCoupon)
-		userGroup.GET("/coupons", a.GetCoupons)
+		// lookups of unknown codes are counted to lock out callers guessing codes
+		lookup := bruteForceProtection(a.guard)
+		// locked out callers are answered before their Idempotency-Key is used, so their 429 is never replayed
+		userGroup.POST("/coupon/validation", require(auth.PermCouponsApply), lookup, idempotencyMiddleware, a.ApplyCoupon)
+		userGroup.GET("/coupons", require(auth.PermCouponsRead), lookup, a.GetCoupons)
 	}
 	return a
 }
@@ -129,6 +263,11 @@ func (a *API) withRoutes() *API {
 func (a *API) Start() error {
 	log.Printf("Starting the service on port: %v", a.cfg.Env.Port)
 	log.Printf("Running in %s mode", a.cfg.Env.Environment)
+	if a.srv.TLSConfig != nil {
+		log.Printf("Serving TLS")
+		// the certificate is served by the TLS configuration
+		return a.srv.ListenAndServeTLS("", "")
+	}
 	return a.srv.ListenAndServe()
 }
 
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"coupon_service/internal/api/auth"
+	"coupon_service/internal/entity"
+	"coupon_service/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+type CreateAPIKeyRequest struct {
+	Name string `json:"name" example:"checkout backend"`
+	// Scopes are the permissions granted to the key.
+	Scopes    []string   `json:"scopes" example:"coupons:apply,coupons:read"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+func (r CreateAPIKeyRequest) validate() error {
+	for _, scope := range r.Scopes {
+		if !auth.ValidPermission(scope) {
+			return pkg.Errorf(pkg.EINVALID, fmt.Sprintf("unknown scope %q", scope), nil)
+		}
+	}
+	return nil
+}
+
+func (r CreateAPIKeyRequest) toEntity() entity.APIKey {
+	key := entity.APIKey{Name: r.Name, Scopes: r.Scopes}
+	if r.ExpiresAt != nil {
+		key.ExpiresAt = r.ExpiresAt.UTC()
+	}
+	return key
+}
+
+type APIKeyResponse struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	CreatedBy  string     `json:"created_by,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	// Key is the secret to present in the X-API-Key header, only returned when the key is created.
+	Key string `json:"key,omitempty"`
+}
+
+func newAPIKeyResponse(k entity.APIKey) APIKeyResponse {
+	return APIKeyResponse{
+		ID:         k.ID,
+		Name:       k.Name,
+		Scopes:     k.Scopes,
+		ExpiresAt:  optionalTime(k.ExpiresAt),
+		CreatedAt:  k.CreatedAt,
+		CreatedBy:  k.CreatedBy,
+		LastUsedAt: optionalTime(k.LastUsedAt),
+	}
+}
+
+// CreateAPIKey godoc
+// @Summary      Create an API key
+// @Description  Creates an API key for a machine to machine caller, granted the permissions of its scopes, which must be granted to the caller as well. The key is only returned in this response, only its hash being stored.
+// @Tags         apikeys
+// @Accept       json
+// @Security     BearerAuth
+// @Produce      json
+// @Param        request body CreateAPIKeyRequest true "Name, scopes and optional expiry of the key"
+// @Success      201 {object} APIKeyResponse
+// @Failure      400 {object} pkg.Error
+// @Success      401
+// @Failure      403
+// @Failure      429 {object} pkg.Error
+// @Failure      501 {object} pkg.Error
+// @Router       /apikeys [post]
+func (a *API) CreateAPIKey(c *gin.Context) {
+	input := CreateAPIKeyRequest{}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		WebErr(c, pkg.Errorf(pkg.EINVALID, "invalid request body", err))
+		return
+	}
+	if err := input.validate(); err != nil {
+		WebErr(c, err)
+		return
+	}
+	// callers can only delegate their own permissions, so that keys never escalate privileges
+	for _, scope := range input.Scopes {
+		if !a.accessPolicy.Granted(c, auth.Permission(scope)) {
+			WebErr(c, pkg.Errorf(pkg.EFORBIDDEN, fmt.Sprintf("scope %q is not granted to the caller", scope), nil))
+			return
+		}
+	}
+
+	key, secret, err := a.svc.CreateAPIKey(a.requestContext(c), input.toEntity())
+	if err != nil {
+		WebErr(c, err)
+		return
+	}
+
+	response := newAPIKeyResponse(key)
+	response.Key = secret
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetAPIKeys godoc
+// @Summary      List the API keys
+// @Description  Returns every API key, oldest first, without their secrets.
+// @Tags         apikeys
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200 {array} APIKeyResponse
+// @Success      401
+// @Failure      403
+// @Failure      429 {object} pkg.Error
+// @Failure      501 {object} pkg.Error
+// @Router       /apikeys [get]
+func (a *API) GetAPIKeys(c *gin.Context) {
+	keys, err := a.svc.ListAPIKeys(a.requestContext(c))
+	if err != nil {
+		WebErr(c, err)
+		return
+	}
+
+	response := make([]APIKeyResponse, len(keys))
+	for i, k := range keys {
+		response[i] = newAPIKeyResponse(k)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RevokeAPIKey godoc
+// @Summary      Revoke an API key
+// @Description  Deletes the API key, which is rejected from then on.
+// @Tags         apikeys
+// @Security     BearerAuth
+// @Param        id path string true "ID of the API key"
+// @Success      204
+// @Success      401
+// @Failure      403
+// @Failure      404 {object} pkg.Error
+// @Failure      429 {object} pkg.Error
+// @Failure      501 {object} pkg.Error
+// @Router       /apikeys/{id} [delete]
+func (a *API) RevokeAPIKey(c *gin.Context) {
+	if err := a.svc.RevokeAPIKey(a.requestContext(c), c.Param("id")); err != nil {
+		WebErr(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
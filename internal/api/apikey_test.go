@@ -0,0 +1,142 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"coupon_service/internal/api/auth"
+	"coupon_service/internal/entity"
+	"coupon_service/internal/service"
+	"coupon_service/pkg"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPI_CreateAPIKey(t *testing.T) {
+	createdAt := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		body         string
+		mockSvcError error
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "created",
+			body:         `{"name": "checkout", "scopes": ["coupons:apply"], "expires_at": "2027-01-01T00:00:00Z"}`,
+			expectedCode: http.StatusCreated,
+			expectedBody: `"key":"cs_key1.secret"`,
+		},
+		{
+			name:         "invalid, unknown scope",
+			body:         `{"name": "checkout", "scopes": ["coupons:delete"]}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `unknown scope \"coupons:delete\"`,
+		},
+		{
+			name:         "forbidden, scope not granted to the caller",
+			body:         `{"name": "checkout", "scopes": ["coupons:apply", "apikeys:manage"]}`,
+			expectedCode: http.StatusForbidden,
+			expectedBody: `scope \"apikeys:manage\" is not granted to the caller`,
+		},
+		{
+			name:         "invalid, not JSON",
+			body:         `name=checkout`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "invalid request body",
+		},
+		{
+			name:         "invalid, rejected by the service",
+			body:         `{"scopes": ["coupons:apply"]}`,
+			mockSvcError: pkg.Errorf(pkg.EINVALID, "name cannot be empty", nil),
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "name cannot be empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svcMock := &service.CouponServiceMock{
+				CreateAPIKeyFunc: func(ctx context.Context, key entity.APIKey) (entity.APIKey, string, error) {
+					if tt.mockSvcError != nil {
+						return entity.APIKey{}, "", tt.mockSvcError
+					}
+					assert.Equal(t, entity.APIKey{
+						Name:      "checkout",
+						Scopes:    []string{"coupons:apply"},
+						ExpiresAt: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+					}, key)
+					key.ID = "key1"
+					key.CreatedAt = createdAt
+					key.CreatedBy = "user123"
+					return key, "cs_key1.secret", nil
+				},
+			}
+			// the caller has the user role
+			api := &API{svc: svcMock, accessPolicy: auth.DefaultAccessPolicy()}
+			router := setupRouter(api)
+			router.POST("/apikeys", api.CreateAPIKey)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/apikeys", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestAPI_GetAPIKeys(t *testing.T) {
+	svcMock := &service.CouponServiceMock{
+		ListAPIKeysFunc: func(ctx context.Context) ([]entity.APIKey, error) {
+			return []entity.APIKey{{ID: "key1", Name: "checkout", Hash: "hash", Scopes: []string{"coupons:apply"}}}, nil
+		},
+	}
+	api := &API{svc: svcMock}
+	router := setupRouter(api)
+	router.GET("/apikeys", api.GetAPIKeys)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/apikeys", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"id":"key1","name":"checkout","scopes":["coupons:apply"]`)
+	// neither the secret nor its hash are ever returned
+	assert.NotContains(t, rec.Body.String(), "hash")
+	assert.NotContains(t, rec.Body.String(), `"key"`)
+	assert.NotContains(t, rec.Body.String(), "last_used_at")
+}
+
+func TestAPI_RevokeAPIKey(t *testing.T) {
+	tests := []struct {
+		name         string
+		mockSvcError error
+		expectedCode int
+	}{
+		{name: "revoked", expectedCode: http.StatusNoContent},
+		{name: "not found", mockSvcError: pkg.Errorf(pkg.ENOTFOUND, "API key not found", nil), expectedCode: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svcMock := &service.CouponServiceMock{
+				RevokeAPIKeyFunc: func(ctx context.Context, id string) error {
+					assert.Equal(t, "key1", id)
+					return tt.mockSvcError
+				},
+			}
+			api := &API{svc: svcMock}
+			router := setupRouter(api)
+			router.DELETE("/apikeys/:id", api.RevokeAPIKey)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/apikeys/key1", nil))
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+		})
+	}
+}
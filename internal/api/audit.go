@@ -0,0 +1,139 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"coupon_service/internal/entity"
+	"coupon_service/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+type AuditQuery struct {
+	Code  string `form:"code"`
+	Actor string `form:"actor"`
+	From  string `form:"from" example:"2026-01-01T00:00:00Z"`
+	To    string `form:"to" example:"2026-12-31T23:59:59Z"`
+}
+
+func (q AuditQuery) toEntity() (entity.AuditFilter, error) {
+	filter := entity.AuditFilter{CouponCode: q.Code, Actor: q.Actor}
+	var err error
+	if q.From != "" {
+		if filter.From, err = time.Parse(time.RFC3339, q.From); err != nil {
+			return entity.AuditFilter{}, pkg.Errorf(pkg.EINVALID, "from must be an RFC 3339 timestamp", err)
+		}
+	}
+	if q.To != "" {
+		if filter.To, err = time.Parse(time.RFC3339, q.To); err != nil {
+			return entity.AuditFilter{}, pkg.Errorf(pkg.EINVALID, "to must be an RFC 3339 timestamp", err)
+		}
+	}
+	return filter, nil
+}
+
+type AuditEventResponse struct {
+	ID         string          `json:"id"`
+	Time       time.Time       `json:"time"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action" enums:"coupon.created,signed_codes.issued,api_key.created,api_key.revoked,token.revoked,subject_tokens.revoked"`
+	CouponCode string          `json:"coupon_code,omitempty"`
+	Before     json.RawMessage `json:"before,omitempty" swaggertype:"object"`
+	After      json.RawMessage `json:"after,omitempty" swaggertype:"object"`
+	RequestID  string          `json:"request_id,omitempty"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+func newAuditEventResponse(e entity.AuditEvent) AuditEventResponse {
+	return AuditEventResponse{
+		ID:         e.ID,
+		Time:       e.Time,
+		Actor:      e.Actor,
+		Action:     string(e.Action),
+		CouponCode: e.CouponCode,
+		Before:     e.Before,
+		After:      e.After,
+		RequestID:  e.RequestID,
+		PrevHash:   e.PrevHash,
+		Hash:       e.Hash,
+	}
+}
+
+// GetAuditEvents godoc
+// @Summary      Query the audit log
+// @Description  Returns the recorded administrative changes, oldest first, optionally filtered by coupon code, actor and time range.
+// @Tags         audit
+// @Security     BearerAuth
+// @Produce      json
+// @Param        code  query string false "Code of the changed coupon"
+// @Param        actor query string false "ID of the user who made the change"
+// @Param        from  query string false "Start of the time range, RFC 3339, inclusive"
+// @Param        to    query string false "End of the time range, RFC 3339, inclusive"
+// @Success      200 {array} AuditEventResponse
+// @Failure      400 {object} pkg.Error
+// @Success      401
+// @Failure      403
+// @Failure      429 {object} pkg.Error
+// @Failure      501 {object} pkg.Error
+// @Router       /audit [get]
+func (a *API) GetAuditEvents(c *gin.Context) {
+	query := AuditQuery{}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		WebErr(c, pkg.Errorf(pkg.EINVALID, "invalid query parameters", err))
+		return
+	}
+
+	filter, err := query.toEntity()
+	if err != nil {
+		WebErr(c, err)
+		return
+	}
+
+	events, err := a.svc.QueryAudit(a.requestContext(c), filter)
+	if err != nil {
+		WebErr(c, err)
+		return
+	}
+
+	response := make([]AuditEventResponse, len(events))
+	for i, e := range events {
+		response[i] = newAuditEventResponse(e)
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+type AuditVerificationResponse struct {
+	Valid         bool   `json:"valid"`
+	Checked       int    `json:"checked"`
+	BrokenEventID string `json:"broken_event_id,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+}
+
+// VerifyAuditLog godoc
+// @Summary      Verify the audit log
+// @Description  Walks the hash chain of the audit events of the tenant of the caller, oldest event first, and reports the first event whose hash does not match its content or whose link to the previous event is broken, or the last remaining event when the last events of the chain were removed.
+// @Tags         audit
+// @Security     BearerAuth
+// @Produce      json
+// @Success      200 {object} AuditVerificationResponse
+// @Success      401
+// @Failure      403
+// @Failure      429 {object} pkg.Error
+// @Failure      501 {object} pkg.Error
+// @Router       /audit/verify [get]
+func (a *API) VerifyAuditLog(c *gin.Context) {
+	v, err := a.svc.VerifyAudit(a.requestContext(c))
+	if err != nil {
+		WebErr(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, AuditVerificationResponse{
+		Valid:         v.Valid,
+		Checked:       v.Checked,
+		BrokenEventID: v.BrokenEventID,
+		Reason:        v.Reason,
+	})
+}
@@ -0,0 +1,172 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"coupon_service/internal/entity"
+	"coupon_service/internal/identity"
+	"coupon_service/internal/service"
+	"coupon_service/pkg"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPI_GetAuditEvents(t *testing.T) {
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		query        string
+		mockSvcError error
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "all events",
+			expectedCode: http.StatusOK,
+			expectedBody: `"action":"coupon.created"`,
+		},
+		{
+			name:         "filtered by code, actor and time range",
+			query:        "?code=SUMMER&actor=admin1&from=2026-01-01T00:00:00Z&to=2026-01-31T00:00:00Z",
+			expectedCode: http.StatusOK,
+			expectedBody: `"after":{"code":"SUMMER"}`,
+		},
+		{
+			name:         "invalid, from is not a timestamp",
+			query:        "?from=2026-01-01",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "from must be an RFC 3339 timestamp",
+		},
+		{
+			name:         "invalid, to is not a timestamp",
+			query:        "?to=yesterday",
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "to must be an RFC 3339 timestamp",
+		},
+		{
+			name:         "audit log not enabled",
+			mockSvcError: pkg.Errorf(pkg.ENOTIMPLEMENTED, "audit log is not enabled", nil),
+			expectedCode: http.StatusNotImplemented,
+			expectedBody: "audit log is not enabled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svcMock := &service.CouponServiceMock{
+				QueryAuditFunc: func(ctx context.Context, f entity.AuditFilter) ([]entity.AuditEvent, error) {
+					if tt.query != "" {
+						assert.Equal(t, entity.AuditFilter{
+							CouponCode: "SUMMER",
+							Actor:      "admin1",
+							From:       from,
+							To:         from.AddDate(0, 0, 30),
+						}, f)
+					}
+					if tt.mockSvcError != nil {
+						return nil, tt.mockSvcError
+					}
+					return []entity.AuditEvent{{
+						ID:         "1",
+						Time:       from,
+						Actor:      "admin1",
+						Action:     entity.AuditCouponCreated,
+						CouponCode: "SUMMER",
+						After:      []byte(`{"code":"SUMMER"}`),
+					}}, nil
+				},
+			}
+			api := &API{svc: svcMock}
+			router := setupRouter(api)
+			router.GET("/audit", api.GetAuditEvents)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/audit"+tt.query, nil))
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestRequestID(t *testing.T) {
+	r := gin.New()
+	r.Use(requestID())
+	r.GET("/", func(c *gin.Context) {
+		c.String(http.StatusOK, identity.RequestID((&API{}).requestContext(c)))
+	})
+	request := func(id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if id != "" {
+			req.Header.Set("X-Request-ID", id)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request("req-1")
+	assert.Equal(t, "req-1", rec.Body.String())
+	assert.Equal(t, "req-1", rec.Header().Get("X-Request-ID"))
+
+	// missing or unusable IDs are replaced by a generated one
+	for _, id := range []string{"", "bad\nid", string(make([]byte, maxRequestIDLength+1))} {
+		rec := request(id)
+		assert.NotEmpty(t, rec.Body.String())
+		assert.NotEqual(t, id, rec.Body.String())
+		assert.Equal(t, rec.Body.String(), rec.Header().Get("X-Request-ID"))
+	}
+}
+
+func TestAPI_VerifyAuditLog(t *testing.T) {
+	tests := []struct {
+		name         string
+		verification entity.AuditVerification
+		mockSvcError error
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "valid",
+			verification: entity.AuditVerification{Valid: true, Checked: 3},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"valid":true,"checked":3}`,
+		},
+		{
+			name:         "broken link",
+			verification: entity.AuditVerification{Checked: 1, BrokenEventID: "2", Reason: "hash does not match the event content"},
+			expectedCode: http.StatusOK,
+			expectedBody: `{"valid":false,"checked":1,"broken_event_id":"2","reason":"hash does not match the event content"}`,
+		},
+		{
+			name:         "audit log not enabled",
+			mockSvcError: pkg.Errorf(pkg.ENOTIMPLEMENTED, "audit log is not enabled", nil),
+			expectedCode: http.StatusNotImplemented,
+			expectedBody: "audit log is not enabled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svcMock := &service.CouponServiceMock{
+				VerifyAuditFunc: func(ctx context.Context) (entity.AuditVerification, error) {
+					return tt.verification, tt.mockSvcError
+				},
+			}
+			api := &API{svc: svcMock}
+			router := setupRouter(api)
+			router.GET("/audit/verify", api.VerifyAuditLog)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/audit/verify", nil))
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"os"
+
+	"coupon_service/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+// CertificateUserPrefix prefixes the subject of the client certificate of a caller to make up its user_id.
+const CertificateUserPrefix = "cert:"
+
+// CertificateIdentity is the identity granted to the callers presenting a client certificate of a subject.
+type CertificateIdentity struct {
+	Roles    []string `json:"roles"`
+	TenantID string   `json:"tenant,omitempty"`
+}
+
+// CertificateSubjects maps the subjects of client certificates, distinguished names in the RFC 2253 form such as
+// "CN=checkout,O=Shop", to the identity of their callers.
+type CertificateSubjects map[string]CertificateIdentity
+
+// LoadCertificateSubjects reads the identities of client certificate subjects from a JSON file such as
+//
+//	{"CN=checkout,O=Shop": {"roles": ["user"], "tenant": "shop"}}
+func LoadCertificateSubjects(path string) (CertificateSubjects, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, pkg.Errorf(pkg.EINTERNAL, "failed to read the client certificate subjects file", err)
+	}
+	subjects := CertificateSubjects{}
+	if err := json.Unmarshal(data, &subjects); err != nil {
+		return nil, pkg.Errorf(pkg.EINTERNAL, "invalid client certificate subjects file", err)
+	}
+	return subjects, nil
+}
+
+// WithClientCertificates authenticates the requests without a bearer token or an API key made with a client
+// certificate verified during the TLS handshake, as the identity of its subject. Certificates of unknown
+// subjects are rejected. Client certificates are ignored by default.
+func WithClientCertificates(subjects CertificateSubjects) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.certificates = subjects
+	}
+}
+
+// clientCertificate returns the client certificate of the request, if it was verified.
+func clientCertificate(c *gin.Context) *x509.Certificate {
+	state := c.Request.TLS
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return state.VerifiedChains[0][0]
+}
+
+// authenticateCertificate authenticates the caller with its client certificate, whose subject belongs to a single tenant.
+func authenticateCertificate(c *gin.Context, cfg middlewareConfig, cert *x509.Certificate) {
+	subject := cert.Subject.String()
+	identity, ok := cfg.certificates[subject]
+	if !ok {
+		abort(c, pkg.Errorf(pkg.EUNAUTHORIZED, "client certificate subject is not accepted", nil))
+		return
+	}
+	if err := cfg.matchTenantHeader(c, identity.TenantID); err != nil {
+		abort(c, err)
+		return
+	}
+
+	c.Set("user_id", CertificateUserPrefix+subject)
+	c.Set("roles", identity.Roles)
+	c.Set("scopes", []string(nil))
+	c.Set("tenant_id", identity.TenantID)
+	c.Next()
+}
@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadCertificateSubjects(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "subjects.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"CN=checkout,O=Shop": {"roles": ["user"], "tenant": "shop"}}`), 0o600))
+	subjects, err := LoadCertificateSubjects(path)
+	assert.NoError(t, err)
+	assert.Equal(t, CertificateSubjects{"CN=checkout,O=Shop": {Roles: []string{"user"}, TenantID: "shop"}}, subjects)
+
+	require.NoError(t, os.WriteFile(path, []byte(`["CN=checkout"]`), 0o600))
+	_, err = LoadCertificateSubjects(path)
+	assert.ErrorContains(t, err, "invalid client certificate subjects file")
+}
+
+func TestTokenMiddleware_ClientCertificates(t *testing.T) {
+	secret := []byte("shared-secret-of-at-least-32-bytes!")
+	subjects := CertificateSubjects{"CN=checkout,O=Shop": {Roles: []string{RoleUser}, TenantID: "shop"}}
+	checkout := &x509.Certificate{Subject: pkix.Name{CommonName: "checkout", Organization: []string{"Shop"}}}
+	unknown := &x509.Certificate{Subject: pkix.Name{CommonName: "unknown"}}
+	tests := []struct {
+		name         string
+		subjects     CertificateSubjects
+		cert         *x509.Certificate
+		verified     bool
+		token        string
+		tenantHeader string
+		expectedCode int
+		expectedBody string
+	}{
+		{name: "mapped subject", subjects: subjects, cert: checkout, verified: true, expectedCode: http.StatusOK, expectedBody: "cert:CN=checkout,O=Shop [user] shop"},
+		{
+			name:         "unknown subject",
+			subjects:     subjects,
+			cert:         unknown,
+			verified:     true,
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: "client certificate subject is not accepted",
+		},
+		{
+			name:         "unverified certificate",
+			subjects:     subjects,
+			cert:         checkout,
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: "missing authorization header",
+		},
+		{
+			name:         "bearer token preferred",
+			subjects:     subjects,
+			cert:         checkout,
+			verified:     true,
+			token:        "Bearer " + signClaims(t, jwt.SigningMethodHS256, "", secret, jwt.MapClaims{"sub": "user1", "roles": []string{"admin"}, "exp": time.Now().Add(time.Hour).Unix()}),
+			expectedCode: http.StatusOK,
+			expectedBody: "user1 [admin] ",
+		},
+		{
+			name:         "tenant header of another tenant",
+			subjects:     subjects,
+			cert:         checkout,
+			verified:     true,
+			tenantHeader: "globex",
+			expectedCode: http.StatusForbidden,
+			expectedBody: "tenant does not match the credentials",
+		},
+		{name: "certificates ignored", cert: checkout, verified: true, expectedCode: http.StatusUnauthorized, expectedBody: "missing authorization header"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []MiddlewareOption{WithTenant("", "X-Tenant-ID")}
+			if tt.subjects != nil {
+				opts = append(opts, WithClientCertificates(tt.subjects))
+			}
+			r := gin.New()
+			r.GET("/", TokenMiddleware(NewHMACVerifier(secret), opts...), func(c *gin.Context) {
+				c.String(http.StatusOK, "%s %v %s", c.GetString("user_id"), c.GetStringSlice("roles"), c.GetString("tenant_id"))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{tt.cert}}
+			if tt.verified {
+				req.TLS.VerifiedChains = [][]*x509.Certificate{{tt.cert}}
+			}
+			if tt.token != "" {
+				req.Header.Set("Authorization", tt.token)
+			}
+			req.Header.Set("X-Tenant-ID", tt.tenantHeader)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+		})
+	}
+}
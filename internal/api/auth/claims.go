@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"encoding/json"
+	"strings"
+
+	"coupon_service/pkg"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultRolesClaim is the claim holding the roles of the caller, unless configured otherwise.
+const DefaultRolesClaim = "roles"
+
+// Claims are the claims of a bearer token: the registered claims, and every other claim to read the roles from.
+type Claims struct {
+	jwt.RegisteredClaims
+	all map[string]any
+}
+
+func (c *Claims) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &c.RegisteredClaims); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &c.all)
+}
+
+// Roles returns the roles found at the dot separated path of the claims, such as "realm_access.roles".
+// Roles are either an array of strings or a space delimited string, as OAuth 2.0 scopes. A missing claim means no roles.
+func (c *Claims) Roles(path string) ([]string, error) {
+	return c.strings(path, "roles")
+}
+
+// Scopes returns the OAuth 2.0 scopes of the scope claim (RFC 9068), or of the scp claim used by some providers.
+func (c *Claims) Scopes() ([]string, error) {
+	if _, ok := c.all["scope"]; ok {
+		return c.strings("scope", "scope")
+	}
+	return c.strings("scp", "scp")
+}
+
+// Tenant returns the tenant ID found at the dot separated path of the claims, empty when the claim is missing.
+func (c *Claims) Tenant(path string) (string, error) {
+	switch v := c.lookup(path).(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+	return "", pkg.Errorf(pkg.EUNAUTHORIZED, "tenant claim must be a string", nil)
+}
+
+// lookup returns the value found at the dot separated path of the claims, nil when there is none.
+func (c *Claims) lookup(path string) any {
+	var value any = c.all
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return nil
+		}
+		if value, ok = object[key]; !ok {
+			return nil
+		}
+	}
+	return value
+}
+
+// strings returns the array of strings or the space delimited string found at the path of the claims.
+func (c *Claims) strings(path, name string) ([]string, error) {
+	switch v := c.lookup(path).(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return strings.Fields(v), nil
+	case []any:
+		values := make([]string, len(v))
+		for i, e := range v {
+			s, ok := e.(string)
+			if !ok {
+				return nil, pkg.Errorf(pkg.EUNAUTHORIZED, name+" claim must only contain strings", nil)
+			}
+			values[i] = s
+		}
+		return values, nil
+	}
+	return nil, pkg.Errorf(pkg.EUNAUTHORIZED, name+" claim must be an array or a space delimited string", nil)
+}
@@ -0,0 +1,164 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"coupon_service/pkg"
+)
+
+// ClientUserPrefix prefixes the client_id of the introspected tokens without a subject, such as the tokens of the
+// client credentials grant, to make up the user_id of their callers, which cannot be mistaken for a user then.
+const ClientUserPrefix = "client:"
+
+const (
+	// maxIntrospectionSize bounds the size of the introspection responses.
+	maxIntrospectionSize = 1 << 20
+	// sweepIntrospectionsEvery is the number of introspections after which expired cached results are removed.
+	sweepIntrospectionsEvery = 1024
+)
+
+// Introspector authenticates opaque bearer tokens with the introspection endpoint of the authorization server
+// (RFC 7662). Active tokens are cached until they expire, so that the endpoint is queried once per token,
+// while inactive tokens are introspected again on every request.
+type Introspector struct {
+	endpoint     string
+	clientID     string
+	clientSecret string
+	policy       Policy
+	client       *http.Client
+	now          func() time.Time
+
+	mu             sync.Mutex
+	cache          map[[sha256.Size]byte]*Claims
+	introspections int
+}
+
+// NewIntrospector returns an introspector of the tokens querying endpoint, authenticated with HTTP basic
+// authentication when clientID is not empty. The issuers and audiences of the policy are checked, if any.
+func NewIntrospector(endpoint, clientID, clientSecret string, policy Policy) *Introspector {
+	return &Introspector{
+		endpoint:     endpoint,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		policy:       policy,
+		client:       &http.Client{Timeout: 10 * time.Second},
+		now:          time.Now,
+		cache:        make(map[[sha256.Size]byte]*Claims),
+	}
+}
+
+// Authenticate returns the claims of the token if it is active.
+func (i *Introspector) Authenticate(ctx context.Context, token string) (*Claims, error) {
+	// tokens are cached by hash, so that they are not kept in memory
+	key := sha256.Sum256([]byte(token))
+	if claims, ok := i.cached(key); ok {
+		return claims, nil
+	}
+
+	claims, err := i.introspect(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := i.policy.accepts(claims); err != nil {
+		return nil, err
+	}
+
+	// tokens without expiry cannot be cached, as they may be revoked at any time
+	if claims.ExpiresAt != nil {
+		i.mu.Lock()
+		defer i.mu.Unlock()
+		i.introspections++
+		if i.introspections%sweepIntrospectionsEvery == 0 {
+			i.sweep(i.now())
+		}
+		i.cache[key] = claims
+	}
+	return claims, nil
+}
+
+func (i *Introspector) cached(key [sha256.Size]byte) (*Claims, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	claims, ok := i.cache[key]
+	if !ok {
+		return nil, false
+	}
+	if !i.now().Before(claims.ExpiresAt.Time) {
+		delete(i.cache, key)
+		return nil, false
+	}
+	return claims, true
+}
+
+// introspect queries the introspection endpoint and returns the claims of the token if it is active.
+func (i *Introspector) introspect(ctx context.Context, token string) (*Claims, error) {
+	form := url.Values{"token": {token}, "token_type_hint": {"access_token"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, i.endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, pkg.Errorf(pkg.EINTERNAL, "invalid introspection endpoint", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if i.clientID != "" {
+		req.SetBasicAuth(url.QueryEscape(i.clientID), url.QueryEscape(i.clientSecret))
+	}
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		log.Printf("failed to introspect a token: %v", err)
+		return nil, pkg.Errorf(pkg.EINTERNAL, "failed to introspect the token", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("failed to introspect a token: status %d", resp.StatusCode)
+		return nil, pkg.Errorf(pkg.EINTERNAL, fmt.Sprintf("failed to introspect the token: status %d", resp.StatusCode), nil)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxIntrospectionSize))
+	if err != nil {
+		return nil, pkg.Errorf(pkg.EINTERNAL, "failed to introspect the token", err)
+	}
+
+	var result struct {
+		Active   bool   `json:"active"`
+		ClientID string `json:"client_id"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, pkg.Errorf(pkg.EINTERNAL, "invalid introspection response", err)
+	}
+	if !result.Active {
+		return nil, pkg.Errorf(pkg.EUNAUTHORIZED, "token is not active", nil)
+	}
+	claims := &Claims{}
+	if err := json.Unmarshal(data, claims); err != nil {
+		return nil, pkg.Errorf(pkg.EUNAUTHORIZED, "malformed token", err)
+	}
+	// sub is optional in introspection responses, the token then acting on behalf of its client only
+	if claims.Subject == "" && result.ClientID != "" {
+		claims.Subject = ClientUserPrefix + result.ClientID
+	}
+	// the authorization server vouches for the token at the time of the request, the expiry guarding against clock skew
+	if claims.ExpiresAt != nil && !i.now().Before(claims.ExpiresAt.Add(i.policy.Leeway)) {
+		return nil, pkg.Errorf(pkg.EUNAUTHORIZED, "token has expired", nil)
+	}
+	return claims, nil
+}
+
+// sweep removes the cached results of the expired tokens.
+func (i *Introspector) sweep(now time.Time) {
+	for key, claims := range i.cache {
+		if !now.Before(claims.ExpiresAt.Time) {
+			delete(i.cache, key)
+		}
+	}
+}
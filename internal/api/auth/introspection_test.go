@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"coupon_service/pkg"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// introspectionServer stands in for the introspection endpoint of an authorization server, answering with the
+// response registered for each token and counting the requests.
+func introspectionServer(t *testing.T, responses map[string]map[string]any) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if id, secret, ok := r.BasicAuth(); !ok || id != "coupon-service" || secret != "client-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		assert.Equal(t, "access_token", r.PostFormValue("token_type_hint"))
+		response, ok := responses[r.PostFormValue("token")]
+		if !ok {
+			response = map[string]any{"active": false}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.NoError(t, json.NewEncoder(w).Encode(response))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func TestIntrospector(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	exp := now.Add(time.Hour).Unix()
+	srv, calls := introspectionServer(t, map[string]map[string]any{
+		"active":       {"active": true, "sub": "user123", "exp": exp, "iss": "https://idp.example.com", "aud": "coupon-service"},
+		"expired":      {"active": true, "sub": "user123", "exp": now.Add(-time.Hour).Unix()},
+		"other-issuer": {"active": true, "sub": "user123", "exp": exp, "iss": "https://other.example.com"},
+		"malformed":    {"active": true, "sub": "user123", "exp": "tomorrow"},
+		"client":       {"active": true, "client_id": "checkout", "exp": exp, "iss": "https://idp.example.com"},
+	})
+	introspector := NewIntrospector(srv.URL, "coupon-service", "client-secret", Policy{Issuers: []string{"https://idp.example.com"}})
+	introspector.now = func() time.Time { return now }
+
+	tests := []struct {
+		token       string
+		wantSubject string
+		wantErr     string
+	}{
+		{token: "active", wantSubject: "user123"},
+		{token: "client", wantSubject: "client:checkout"},
+		{token: "unknown", wantErr: "token is not active"},
+		{token: "expired", wantErr: "token has expired"},
+		{token: "other-issuer", wantErr: "token issuer is not accepted"},
+		{token: "malformed", wantErr: "malformed token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			claims, err := introspector.Authenticate(context.Background(), tt.token)
+			if tt.wantErr != "" {
+				assert.Equal(t, pkg.EUNAUTHORIZED, pkg.ErrorCode(err))
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantSubject, claims.Subject)
+		})
+	}
+
+	// active tokens are cached until they expire, inactive ones are introspected again
+	calls.Store(0)
+	for range 3 {
+		_, err := introspector.Authenticate(context.Background(), "active")
+		assert.NoError(t, err)
+		_, err = introspector.Authenticate(context.Background(), "unknown")
+		assert.Error(t, err)
+	}
+	assert.Equal(t, int32(3), calls.Load())
+
+	now = now.Add(time.Hour)
+	_, err := introspector.Authenticate(context.Background(), "active")
+	assert.ErrorContains(t, err, "token has expired")
+	assert.Equal(t, int32(4), calls.Load())
+
+	introspector.sweep(now)
+	assert.Empty(t, introspector.cache)
+
+	// failures of the endpoint are not mistaken for inactive tokens
+	unauthorized := NewIntrospector(srv.URL, "coupon-service", "wrong", Policy{})
+	_, err = unauthorized.Authenticate(context.Background(), "active")
+	assert.Equal(t, pkg.EINTERNAL, pkg.ErrorCode(err))
+	assert.ErrorContains(t, err, "failed to introspect the token: status 401")
+}
+
+func TestTokenMiddleware_Introspection(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+	srv, _ := introspectionServer(t, map[string]map[string]any{
+		"admin":  {"active": true, "sub": "admin1", "exp": exp, "realm_access": map[string]any{"roles": []string{"admin"}}},
+		"scoped": {"active": true, "sub": "client1", "exp": exp, "scope": "checkout profile"},
+		"user":   {"active": true, "sub": "user1", "exp": exp, "realm_access": map[string]any{"roles": "user"}},
+		"client": {"active": true, "client_id": "checkout-app", "exp": exp, "scope": "checkout"},
+		"nobody": {"active": true, "exp": exp, "scope": "checkout"},
+	})
+	policy := &AccessPolicy{
+		Roles:  DefaultAccessPolicy().Roles,
+		Scopes: map[string][]Permission{"checkout": {PermCouponsCreate}},
+	}
+	tests := []struct {
+		token        string
+		expectedCode int
+		expectedBody string
+	}{
+		{token: "admin", expectedCode: http.StatusOK, expectedBody: "admin1 [admin] []"},
+		{token: "scoped", expectedCode: http.StatusOK, expectedBody: "client1 [] [checkout profile]"},
+		{token: "client", expectedCode: http.StatusOK, expectedBody: "client:checkout-app [] [checkout]"},
+		{token: "nobody", expectedCode: http.StatusUnauthorized, expectedBody: "token has no subject"},
+		{token: "user", expectedCode: http.StatusForbidden, expectedBody: "insufficient permissions, missing coupons:create"},
+		{token: "unknown", expectedCode: http.StatusUnauthorized, expectedBody: "token is not active"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	introspector := NewIntrospector(srv.URL, "coupon-service", "client-secret", Policy{})
+	r.GET("/", TokenMiddleware(introspector, WithRolesClaim("realm_access.roles")), policy.Require(PermCouponsCreate), func(c *gin.Context) {
+		c.String(http.StatusOK, "%v %v %v", c.GetString("user_id"), c.GetStringSlice("roles"), c.GetStringSlice("scopes"))
+	})
+	for _, tt := range tests {
+		t.Run(tt.token, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"coupon_service/pkg"
+)
+
+const (
+	// minRefetchInterval bounds how often tokens with an unknown kid trigger a refresh of the key set,
+	// so that forged kids cannot flood the identity provider.
+	minRefetchInterval = 10 * time.Second
+	// maxJWKSSize bounds the size of the fetched key set documents.
+	maxJWKSSize = 1 << 20
+)
+
+// KeySet is a JSON Web Key Set (RFC 7517) loaded from a file or a URL. Keys are cached and the set is refreshed
+// once stale, or when a token is signed with an unknown key, so that keys rotated by the identity provider are picked up.
+// Refreshes run outside the lock, one at a time, so that a slow identity provider does not hold up the known keys.
+type KeySet struct {
+	source  string
+	refresh time.Duration
+	client  *http.Client
+	now     func() time.Time
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	// refreshing is closed once the refresh in flight completes, nil when none is.
+	refreshing chan struct{}
+}
+
+// NewKeySet loads the key set from source, an http(s) URL or a file path, refreshed every refresh interval.
+func NewKeySet(source string, refresh time.Duration) (*KeySet, error) {
+	s := &KeySet{
+		source:  source,
+		refresh: refresh,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		now:     time.Now,
+	}
+	keys, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	s.keys = keys
+	s.fetchedAt = s.now()
+	s.attemptedAt = s.fetchedAt
+	return s, nil
+}
+
+// Key returns the public key identified by kid.
+func (s *KeySet) Key(kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	key, ok := s.keys[kid]
+	now := s.now()
+	stale := now.Sub(s.fetchedAt) >= s.refresh
+	if (stale || !ok) && s.refreshing == nil && now.Sub(s.attemptedAt) >= minRefetchInterval {
+		s.attemptedAt = now
+		s.refreshing = make(chan struct{})
+		go s.refreshKeys(now, s.refreshing)
+	}
+	refreshing := s.refreshing
+	s.mu.Unlock()
+
+	// known keys are served at once, even stale ones, while tokens signed with an unknown key wait for
+	// the refresh in flight, shared by every request
+	if !ok && refreshing != nil {
+		<-refreshing
+		s.mu.Lock()
+		key, ok = s.keys[kid]
+		s.mu.Unlock()
+	}
+	if !ok {
+		return nil, pkg.Errorf(pkg.EUNAUTHORIZED, fmt.Sprintf("unknown signing key %q", kid), nil)
+	}
+	return key, nil
+}
+
+// refreshKeys fetches the key set started at the given time and closes done once the keys are replaced.
+// The current keys are kept while the key set cannot be fetched.
+func (s *KeySet) refreshKeys(started time.Time, done chan struct{}) {
+	keys, err := s.load()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		log.Printf("failed to refresh the JWKS: %v", err)
+	} else {
+		s.keys = keys
+		s.fetchedAt = started
+	}
+	s.refreshing = nil
+	close(done)
+}
+
+// load fetches and parses the key set.
+func (s *KeySet) load() (map[string]crypto.PublicKey, error) {
+	data, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(data)
+}
+
+func (s *KeySet) read() ([]byte, error) {
+	if !strings.HasPrefix(s.source, "http://") && !strings.HasPrefix(s.source, "https://") {
+		data, err := os.ReadFile(strings.TrimPrefix(s.source, "file://"))
+		if err != nil {
+			return nil, pkg.Errorf(pkg.EINTERNAL, "failed to read the JWKS file", err)
+		}
+		return data, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.client.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.source, nil)
+	if err != nil {
+		return nil, pkg.Errorf(pkg.EINTERNAL, "invalid JWKS URL", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, pkg.Errorf(pkg.EINTERNAL, "failed to fetch the JWKS", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, pkg.Errorf(pkg.EINTERNAL, fmt.Sprintf("failed to fetch the JWKS: status %d", resp.StatusCode), nil)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxJWKSSize))
+	if err != nil {
+		return nil, pkg.Errorf(pkg.EINTERNAL, "failed to fetch the JWKS", err)
+	}
+	return data, nil
+}
+
+// jwk is a JSON Web Key, holding the members of RSA and EC public keys.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// parseJWKS returns the RSA and EC signature keys of the key set by kid. Other keys are ignored.
+func parseJWKS(data []byte) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, pkg.Errorf(pkg.EINTERNAL, "invalid JWKS document", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		var (
+			key crypto.PublicKey
+			err error
+		)
+		switch k.Kty {
+		case "RSA":
+			key, err = k.rsaKey()
+		case "EC":
+			key, err = k.ecKey()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, pkg.Errorf(pkg.EINTERNAL, fmt.Sprintf("invalid JWKS key %q", k.Kid), err)
+		}
+		keys[k.Kid] = key
+	}
+	return keys, nil
+}
+
+func (k jwk) rsaKey() (*rsa.PublicKey, error) {
+	n, err := decodeBigInt(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := decodeBigInt(k.E)
+	if err != nil {
+		return nil, err
+	}
+	if !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+		return nil, fmt.Errorf("invalid RSA exponent")
+	}
+	if n.BitLen() < 2048 {
+		return nil, fmt.Errorf("RSA keys must be at least 2048 bits long")
+	}
+	return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+}
+
+func (k jwk) ecKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+	x, err := decodeBigInt(k.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := decodeBigInt(k.Y)
+	if err != nil {
+		return nil, err
+	}
+	key := &ecdsa.PublicKey{Curve: curve, X: x, Y: y}
+	if _, err := key.ECDH(); err != nil {
+		return nil, fmt.Errorf("point is not on curve %s", k.Crv)
+	}
+	return key, nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("missing key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -1,7 +1,11 @@
 package auth
 
 import (
-	"net/http"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
 
 	"coupon_service/pkg"
 	"github.com/gin-gonic/gin"
@@ -12,33 +16,112 @@ const (
 	RoleUser  = "user"
 )
 
-func RequireRoles(requiredRoles ...string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Get roles from context
-		rolesInterface, exists := c.Get("roles")
-		if !exists {
-			c.AbortWithError(http.StatusForbidden, pkg.Errorf(pkg.EFORBIDDEN, "no roles found", nil))
-			return
+// Permission allows an operation of the API. Routes require permissions, which callers are granted through
+// their roles and OAuth scopes by an AccessPolicy.
+type Permission string
+
+const (
+	PermCouponsCreate Permission = "coupons:create"
+	PermCouponsRead   Permission = "coupons:read"
+	PermCouponsApply  Permission = "coupons:apply"
+	PermCouponsExport Permission = "coupons:export"
+	PermAuditRead     Permission = "audit:read"
+	PermAPIKeysManage Permission = "apikeys:manage"
+	PermTokensRevoke  Permission = "tokens:revoke"
+)
+
+// Permissions lists every known permission.
+var Permissions = []Permission{PermCouponsCreate, PermCouponsRead, PermCouponsApply, PermCouponsExport, PermAuditRead, PermAPIKeysManage, PermTokensRevoke}
+
+// ValidPermission reports whether perm is a known permission.
+func ValidPermission(perm string) bool {
+	return slices.Contains(Permissions, Permission(perm))
+}
+
+// AccessPolicy maps roles and OAuth scopes to the permissions they grant.
+type AccessPolicy struct {
+	Roles  map[string][]Permission `json:"roles"`
+	Scopes map[string][]Permission `json:"scopes"`
+}
+
+// DefaultAccessPolicy grants every permission to admins, and reading and applying coupons to users.
+func DefaultAccessPolicy() *AccessPolicy {
+	return &AccessPolicy{
+		Roles: map[string][]Permission{
+			RoleAdmin: Permissions,
+			RoleUser:  {PermCouponsRead, PermCouponsApply},
+		},
+	}
+}
+
+// LoadAccessPolicy reads an access policy from a JSON file such as
+//
+//	{"roles": {"admin": ["coupons:create", "audit:read"]}, "scopes": {"coupons.apply": ["coupons:apply"]}}
+func LoadAccessPolicy(path string) (*AccessPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, pkg.Errorf(pkg.EINTERNAL, "failed to read the access policy file", err)
+	}
+	p := &AccessPolicy{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, pkg.Errorf(pkg.EINTERNAL, "invalid access policy file", err)
+	}
+	if err := p.validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// validate rejects unknown permissions, most likely typos which would silently deny access.
+func (p *AccessPolicy) validate() error {
+	for kind, grants := range map[string]map[string][]Permission{"role": p.Roles, "scope": p.Scopes} {
+		for name, perms := range grants {
+			for _, perm := range perms {
+				if !ValidPermission(string(perm)) {
+					return pkg.Errorf(pkg.EINTERNAL, fmt.Sprintf("unknown permission %q granted to %s %q", perm, kind, name), nil)
+				}
+			}
 		}
+	}
+	return nil
+}
 
-		userRoles, ok := rolesInterface.([]string)
-		if !ok {
-			c.AbortWithError(http.StatusForbidden, pkg.Errorf(pkg.EFORBIDDEN, "invalid roles format", nil))
-			return
+// Allows reports whether the roles or the scopes grant the permission.
+func (p *AccessPolicy) Allows(roles, scopes []string, perm Permission) bool {
+	if p == nil {
+		return false
+	}
+	for _, r := range roles {
+		if slices.Contains(p.Roles[r], perm) {
+			return true
 		}
+	}
+	for _, s := range scopes {
+		if slices.Contains(p.Scopes[s], perm) {
+			return true
+		}
+	}
+	return false
+}
 
-		// Check if user has any of the required roles
-		hasRole := false
-		for _, requiredRole := range requiredRoles {
-			for _, userRole := range userRoles {
-				if userRole == requiredRole {
-					hasRole = true
-					break
-				}
+// Granted reports whether the caller of the request, authenticated by TokenMiddleware, has the permission,
+// granted by the policy to its roles and scopes or, for API keys, directly.
+func (p *AccessPolicy) Granted(c *gin.Context, perm Permission) bool {
+	return slices.Contains(c.GetStringSlice("permissions"), string(perm)) ||
+		p.Allows(c.GetStringSlice("roles"), c.GetStringSlice("scopes"), perm)
+}
+
+// Require lets through the requests whose caller, authenticated by TokenMiddleware, has all the permissions.
+func (p *AccessPolicy) Require(perms ...Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var missing []string
+		for _, perm := range perms {
+			if !p.Granted(c, perm) {
+				missing = append(missing, string(perm))
 			}
 		}
-		if !hasRole {
-			c.AbortWithError(http.StatusForbidden, pkg.Errorf(pkg.EFORBIDDEN, "insufficient permissions", nil))
+		if len(missing) > 0 {
+			abort(c, pkg.Errorf(pkg.EFORBIDDEN, "insufficient permissions, missing "+strings.Join(missing, ", "), nil))
 			return
 		}
 		c.Next()
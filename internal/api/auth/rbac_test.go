@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadAccessPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *AccessPolicy
+		wantErr string
+	}{
+		{
+			name:    "roles and scopes",
+			content: `{"roles": {"support": ["coupons:read"]}, "scopes": {"checkout": ["coupons:apply", "coupons:read"]}}`,
+			want: &AccessPolicy{
+				Roles:  map[string][]Permission{"support": {PermCouponsRead}},
+				Scopes: map[string][]Permission{"checkout": {PermCouponsApply, PermCouponsRead}},
+			},
+		},
+		{
+			name:    "unknown permission",
+			content: `{"roles": {"support": ["coupon:read"]}}`,
+			wantErr: `unknown permission \"coupon:read\" granted to role \"support\"`,
+		},
+		{
+			name:    "not JSON",
+			content: `roles: {}`,
+			wantErr: "invalid access policy file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.json")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+
+			got, err := LoadAccessPolicy(path)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	_, err := LoadAccessPolicy(filepath.Join(t.TempDir(), "missing.json"))
+	assert.ErrorContains(t, err, "failed to read the access policy file")
+}
+
+func TestAccessPolicy_Require(t *testing.T) {
+	policy := &AccessPolicy{
+		Roles:  map[string][]Permission{RoleAdmin: Permissions, RoleUser: {PermCouponsRead, PermCouponsApply}},
+		Scopes: map[string][]Permission{"checkout": {PermCouponsApply}},
+	}
+	tests := []struct {
+		name         string
+		roles        []string
+		scopes       []string
+		perms        []Permission
+		expectedCode int
+		expectedBody string
+	}{
+		{name: "granted by role", roles: []string{RoleUser}, perms: []Permission{PermCouponsApply}, expectedCode: http.StatusOK},
+		{name: "granted by scope", scopes: []string{"checkout"}, perms: []Permission{PermCouponsApply}, expectedCode: http.StatusOK},
+		{name: "all permissions of admins", roles: []string{RoleAdmin}, perms: []Permission{PermAuditRead, PermCouponsExport}, expectedCode: http.StatusOK},
+		{
+			name:         "one permission missing",
+			roles:        []string{RoleUser},
+			perms:        []Permission{PermCouponsRead, PermCouponsCreate},
+			expectedCode: http.StatusForbidden,
+			expectedBody: "insufficient permissions, missing coupons:create",
+		},
+		{
+			name:         "unknown role and scope",
+			roles:        []string{"guest"},
+			scopes:       []string{"profile"},
+			perms:        []Permission{PermCouponsRead},
+			expectedCode: http.StatusForbidden,
+			expectedBody: "insufficient permissions, missing coupons:read",
+		},
+		{name: "not authenticated", perms: []Permission{PermCouponsRead}, expectedCode: http.StatusForbidden},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				if tt.roles != nil {
+					c.Set("roles", tt.roles)
+				}
+				if tt.scopes != nil {
+					c.Set("scopes", tt.scopes)
+				}
+			})
+			r.GET("/", policy.Require(tt.perms...), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestClaims_Scopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		claims  string
+		want    []string
+		wantErr bool
+	}{
+		{name: "scope string", claims: `{"scope": "checkout  profile"}`, want: []string{"checkout", "profile"}},
+		{name: "scp array", claims: `{"scp": ["checkout", "profile"]}`, want: []string{"checkout", "profile"}},
+		{name: "scope over scp", claims: `{"scope": "checkout", "scp": ["profile"]}`, want: []string{"checkout"}},
+		{name: "no scopes", claims: `{}`},
+		{name: "scope of another type", claims: `{"scope": 1}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := &Claims{}
+			require.NoError(t, json.Unmarshal([]byte(tt.claims), claims))
+
+			got, err := claims.Scopes()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
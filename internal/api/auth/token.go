@@ -1,51 +1,436 @@
 package auth
 
 import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"errors"
 	"fmt"
-	"net/http"
+	"log"
+	"slices"
 	"strings"
+	"time"
 
+	"coupon_service/internal/entity"
 	"coupon_service/pkg"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-func TokenMiddleware(JWTSecret string) gin.HandlerFunc {
+const (
+	// APIKeyHeader is the header machine to machine callers present their API key in.
+	APIKeyHeader = "X-API-Key"
+	// APIKeyUserPrefix prefixes the ID of the API key of a caller to make up its user_id.
+	APIKeyUserPrefix = "apikey:"
+)
+
+// TokenAuthenticator authenticates bearer tokens and returns their claims, rejecting invalid tokens
+// with a pkg.EUNAUTHORIZED error telling why.
+type TokenAuthenticator interface {
+	Authenticate(ctx context.Context, token string) (*Claims, error)
+}
+
+// Policy lists the tokens accepted by a Verifier or an Introspector.
+type Policy struct {
+	// Algorithms allowed to sign tokens, HS256 with a secret and RS256 and ES256 with a key set by default.
+	Algorithms []string
+	// Issuers and Audiences accepted in the iss and aud claims. Any issuer and audience is accepted when empty.
+	Issuers   []string
+	Audiences []string
+	// Leeway tolerates clock skew with the issuer when checking the exp, nbf and iat claims.
+	Leeway time.Duration
+}
+
+// Verifier checks the signature of tokens, with a shared secret for HMAC algorithms and with the keys of a key set,
+// selected by the kid of the token header, for RSA and ECDSA algorithms. Tokens signed with any other algorithm
+// than the allowed ones are rejected, whatever their header claims, as well as tokens without expiry or from
+// issuers or for audiences the policy does not accept.
+type Verifier struct {
+	secret []byte
+	keySet *KeySet
+	policy Policy
+	now    func() time.Time
+}
+
+// NewVerifier returns a verifier of the tokens signed with the secret or with the keys of keySet, either being optional.
+func NewVerifier(secret []byte, keySet *KeySet, policy Policy) (*Verifier, error) {
+	algorithms := slices.Clone(policy.Algorithms)
+	if len(algorithms) == 0 {
+		if len(secret) > 0 {
+			algorithms = append(algorithms, "HS256")
+		}
+		if keySet != nil {
+			algorithms = append(algorithms, "RS256", "ES256")
+		}
+	}
+	if len(algorithms) == 0 {
+		return nil, pkg.Errorf(pkg.EINTERNAL, "a JWT secret or a JWKS is required to verify tokens", nil)
+	}
+
+	for _, alg := range algorithms {
+		switch jwt.GetSigningMethod(alg).(type) {
+		case *jwt.SigningMethodHMAC:
+			if len(secret) == 0 {
+				return nil, pkg.Errorf(pkg.EINTERNAL, fmt.Sprintf("algorithm %s requires a JWT secret", alg), nil)
+			}
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA:
+			if keySet == nil {
+				return nil, pkg.Errorf(pkg.EINTERNAL, fmt.Sprintf("algorithm %s requires a JWKS", alg), nil)
+			}
+		default:
+			return nil, pkg.Errorf(pkg.EINTERNAL, fmt.Sprintf("unsupported JWT algorithm %q", alg), nil)
+		}
+	}
+	policy.Algorithms = algorithms
+	return &Verifier{secret: secret, keySet: keySet, policy: policy, now: time.Now}, nil
+}
+
+// NewHMACVerifier returns a verifier of the tokens signed with the secret using HS256, from any issuer and for any audience.
+func NewHMACVerifier(secret []byte) *Verifier {
+	return &Verifier{secret: secret, policy: Policy{Algorithms: []string{"HS256"}}, now: time.Now}
+}
+
+// Parse verifies the signature and the claims of the token and returns it.
+// Rejected tokens are reported with a pkg.EUNAUTHORIZED error telling why.
+func (v *Verifier) Parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	token, err := jwt.ParseWithClaims(tokenString, claims, v.key,
+		jwt.WithValidMethods(v.policy.Algorithms),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(v.policy.Leeway),
+		jwt.WithTimeFunc(v.now),
+	)
+	if err != nil {
+		return nil, tokenError(err)
+	}
+
+	if err := v.policy.accepts(claims); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// Authenticate verifies the token and returns its claims.
+func (v *Verifier) Authenticate(_ context.Context, token string) (*Claims, error) {
+	claims := &Claims{}
+	if _, err := v.Parse(token, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// accepts checks the issuer and the audiences of the claims.
+func (p Policy) accepts(claims jwt.Claims) error {
+	if len(p.Issuers) > 0 {
+		iss, err := claims.GetIssuer()
+		if err != nil || !slices.Contains(p.Issuers, iss) {
+			return pkg.Errorf(pkg.EUNAUTHORIZED, "token issuer is not accepted", err)
+		}
+	}
+	if len(p.Audiences) > 0 {
+		aud, err := claims.GetAudience()
+		if err != nil || !slices.ContainsFunc(aud, func(a string) bool { return slices.Contains(p.Audiences, a) }) {
+			return pkg.Errorf(pkg.EUNAUTHORIZED, "token audience is not accepted", err)
+		}
+	}
+	return nil
+}
+
+// tokenError maps the errors of the JWT parser to errors telling why the token was rejected.
+func tokenError(err error) error {
+	var msg string
+	switch {
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		msg = "malformed token"
+	case errors.Is(err, jwt.ErrTokenUnverifiable), errors.Is(err, jwt.ErrTokenSignatureInvalid):
+		msg = "invalid token signature"
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		msg = "token has no expiry"
+	case errors.Is(err, jwt.ErrTokenExpired):
+		msg = "token has expired"
+	case errors.Is(err, jwt.ErrTokenNotValidYet):
+		msg = "token is not valid yet"
+	case errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		msg = "token is issued in the future"
+	default:
+		msg = "invalid token"
+	}
+	return pkg.Errorf(pkg.EUNAUTHORIZED, msg, err)
+}
+
+// key returns the key verifying the token, whose algorithm has already been checked to be allowed.
+func (v *Verifier) key(token *jwt.Token) (any, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		return v.secret, nil
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		key, err := v.publicKey(token)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := key.(*rsa.PublicKey); !ok {
+			return nil, fmt.Errorf("key of token is not an RSA key")
+		}
+		return key, nil
+	case *jwt.SigningMethodECDSA:
+		key, err := v.publicKey(token)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := key.(*ecdsa.PublicKey); !ok {
+			return nil, fmt.Errorf("key of token is not an EC key")
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+}
+
+func (v *Verifier) publicKey(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	return v.keySet.Key(kid)
+}
+
+// abort stops the request with the status of the error, reported in the body.
+func abort(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.AbortWithStatusJSON(pkg.ErrorStatusCode(pkg.ErrorCode(err)), err)
+}
+
+// APIKeyAuthenticator authenticates the API keys of machine to machine callers.
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(ctx context.Context, key string) (entity.APIKey, error)
+}
+
+// RevocationChecker tells the bearer tokens of a tenant revoked before their expiry.
+type RevocationChecker interface {
+	Revoked(ctx context.Context, tenantID, jti, subject string, issuedAt time.Time) (bool, error)
+}
+
+type middlewareConfig struct {
+	rolesClaim   string
+	apiKeys      APIKeyAuthenticator
+	revocations  RevocationChecker
+	tenantClaim  string
+	tenantHeader string
+	devIdentity  *DevIdentity
+	certificates CertificateSubjects
+}
+
+// MiddlewareOption customizes the middleware created by TokenMiddleware.
+type MiddlewareOption func(*middlewareConfig)
+
+// WithRolesClaim reads the roles from the dot separated path of the token claims, DefaultRolesClaim by default.
+func WithRolesClaim(path string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		if path != "" {
+			c.rolesClaim = path
+		}
+	}
+}
+
+// WithAPIKeys lets machine to machine callers present an API key in the X-API-Key header instead of a bearer token,
+// granted the permissions of its scopes. API keys are rejected by default.
+func WithAPIKeys(apiKeys APIKeyAuthenticator) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.apiKeys = apiKeys
+	}
+}
+
+// WithRevocations rejects the tokens revoked before their expiry. Tokens are not checked by default.
+func WithRevocations(revocations RevocationChecker) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.revocations = revocations
+	}
+}
+
+// WithTenant sets the tenant of the callers, read from the dot separated path claim of their token or, if claim is
+// empty, from the header. Tokens without the claim are rejected, as well as callers presenting a header that does
+// not match the tenant of their token or API key. With the header alone, the tenant is chosen by the caller,
+// which is only acceptable in development and tests. Every caller belongs to the default tenant, with an empty ID,
+// by default.
+func WithTenant(claim, header string) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.tenantClaim = claim
+		c.tenantHeader = header
+	}
+}
+
+// DevIdentity is the caller of the requests without credentials in the development auth mode.
+type DevIdentity struct {
+	UserID   string
+	Roles    []string
+	TenantID string
+}
+
+// WithDevIdentity authenticates the requests without credentials as the identity, instead of rejecting them,
+// so that the service can be tried without an identity provider. Requests with a bearer token or an API key are
+// authenticated as usual. For development and tests only: anyone reaching the service gets the identity's roles.
+func WithDevIdentity(identity DevIdentity) MiddlewareOption {
+	return func(c *middlewareConfig) {
+		c.devIdentity = &identity
+	}
+}
+
+// TokenMiddleware authenticates the requests with their bearer token, verified by the authenticator, setting the user_id,
+// roles, scopes and tenant_id of the caller. API keys, client certificates and the development identity are accepted
+// as well when enabled by the options.
+func TokenMiddleware(authenticator TokenAuthenticator, opts ...MiddlewareOption) gin.HandlerFunc {
+	cfg := middlewareConfig{rolesClaim: DefaultRolesClaim}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
 	return func(c *gin.Context) {
+		if key := c.GetHeader(APIKeyHeader); key != "" {
+			authenticateAPIKey(c, cfg, key)
+			return
+		}
+
 		authHeader := c.GetHeader("Authorization")
+		if cert := clientCertificate(c); authHeader == "" && cert != nil && cfg.certificates != nil {
+			authenticateCertificate(c, cfg, cert)
+			return
+		}
+		if authHeader == "" && cfg.devIdentity != nil {
+			authenticateDevIdentity(c, cfg)
+			return
+		}
 		if authHeader == "" {
-			c.AbortWithError(http.StatusUnauthorized, pkg.Errorf(pkg.EUNAUTHORIZED, "missing authorization header", nil))
+			abort(c, pkg.Errorf(pkg.EUNAUTHORIZED, "missing authorization header", nil))
 			return
 		}
 
 		bearerToken := strings.Split(authHeader, "Bearer ")
 		if len(bearerToken) != 2 {
-			c.AbortWithError(http.StatusUnauthorized, pkg.Errorf(pkg.EUNAUTHORIZED, "invalid token format", nil))
+			abort(c, pkg.Errorf(pkg.EUNAUTHORIZED, "invalid token format", nil))
 			return
 		}
 
-		token, err := jwt.ParseWithClaims(bearerToken[1], &jwt.MapClaims{}, func(token *jwt.Token) (any, error) {
-			return []byte(JWTSecret), nil
-		})
+		claims, err := authenticator.Authenticate(c.Request.Context(), bearerToken[1])
 		if err != nil {
-			c.AbortWithError(http.StatusUnauthorized, pkg.Errorf(pkg.EUNAUTHORIZED, "invalid token", err))
+			abort(c, err)
 			return
 		}
-
-		if claims, ok := token.Claims.(*jwt.MapClaims); ok && token.Valid {
-			r := (*claims)["roles"]
-			roles := make([]string, len(r.([]interface{})))
-			for i, v := range r.([]interface{}) {
-				roles[i] = fmt.Sprint(v)
+		if claims.Subject == "" {
+			abort(c, pkg.Errorf(pkg.EUNAUTHORIZED, "token has no subject", nil))
+			return
+		}
+		roles, err := claims.Roles(cfg.rolesClaim)
+		if err != nil {
+			abort(c, err)
+			return
+		}
+		scopes, err := claims.Scopes()
+		if err != nil {
+			abort(c, err)
+			return
+		}
+		tenantID, err := cfg.tenant(c, claims)
+		if err != nil {
+			abort(c, err)
+			return
+		}
+		if cfg.revocations != nil {
+			if err := checkRevocation(c.Request.Context(), cfg.revocations, tenantID, claims); err != nil {
+				abort(c, err)
+				return
 			}
-			c.Set("roles", roles)
+		}
 
-			c.Set("user_id", (*claims)["sub"])
+		c.Set("roles", roles)
+		c.Set("scopes", scopes)
+		c.Set("user_id", claims.Subject)
+		c.Set("tenant_id", tenantID)
+		c.Next()
+	}
+}
 
-			c.Next()
-		} else {
-			c.AbortWithError(http.StatusUnauthorized, pkg.Errorf(pkg.EUNAUTHORIZED, "invalid token claims", nil))
+// tenant returns the tenant of the caller authenticated with the claims.
+func (cfg middlewareConfig) tenant(c *gin.Context, claims *Claims) (string, error) {
+	if cfg.tenantClaim == "" {
+		if cfg.tenantHeader == "" {
+			return "", nil
 		}
+		return c.GetHeader(cfg.tenantHeader), nil
+	}
+
+	tenantID, err := claims.Tenant(cfg.tenantClaim)
+	if err != nil {
+		return "", err
+	}
+	if tenantID == "" {
+		return "", pkg.Errorf(pkg.EUNAUTHORIZED, "token has no tenant", nil)
+	}
+	return tenantID, cfg.matchTenantHeader(c, tenantID)
+}
+
+// matchTenantHeader rejects the callers asking for another tenant than their own in the tenant header.
+func (cfg middlewareConfig) matchTenantHeader(c *gin.Context, tenantID string) error {
+	if cfg.tenantHeader == "" {
+		return nil
+	}
+	if header := c.GetHeader(cfg.tenantHeader); header != "" && header != tenantID {
+		return pkg.Errorf(pkg.EFORBIDDEN, "tenant does not match the credentials", nil)
+	}
+	return nil
+}
+
+// authenticateAPIKey authenticates the caller with an API key, which belongs to a single tenant.
+func authenticateAPIKey(c *gin.Context, cfg middlewareConfig, presented string) {
+	if cfg.apiKeys == nil {
+		abort(c, pkg.Errorf(pkg.EUNAUTHORIZED, "API keys are not accepted", nil))
+		return
+	}
+	key, err := cfg.apiKeys.AuthenticateAPIKey(c.Request.Context(), presented)
+	if err != nil {
+		abort(c, err)
+		return
+	}
+	if err := cfg.matchTenantHeader(c, key.TenantID); err != nil {
+		abort(c, err)
+		return
+	}
+
+	c.Set("user_id", APIKeyUserPrefix+key.ID)
+	c.Set("roles", []string(nil))
+	c.Set("permissions", key.Scopes)
+	c.Set("tenant_id", key.TenantID)
+	c.Next()
+}
+
+// authenticateDevIdentity authenticates the caller as the development identity. With the tenant header alone,
+// the tenant can be chosen per request.
+func authenticateDevIdentity(c *gin.Context, cfg middlewareConfig) {
+	identity := cfg.devIdentity
+	tenantID := identity.TenantID
+	if header := c.GetHeader(cfg.tenantHeader); cfg.tenantClaim == "" && header != "" {
+		tenantID = header
+	} else if err := cfg.matchTenantHeader(c, tenantID); err != nil {
+		abort(c, err)
+		return
+	}
+
+	c.Set("roles", identity.Roles)
+	c.Set("scopes", []string(nil))
+	c.Set("user_id", identity.UserID)
+	c.Set("tenant_id", tenantID)
+	c.Next()
+}
+
+// checkRevocation rejects revoked tokens. Failures to check are rejected as well, so that revoked tokens
+// are never let through.
+func checkRevocation(ctx context.Context, revocations RevocationChecker, tenantID string, claims *Claims) error {
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+	revoked, err := revocations.Revoked(ctx, tenantID, claims.ID, claims.Subject, issuedAt)
+	if err != nil {
+		log.Printf("failed to check the revocation of a token: %v", err)
+		return pkg.Errorf(pkg.EINTERNAL, "failed to check the token revocation", err)
+	}
+	if revoked {
+		return pkg.Errorf(pkg.EUNAUTHORIZED, "token has been revoked", nil)
 	}
+	return nil
 }
@@ -0,0 +1,780 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"coupon_service/internal/entity"
+	"coupon_service/pkg"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"use": "sig",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "EC",
+		"kid": kid,
+		"crv": key.Curve.Params().Name,
+		"x":   base64.RawURLEncoding.EncodeToString(key.X.Bytes()),
+		"y":   base64.RawURLEncoding.EncodeToString(key.Y.Bytes()),
+	}
+}
+
+func jwksDocument(t *testing.T, keys ...map[string]string) []byte {
+	data, err := json.Marshal(map[string]any{"keys": keys})
+	require.NoError(t, err)
+	return data
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key any) string {
+	return signClaims(t, method, kid, key, jwt.MapClaims{
+		"sub":   "user123",
+		"roles": []string{"admin"},
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	})
+}
+
+func signClaims(t *testing.T, method jwt.SigningMethod, kid string, key any, claims jwt.Claims) string {
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestVerifier(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherRSAKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	secret := []byte("shared-secret-of-at-least-32-bytes!")
+
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Write(jwksDocument(t, rsaJWK("rsa-1", &rsaKey.PublicKey), ecJWK("ec-1", &ecKey.PublicKey)))
+	}))
+	defer srv.Close()
+
+	keySet, err := NewKeySet(srv.URL, time.Hour)
+	require.NoError(t, err)
+	verifier, err := NewVerifier(secret, keySet, Policy{Algorithms: []string{"HS256", "RS256", "ES256"}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{name: "RS256 selected by kid", token: sign(t, jwt.SigningMethodRS256, "rsa-1", rsaKey)},
+		{name: "ES256 selected by kid", token: sign(t, jwt.SigningMethodES256, "ec-1", ecKey)},
+		{name: "HS256 with the secret", token: sign(t, jwt.SigningMethodHS256, "", secret)},
+		{name: "signed with another key", token: sign(t, jwt.SigningMethodRS256, "rsa-1", otherRSAKey), wantErr: true},
+		{name: "kid of a key of another type", token: sign(t, jwt.SigningMethodRS256, "ec-1", rsaKey), wantErr: true},
+		{name: "unknown kid", token: sign(t, jwt.SigningMethodRS256, "rsa-2", rsaKey), wantErr: true},
+		{name: "algorithm not allowed", token: sign(t, jwt.SigningMethodRS512, "rsa-1", rsaKey), wantErr: true},
+		{name: "none algorithm", token: sign(t, jwt.SigningMethodNone, "", jwt.UnsafeAllowNoneSignatureType), wantErr: true},
+		{
+			// the public key used as an HMAC secret, the classic algorithm confusion attack
+			name:    "HS256 with the RSA public key",
+			token:   sign(t, jwt.SigningMethodHS256, "rsa-1", []byte(rsaJWK("rsa-1", &rsaKey.PublicKey)["n"])),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := verifier.Parse(tt.token, &jwt.MapClaims{})
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.True(t, token.Valid)
+		})
+	}
+
+	// unknown kids do not refresh the key set more often than minRefetchInterval
+	assert.Equal(t, int32(1), fetches.Load())
+}
+
+func TestVerifier_PinnedAlgorithms(t *testing.T) {
+	secret := []byte("shared-secret-of-at-least-32-bytes!")
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, jwksDocument(t, ecJWK("ec-1", &ecKey.PublicKey)), 0o600))
+	keySet, err := NewKeySet(path, time.Hour)
+	require.NoError(t, err)
+
+	// without a secret, HMAC tokens are rejected even if their header claims HS256
+	verifier, err := NewVerifier(nil, keySet, Policy{})
+	require.NoError(t, err)
+	_, err = verifier.Parse(sign(t, jwt.SigningMethodHS256, "", secret), &jwt.MapClaims{})
+	assert.Error(t, err)
+	_, err = verifier.Parse(sign(t, jwt.SigningMethodES256, "ec-1", ecKey), &jwt.MapClaims{})
+	assert.NoError(t, err)
+
+	_, err = NewVerifier(nil, keySet, Policy{Algorithms: []string{"HS256"}})
+	assert.EqualError(t, err, `{"code":"internal","message":"algorithm HS256 requires a JWT secret"}`)
+	_, err = NewVerifier(secret, nil, Policy{Algorithms: []string{"RS256"}})
+	assert.EqualError(t, err, `{"code":"internal","message":"algorithm RS256 requires a JWKS"}`)
+	_, err = NewVerifier(secret, nil, Policy{Algorithms: []string{"none"}})
+	assert.EqualError(t, err, `{"code":"internal","message":"unsupported JWT algorithm \"none\""}`)
+	_, err = NewVerifier(nil, nil, Policy{})
+	assert.Error(t, err)
+}
+
+func TestVerifier_Policy(t *testing.T) {
+	secret := []byte("shared-secret-of-at-least-32-bytes!")
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	verifier, err := NewVerifier(secret, nil, Policy{
+		Issuers:   []string{"https://idp.example.com", "https://idp2.example.com"},
+		Audiences: []string{"coupon-service"},
+		Leeway:    30 * time.Second,
+	})
+	require.NoError(t, err)
+	verifier.now = func() time.Time { return now }
+
+	valid := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"sub": "user123",
+			"iss": "https://idp.example.com",
+			"aud": []string{"other-service", "coupon-service"},
+			"iat": now.Unix(),
+			"exp": now.Add(time.Hour).Unix(),
+		}
+	}
+	tests := []struct {
+		name    string
+		edit    func(jwt.MapClaims)
+		wantErr string
+	}{
+		{name: "valid", edit: func(jwt.MapClaims) {}},
+		{name: "second issuer", edit: func(c jwt.MapClaims) { c["iss"] = "https://idp2.example.com" }},
+		{name: "audience as a string", edit: func(c jwt.MapClaims) { c["aud"] = "coupon-service" }},
+		{name: "expired within leeway", edit: func(c jwt.MapClaims) { c["exp"] = now.Add(-20 * time.Second).Unix() }},
+		{name: "expired", edit: func(c jwt.MapClaims) { c["exp"] = now.Add(-time.Minute).Unix() }, wantErr: "token has expired"},
+		{name: "no expiry", edit: func(c jwt.MapClaims) { delete(c, "exp") }, wantErr: "token has no expiry"},
+		{name: "not valid yet", edit: func(c jwt.MapClaims) { c["nbf"] = now.Add(time.Minute).Unix() }, wantErr: "token is not valid yet"},
+		{name: "issued in the future", edit: func(c jwt.MapClaims) { c["iat"] = now.Add(time.Minute).Unix() }, wantErr: "token is issued in the future"},
+		{name: "other issuer", edit: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }, wantErr: "token issuer is not accepted"},
+		{name: "no issuer", edit: func(c jwt.MapClaims) { delete(c, "iss") }, wantErr: "token issuer is not accepted"},
+		{name: "other audience", edit: func(c jwt.MapClaims) { c["aud"] = "billing-service" }, wantErr: "token audience is not accepted"},
+		{name: "no audience", edit: func(c jwt.MapClaims) { delete(c, "aud") }, wantErr: "token audience is not accepted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := valid()
+			tt.edit(claims)
+			signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+			require.NoError(t, err)
+
+			_, err = verifier.Parse(signed, &jwt.MapClaims{})
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Equal(t, pkg.EUNAUTHORIZED, pkg.ErrorCode(err))
+			assert.Equal(t, tt.wantErr, pkg.ErrorMessage(err))
+		})
+	}
+
+	_, err = verifier.Parse("not.a.token", &jwt.MapClaims{})
+	assert.Equal(t, "malformed token", pkg.ErrorMessage(err))
+	_, err = verifier.Parse(sign(t, jwt.SigningMethodHS256, "", []byte("another-secret")), &jwt.MapClaims{})
+	assert.Equal(t, "invalid token signature", pkg.ErrorMessage(err))
+}
+
+func TestKeySet_Refresh(t *testing.T) {
+	first, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	second, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var rotated, failing atomic.Bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case failing.Load():
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case rotated.Load():
+			w.Write(jwksDocument(t, rsaJWK("k2", &second.PublicKey)))
+		default:
+			w.Write(jwksDocument(t, rsaJWK("k1", &first.PublicKey)))
+		}
+	}))
+	defer srv.Close()
+
+	keySet, err := NewKeySet(srv.URL, time.Hour)
+	require.NoError(t, err)
+	now := keySet.fetchedAt
+	keySet.now = func() time.Time { return now }
+
+	_, err = keySet.Key("k1")
+	assert.NoError(t, err)
+
+	// a rotated key is fetched when a token uses its kid
+	rotated.Store(true)
+	_, err = keySet.Key("k2")
+	assert.Error(t, err, "refreshed too early")
+	now = now.Add(minRefetchInterval)
+	_, err = keySet.Key("k2")
+	assert.NoError(t, err)
+	_, err = keySet.Key("k1")
+	assert.Error(t, err)
+
+	// stale keys are kept while the key set cannot be fetched
+	failing.Store(true)
+	now = now.Add(2 * time.Hour)
+	_, err = keySet.Key("k2")
+	assert.NoError(t, err)
+
+	_, err = NewKeySet(srv.URL, time.Hour)
+	assert.Error(t, err)
+}
+
+func TestKeySet_ConcurrentRefresh(t *testing.T) {
+	first, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	second, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var requests atomic.Int32
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Write(jwksDocument(t, rsaJWK("k1", &first.PublicKey)))
+			return
+		}
+		<-release
+		w.Write(jwksDocument(t, rsaJWK("k1", &first.PublicKey), rsaJWK("k2", &second.PublicKey)))
+	}))
+	defer srv.Close()
+
+	keySet, err := NewKeySet(srv.URL, time.Hour)
+	require.NoError(t, err)
+	now := keySet.fetchedAt.Add(2 * time.Hour)
+	keySet.now = func() time.Time { return now }
+
+	// the stale key is served while the key set is refreshed
+	_, err = keySet.Key("k1")
+	assert.NoError(t, err)
+
+	// tokens signed with the rotated key wait for the same refresh
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = keySet.Key("k2")
+		}()
+	}
+	close(release)
+	wg.Wait()
+	for _, err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(2), requests.Load())
+}
+
+func TestParseJWKS(t *testing.T) {
+	smallKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	offCurve := ecJWK("ec-1", &ecKey.PublicKey)
+	offCurve["y"] = offCurve["x"]
+
+	tests := []struct {
+		name     string
+		document []byte
+		wantKeys int
+		wantErr  bool
+	}{
+		{name: "encryption and unknown keys are ignored", document: []byte(`{"keys":[{"kty":"RSA","use":"enc"},{"kty":"oct","k":"c2VjcmV0"}]}`)},
+		{name: "RSA key too small", document: jwksDocument(t, rsaJWK("small", &smallKey.PublicKey)), wantErr: true},
+		{name: "EC point off curve", document: jwksDocument(t, offCurve), wantErr: true},
+		{name: "unsupported curve", document: []byte(`{"keys":[{"kty":"EC","crv":"P-192","x":"AQ","y":"AQ"}]}`), wantErr: true},
+		{name: "not JSON", document: []byte(`keys`), wantErr: true},
+		{name: "EC key", document: jwksDocument(t, ecJWK("ec-1", &ecKey.PublicKey)), wantKeys: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys, err := parseJWKS(tt.document)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Len(t, keys, tt.wantKeys)
+		})
+	}
+}
+
+func TestTokenMiddleware(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(jwksDocument(t, rsaJWK("rsa-1", &rsaKey.PublicKey)))
+	}))
+	defer srv.Close()
+	keySet, err := NewKeySet(srv.URL, time.Hour)
+	require.NoError(t, err)
+	verifier, err := NewVerifier(nil, keySet, Policy{})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		rolesClaim   string
+		claims       jwt.MapClaims
+		token        string
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "roles as an array",
+			claims:       jwt.MapClaims{"sub": "user123", "roles": []string{"admin", "user"}},
+			expectedCode: http.StatusOK,
+			expectedBody: "user123 [admin user]",
+		},
+		{
+			name:         "roles as a space delimited string",
+			claims:       jwt.MapClaims{"sub": "user123", "roles": "admin user"},
+			expectedCode: http.StatusOK,
+			expectedBody: "user123 [admin user]",
+		},
+		{
+			name:         "roles at a nested path",
+			rolesClaim:   "realm_access.roles",
+			claims:       jwt.MapClaims{"sub": "user123", "realm_access": map[string]any{"roles": []string{"admin"}}},
+			expectedCode: http.StatusOK,
+			expectedBody: "user123 [admin]",
+		},
+		{
+			name:         "no roles is forbidden",
+			claims:       jwt.MapClaims{"sub": "user123"},
+			expectedCode: http.StatusForbidden,
+			expectedBody: "insufficient permissions",
+		},
+		{
+			name:         "nested path missing",
+			rolesClaim:   "realm_access.roles",
+			claims:       jwt.MapClaims{"sub": "user123", "realm_access": "admin"},
+			expectedCode: http.StatusForbidden,
+			expectedBody: "insufficient permissions",
+		},
+		{
+			name:         "roles of another type",
+			claims:       jwt.MapClaims{"sub": "user123", "roles": 42},
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: "roles claim must be an array or a space delimited string",
+		},
+		{
+			name:         "roles with a non string",
+			claims:       jwt.MapClaims{"sub": "user123", "roles": []any{"admin", 1}},
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: "roles claim must only contain strings",
+		},
+		{
+			name:         "no subject",
+			claims:       jwt.MapClaims{"roles": []string{"admin"}},
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: "token has no subject",
+		},
+		{
+			name:         "subject of another type",
+			claims:       jwt.MapClaims{"sub": 123, "roles": []string{"admin"}},
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: "malformed token",
+		},
+		{
+			name:         "signed with a secret",
+			token:        sign(t, jwt.SigningMethodHS256, "rsa-1", []byte("secret")),
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: "invalid token signature",
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/", TokenMiddleware(verifier, WithRolesClaim(tt.rolesClaim)), DefaultAccessPolicy().Require(PermCouponsCreate), func(c *gin.Context) {
+				c.String(http.StatusOK, "%v %v", c.GetString("user_id"), c.GetStringSlice("roles"))
+			})
+
+			token := tt.token
+			if token == "" {
+				tt.claims["exp"] = time.Now().Add(time.Hour).Unix()
+				token = signClaims(t, jwt.SigningMethodRS256, "rsa-1", rsaKey, tt.claims)
+			}
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+token)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+type apiKeysStub map[string]entity.APIKey
+
+func (s apiKeysStub) AuthenticateAPIKey(_ context.Context, key string) (entity.APIKey, error) {
+	k, ok := s[key]
+	if !ok {
+		return entity.APIKey{}, pkg.Errorf(pkg.EUNAUTHORIZED, "invalid API key", nil)
+	}
+	return k, nil
+}
+
+func TestTokenMiddleware_APIKeys(t *testing.T) {
+	apiKeys := apiKeysStub{"cs_key1.secret": {ID: "key1", Scopes: []string{string(PermCouponsApply)}}}
+	tests := []struct {
+		name         string
+		apiKeys      APIKeyAuthenticator
+		key          string
+		perm         Permission
+		expectedCode int
+		expectedBody string
+	}{
+		{name: "granted by its scopes", apiKeys: apiKeys, key: "cs_key1.secret", perm: PermCouponsApply, expectedCode: http.StatusOK, expectedBody: "apikey:key1 []"},
+		{
+			name:         "permission not granted",
+			apiKeys:      apiKeys,
+			key:          "cs_key1.secret",
+			perm:         PermCouponsCreate,
+			expectedCode: http.StatusForbidden,
+			expectedBody: "insufficient permissions, missing coupons:create",
+		},
+		{name: "invalid key", apiKeys: apiKeys, key: "cs_key1.wrong", perm: PermCouponsApply, expectedCode: http.StatusUnauthorized, expectedBody: "invalid API key"},
+		{name: "API keys not accepted", key: "cs_key1.secret", perm: PermCouponsApply, expectedCode: http.StatusUnauthorized, expectedBody: "API keys are not accepted"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/", TokenMiddleware(NewHMACVerifier([]byte("secret")), WithAPIKeys(tt.apiKeys)), DefaultAccessPolicy().Require(tt.perm), func(c *gin.Context) {
+				c.String(http.StatusOK, "%v %v", c.GetString("user_id"), c.GetStringSlice("roles"))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(APIKeyHeader, tt.key)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+type revocationsStub struct {
+	revoked map[string]bool
+	err     error
+}
+
+func (s revocationsStub) Revoked(_ context.Context, tenantID, jti, subject string, issuedAt time.Time) (bool, error) {
+	return s.revoked[tenantID+"/"+jti] || s.revoked[tenantID+"/"+subject] && issuedAt.IsZero(), s.err
+}
+
+func TestTokenMiddleware_Revocations(t *testing.T) {
+	secret := []byte("shared-secret-of-at-least-32-bytes!")
+	revocations := revocationsStub{revoked: map[string]bool{"acme/token-1": true, "acme/user2": true}}
+	tests := []struct {
+		name         string
+		revocations  RevocationChecker
+		claims       jwt.MapClaims
+		expectedCode int
+		expectedBody string
+	}{
+		{name: "not revoked", revocations: revocations, claims: jwt.MapClaims{"sub": "user1", "jti": "token-2"}, expectedCode: http.StatusOK},
+		{
+			name:         "revoked by ID",
+			revocations:  revocations,
+			claims:       jwt.MapClaims{"sub": "user1", "jti": "token-1"},
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: "token has been revoked",
+		},
+		{
+			name:         "revoked by subject",
+			revocations:  revocations,
+			claims:       jwt.MapClaims{"sub": "user2"},
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: "token has been revoked",
+		},
+		{name: "revoked in another tenant", revocations: revocations, claims: jwt.MapClaims{"sub": "user2", "jti": "token-1", "tenant": "globex"}, expectedCode: http.StatusOK},
+		{
+			name:         "revocation check failed",
+			revocations:  revocationsStub{err: pkg.Errorf(pkg.EINTERNAL, "store unavailable", nil)},
+			claims:       jwt.MapClaims{"sub": "user1"},
+			expectedCode: http.StatusInternalServerError,
+			expectedBody: "failed to check the token revocation",
+		},
+		{name: "not checked", claims: jwt.MapClaims{"sub": "user1", "jti": "token-1"}, expectedCode: http.StatusOK},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := []MiddlewareOption{WithTenant("tenant", "")}
+			if tt.revocations != nil {
+				opts = append(opts, WithRevocations(tt.revocations))
+			}
+			r := gin.New()
+			r.GET("/", TokenMiddleware(NewHMACVerifier(secret), opts...), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			if _, ok := tt.claims["tenant"]; !ok {
+				tt.claims["tenant"] = "acme"
+			}
+			tt.claims["exp"] = time.Now().Add(time.Hour).Unix()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("Authorization", "Bearer "+signClaims(t, jwt.SigningMethodHS256, "", secret, tt.claims))
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestTokenMiddleware_Tenant(t *testing.T) {
+	secret := []byte("shared-secret-of-at-least-32-bytes!")
+	apiKeys := apiKeysStub{"cs_key1.secret": {ID: "key1", TenantID: "acme"}}
+	tests := []struct {
+		name         string
+		claim        string
+		header       string
+		claims       jwt.MapClaims
+		apiKey       string
+		tenantHeader string
+		expectedCode int
+		expectedBody string
+	}{
+		{name: "default tenant", claims: jwt.MapClaims{"sub": "user1", "tenant": "acme"}, tenantHeader: "globex", expectedCode: http.StatusOK, expectedBody: "tenant="},
+		{name: "from the claim", claim: "org.id", claims: jwt.MapClaims{"sub": "user1", "org": map[string]any{"id": "acme"}}, expectedCode: http.StatusOK, expectedBody: "tenant=acme"},
+		{
+			name:         "claim missing",
+			claim:        "tenant",
+			claims:       jwt.MapClaims{"sub": "user1"},
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: "token has no tenant",
+		},
+		{
+			name:         "claim not a string",
+			claim:        "tenant",
+			claims:       jwt.MapClaims{"sub": "user1", "tenant": []string{"acme"}},
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: "tenant claim must be a string",
+		},
+		{
+			name:         "header matching the claim",
+			claim:        "tenant",
+			header:       "X-Tenant-ID",
+			claims:       jwt.MapClaims{"sub": "user1", "tenant": "acme"},
+			tenantHeader: "acme",
+			expectedCode: http.StatusOK,
+			expectedBody: "tenant=acme",
+		},
+		{
+			name:         "header of another tenant than the claim",
+			claim:        "tenant",
+			header:       "X-Tenant-ID",
+			claims:       jwt.MapClaims{"sub": "user1", "tenant": "acme"},
+			tenantHeader: "globex",
+			expectedCode: http.StatusForbidden,
+			expectedBody: "tenant does not match the credentials",
+		},
+		{name: "from the header", header: "X-Tenant-ID", claims: jwt.MapClaims{"sub": "user1"}, tenantHeader: "globex", expectedCode: http.StatusOK, expectedBody: "tenant=globex"},
+		{name: "API key", claim: "tenant", header: "X-Tenant-ID", apiKey: "cs_key1.secret", expectedCode: http.StatusOK, expectedBody: "tenant=acme"},
+		{
+			name:         "API key of another tenant than the header",
+			header:       "X-Tenant-ID",
+			apiKey:       "cs_key1.secret",
+			tenantHeader: "globex",
+			expectedCode: http.StatusForbidden,
+			expectedBody: "tenant does not match the credentials",
+		},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/", TokenMiddleware(NewHMACVerifier(secret), WithAPIKeys(apiKeys), WithTenant(tt.claim, tt.header)), func(c *gin.Context) {
+				c.String(http.StatusOK, "tenant=%s", c.GetString("tenant_id"))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.apiKey != "" {
+				req.Header.Set(APIKeyHeader, tt.apiKey)
+			} else {
+				tt.claims["exp"] = time.Now().Add(time.Hour).Unix()
+				req.Header.Set("Authorization", "Bearer "+signClaims(t, jwt.SigningMethodHS256, "", secret, tt.claims))
+			}
+			req.Header.Set("X-Tenant-ID", tt.tenantHeader)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestTokenMiddleware_DevIdentity(t *testing.T) {
+	secret := []byte("shared-secret-of-at-least-32-bytes!")
+	dev := DevIdentity{UserID: "dev", Roles: []string{"admin"}, TenantID: "acme"}
+	tests := []struct {
+		name         string
+		opts         []MiddlewareOption
+		token        string
+		tenantHeader string
+		expectedCode int
+		expectedBody string
+	}{
+		{name: "requests without credentials", opts: []MiddlewareOption{WithDevIdentity(dev)}, expectedCode: http.StatusOK, expectedBody: "dev [admin] acme"},
+		{
+			name:         "tokens still verified",
+			opts:         []MiddlewareOption{WithDevIdentity(dev)},
+			token:        "Bearer " + signClaims(t, jwt.SigningMethodHS256, "", secret, jwt.MapClaims{"sub": "user1", "roles": []string{"user"}, "exp": time.Now().Add(time.Hour).Unix()}),
+			expectedCode: http.StatusOK,
+			expectedBody: "user1 [user] ",
+		},
+		{
+			name:         "invalid tokens still rejected",
+			opts:         []MiddlewareOption{WithDevIdentity(dev)},
+			token:        "Bearer invalid",
+			expectedCode: http.StatusUnauthorized,
+			expectedBody: "malformed token",
+		},
+		{
+			name:         "tenant chosen with the header",
+			opts:         []MiddlewareOption{WithDevIdentity(dev), WithTenant("", "X-Tenant-ID")},
+			tenantHeader: "globex",
+			expectedCode: http.StatusOK,
+			expectedBody: "dev [admin] globex",
+		},
+		{
+			name:         "tenant header checked against the identity",
+			opts:         []MiddlewareOption{WithDevIdentity(dev), WithTenant("tenant", "X-Tenant-ID")},
+			tenantHeader: "globex",
+			expectedCode: http.StatusForbidden,
+			expectedBody: "tenant does not match the credentials",
+		},
+		{name: "disabled", expectedCode: http.StatusUnauthorized, expectedBody: "missing authorization header"},
+	}
+
+	gin.SetMode(gin.TestMode)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/", TokenMiddleware(NewHMACVerifier(secret), tt.opts...), func(c *gin.Context) {
+				c.String(http.StatusOK, "%s %v %s", c.GetString("user_id"), c.GetStringSlice("roles"), c.GetString("tenant_id"))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", tt.token)
+			}
+			req.Header.Set("X-Tenant-ID", tt.tenantHeader)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+// FuzzTokenMiddleware signs arbitrary claims and sends arbitrary authorization headers: the middleware must answer
+// 200, 401 or 403 and never panic, which gin.Recovery would otherwise turn into a 500.
+func FuzzTokenMiddleware(f *testing.F) {
+	secret := []byte("shared-secret-of-at-least-32-bytes!")
+	verifier := NewHMACVerifier(secret)
+	exp := time.Now().Add(time.Hour).Unix()
+
+	for _, claims := range []string{
+		fmt.Sprintf(`{"sub":"user123","roles":["admin"],"exp":%d}`, exp),
+		fmt.Sprintf(`{"sub":"user123","roles":"admin user","exp":%d}`, exp),
+		fmt.Sprintf(`{"sub":"user123","exp":%d}`, exp),
+		fmt.Sprintf(`{"sub":"user123","roles":{"admin":true},"exp":%d}`, exp),
+		fmt.Sprintf(`{"sub":"user123","roles":[null,1,"admin"],"exp":%d}`, exp),
+		fmt.Sprintf(`{"sub":["user123"],"roles":["admin"],"exp":%d}`, exp),
+		fmt.Sprintf(`{"sub":"user123","realm_access":{"roles":["admin"]},"exp":%d}`, exp),
+		`{"sub":"user123","roles":["admin"],"exp":"tomorrow"}`,
+		`[]`,
+		`null`,
+	} {
+		f.Add(claims, "roles", "")
+		f.Add(claims, "realm_access.roles", "")
+	}
+	f.Add("", "roles", "Bearer ")
+	f.Add("", "roles", "Bearer a.b.c")
+	f.Add("", "roles", "Basic dXNlcjpwYXNz")
+
+	gin.SetMode(gin.TestMode)
+	f.Fuzz(func(t *testing.T, claims, rolesClaim, header string) {
+		r := gin.New()
+		r.GET("/", TokenMiddleware(verifier, WithRolesClaim(rolesClaim)), DefaultAccessPolicy().Require(PermCouponsApply), func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		if header == "" {
+			header = "Bearer " + signPayload(secret, claims)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header["Authorization"] = []string{header}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+
+		assert.Contains(t, []int{http.StatusOK, http.StatusUnauthorized, http.StatusForbidden}, rec.Code)
+	})
+}
+
+// signPayload returns an HS256 token of the raw payload, which needs not be valid claims.
+func signPayload(secret []byte, payload string) string {
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(payload))
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"coupon_service/internal/bruteforce"
+	"coupon_service/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+// bruteForceProtection locks out users and IPs repeatedly looking up unknown or mistyped coupon codes,
+// answering 429 Too Many Requests with a Retry-After header while they are locked out.
+// Failures of the store let requests through, so an unavailable store does not take the API down.
+func bruteForceProtection(guard *bruteforce.Guard) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keys := []string{"ip:" + c.ClientIP()}
+		if userID, ok := c.Get("user_id"); ok && userID != nil {
+			keys = append(keys, "user:"+fmt.Sprint(userID))
+		}
+
+		lockedFor, err := guard.Check(c.Request.Context(), keys...)
+		if err != nil {
+			log.Printf("brute force protection unavailable: %v", err)
+		}
+		if lockedFor > 0 {
+			tooManyFailures(c, lockedFor)
+			return
+		}
+
+		c.Next()
+
+		if !failedLookup(c.Writer.Status()) {
+			return
+		}
+		if _, err := guard.Fail(c.Request.Context(), keys...); err != nil {
+			log.Printf("brute force protection unavailable: %v", err)
+		}
+	}
+}
+
+// failedLookup reports whether the response status tells a coupon code was not found or mistyped.
+func failedLookup(status int) bool {
+	return status == http.StatusNotFound || status == pkg.ErrorStatusCode(pkg.EMISTYPED)
+}
+
+func tooManyFailures(c *gin.Context, retryAfter time.Duration) {
+	c.Header("Retry-After", seconds(retryAfter))
+	WebErr(c, pkg.Errorf(pkg.ETOOMANYREQUESTS, "too many failed coupon lookups, retry later", nil))
+	c.Abort()
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"coupon_service/internal/bruteforce"
+	"coupon_service/internal/config"
+	"coupon_service/pkg"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBruteForceProtection(t *testing.T) {
+	guard := bruteforce.New(bruteforce.NewMemoryStore(), bruteforce.Config{
+		Threshold:   2,
+		BaseLockout: time.Minute,
+		MaxLockout:  time.Hour,
+		Window:      time.Hour,
+	})
+
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", c.GetHeader("X-User"))
+	})
+	r.GET("/lookup/:code", bruteForceProtection(guard), func(c *gin.Context) {
+		switch c.Param("code") {
+		case "known":
+			c.Status(http.StatusOK)
+		case "mistyped":
+			WebErr(c, pkg.Errorf(pkg.EMISTYPED, "coupon code appears to be mistyped, please check it", nil))
+		default:
+			WebErr(c, pkg.Errorf(pkg.ENOTFOUND, "coupon not found", nil))
+		}
+	})
+	lookup := func(code, user, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/lookup/"+code, nil)
+		req.Header.Set("X-User", user)
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, lookup("known", "alice", "10.0.0.1").Code)
+	assert.Equal(t, http.StatusNotFound, lookup("guess1", "alice", "10.0.0.1").Code)
+	assert.Equal(t, http.StatusUnprocessableEntity, lookup("mistyped", "alice", "10.0.0.1").Code)
+
+	// alice is locked out, whatever her IP and even for known codes
+	rec := lookup("known", "alice", "10.0.0.2")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "60", rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Body.String(), "too many failed coupon lookups, retry later")
+
+	// and so is her first IP, for other users too
+	assert.Equal(t, http.StatusTooManyRequests, lookup("known", "bob", "10.0.0.1").Code)
+
+	// while other users on other IPs are not
+	assert.Equal(t, http.StatusOK, lookup("known", "bob", "10.0.0.3").Code)
+}
+
+func TestBruteForceProtection_ForwardedFor(t *testing.T) {
+	guard := bruteforce.New(bruteforce.NewMemoryStore(), bruteforce.Config{
+		Threshold:   1,
+		BaseLockout: time.Minute,
+		MaxLockout:  time.Hour,
+		Window:      time.Hour,
+	})
+
+	var cfg config.Config
+	cfg.Env.Environment = "test"
+	cfg.Env.TrustedProxies = []string{"10.0.0.100"}
+	r := SetupRouter(cfg)
+	r.GET("/lookup/:code", bruteForceProtection(guard), func(c *gin.Context) {
+		if c.Param("code") == "known" {
+			c.Status(http.StatusOK)
+			return
+		}
+		WebErr(c, pkg.Errorf(pkg.ENOTFOUND, "coupon not found", nil))
+	})
+	lookup := func(code, ip, forwardedFor string) int {
+		req := httptest.NewRequest(http.MethodGet, "/lookup/"+code, nil)
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// a caller cannot evade its lockout by spoofing the header
+	assert.Equal(t, http.StatusNotFound, lookup("guess", "10.0.0.1", "10.0.0.2"))
+	assert.Equal(t, http.StatusTooManyRequests, lookup("known", "10.0.0.1", "10.0.0.3"))
+
+	// nor lock out the IP it names
+	assert.Equal(t, http.StatusOK, lookup("known", "10.0.0.2", ""))
+
+	// while the header of a trusted proxy is honoured
+	assert.Equal(t, http.StatusTooManyRequests, lookup("known", "10.0.0.100", "10.0.0.1"))
+	assert.Equal(t, http.StatusOK, lookup("known", "10.0.0.100", "10.0.0.4"))
+}
@@ -1,37 +1,114 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 
+	"coupon_service/internal/entity"
 	"coupon_service/pkg"
 
 	"github.com/gin-gonic/gin"
 )
 
 type ApplyCouponRequest struct {
-	Code  string `json:"code"`
-	Value int    `json:"value"`
+	Code  string       `json:"code"`
+	Value Money        `json:"value"`
+	Lines []BasketLine `json:"lines"`
+}
+
+// BasketLine is a product or shipping line of the basket. Lines are required by coupons
+// whose effect depends on the basket content (free shipping, buy X get Y, cheapest item free).
+type BasketLine struct {
+	SKU       string `json:"sku"`
+	Type      string `json:"type" enums:"product,shipping"`
+	Quantity  int    `json:"quantity"`
+	UnitPrice Money  `json:"unit_price"`
+}
+
+func (l BasketLine) validate() error {
+	switch entity.LineType(l.Type) {
+	case entity.LineProduct:
+		if l.SKU == "" {
+			return pkg.Errorf(pkg.EINVALID, "sku of product lines cannot be empty", nil)
+		}
+	case entity.LineShipping:
+	default:
+		return pkg.Errorf(pkg.EINVALID, "line type should be product or shipping", nil)
+	}
+
+	if l.Quantity <= 0 {
+		return pkg.Errorf(pkg.EINVALID, "line quantity should be positive", nil)
+	}
+	if l.Quantity > entity.MaxLineQuantity {
+		return pkg.Errorf(pkg.EINVALID, fmt.Sprintf("line quantity cannot exceed %d", entity.MaxLineQuantity), nil)
+	}
+
+	if l.UnitPrice.Amount < 0 {
+		return pkg.Errorf(pkg.EINVALID, "line unit_price cannot be negative", nil)
+	}
+	if l.UnitPrice.Amount > entity.MaxLineUnitPrice {
+		return pkg.Errorf(pkg.EINVALID, fmt.Sprintf("line unit_price cannot exceed %d", entity.MaxLineUnitPrice), nil)
+	}
+	if !pkg.IsCurrencyCode(l.UnitPrice.toEntity().Currency) {
+		return pkg.Errorf(pkg.EINVALID, "line unit_price currency must be an ISO 4217 code", nil)
+	}
+	return nil
+}
+
+func (l BasketLine) toEntity() entity.BasketLine {
+	return entity.BasketLine{
+		SKU:       l.SKU,
+		Type:      entity.LineType(l.Type),
+		Quantity:  l.Quantity,
+		UnitPrice: l.UnitPrice.toEntity(),
+	}
 }
 
 type ApplyCouponResponse struct {
-	Value                 int  `json:"value"`
-	AppliedDiscount       int  `json:"applied_discount"`
-	ApplicationSuccessful bool `json:"application_successful"`
+	Value                 Money                `json:"value"`
+	AppliedDiscount       Money                `json:"applied_discount"`
+	ApplicationSuccessful bool                 `json:"application_successful"`
+	Lines                 []BasketLineResponse `json:"lines,omitempty"`
+}
+
+// BasketLineResponse describes the effect the coupon had on a basket line.
+type BasketLineResponse struct {
+	SKU             string `json:"sku"`
+	Type            string `json:"type"`
+	Quantity        int    `json:"quantity"`
+	UnitPrice       Money  `json:"unit_price"`
+	AppliedDiscount Money  `json:"applied_discount"`
+	Effect          string `json:"effect,omitempty"`
+}
+
+func newBasketLineResponse(l entity.BasketLine) BasketLineResponse {
+	return BasketLineResponse{
+		SKU:             l.SKU,
+		Type:            string(l.Type),
+		Quantity:        l.Quantity,
+		UnitPrice:       newMoney(l.UnitPrice),
+		AppliedDiscount: newMoney(l.AppliedDiscount),
+		Effect:          string(l.Effect),
+	}
 }
 
 // ApplyCoupon godoc
 // @Summary      Apply a coupon to a basket
-// @Description  Applies a coupon code to a given basket value and returns the result or error
+// @Description  Applies a coupon code to a basket and returns the discount applied to the basket and to each of its lines, or an error
 // @Tags         coupons
 // @Accept       json
 // @Security     BearerAuth
 // @Produce      json
 // @Param        request body ApplyCouponRequest true "Coupon code and basket value"
+// @Param        Idempotency-Key header string false "Key identifying retries of the request, whose first response is replayed"
 // @Success      200 {object} ApplyCouponResponse
 // @Failure      400 {object} pkg.Error
 // @Success      401
 // @Failure      403
 // @Failure      404 {object} pkg.Error
+// @Failure      422 {object} pkg.Error
+// @Failure      429 {object} pkg.Error
 // @Router       /coupon/validation [post]
 func (a *API) ApplyCoupon(c *gin.Context) {
 	input := ApplyCouponRequest{}
@@ -45,41 +122,166 @@ func (a *API) ApplyCoupon(c *gin.Context) {
 		return
 	}
 
-	if input.Value <= 0 {
-		WebErr(c, pkg.Errorf(pkg.EINVALID, "value should be positive", nil))
+	if err := input.Value.validatePositive("value"); err != nil {
+		WebErr(c, err)
 		return
 	}
 
-	basket, err := a.svc.ApplyCoupon(input.Code, input.Value)
+	lines := make([]entity.BasketLine, len(input.Lines))
+	for i, l := range input.Lines {
+		if l.Type == "" {
+			l.Type = string(entity.LineProduct)
+		}
+		if err := l.validate(); err != nil {
+			WebErr(c, err)
+			return
+		}
+		lines[i] = l.toEntity()
+	}
+
+	basket, err := a.svc.ApplyCoupon(a.requestContext(c), input.Code, entity.Basket{Value: input.Value.toEntity(), Lines: lines})
 	if err != nil {
 		WebErr(c, err)
 		return
 	}
 
+	var linesResponse []BasketLineResponse
+	for _, l := range basket.Lines {
+		linesResponse = append(linesResponse, newBasketLineResponse(l))
+	}
+
 	c.JSON(http.StatusOK, ApplyCouponResponse{
-		Value:                 basket.Value,
-		AppliedDiscount:       basket.AppliedDiscount,
-		ApplicationSuccessful: basket.ApplicationSuccessful})
+		Value:                 newMoney(basket.Value),
+		AppliedDiscount:       newMoney(basket.AppliedDiscount),
+		ApplicationSuccessful: basket.ApplicationSuccessful,
+		Lines:                 linesResponse})
 }
 
 type CreateCouponRequest struct {
-	Code           string `json:"code"`
-	Discount       int    `json:"discount"`
-	MinBasketValue int    `json:"minimum_basket_value"`
+	Code         string        `json:"code"`
+	GenerateCode bool          `json:"generate_code"`
+	Effect       string        `json:"effect" enums:"fixed_discount,free_shipping,buy_x_get_y,cheapest_item_free,tiered_discount"`
+	Terms        []CouponTerms `json:"terms"`
+	BuyXGetY     *BuyXGetY     `json:"buy_x_get_y,omitempty"`
+	Owner        string        `json:"owner,omitempty"`
+	Description  string        `json:"description,omitempty"`
+	Labels       []string      `json:"labels,omitempty"`
+}
+
+// CouponTerms are the discount and minimum basket value of a coupon in one currency.
+// Tiered discount coupons define them through their tiers instead.
+type CouponTerms struct {
+	Discount       Money  `json:"discount"`
+	MinBasketValue Money  `json:"minimum_basket_value"`
+	Tiers          []Tier `json:"tiers,omitempty"`
+}
+
+// Tier grants its discount to baskets reaching its minimum basket value.
+type Tier struct {
+	MinBasketValue Money `json:"minimum_basket_value"`
+	Discount       Money `json:"discount"`
+}
+
+func (t CouponTerms) toEntity() entity.Terms {
+	var tiers []entity.Tier
+	for _, tier := range t.Tiers {
+		tiers = append(tiers, entity.Tier{
+			MinBasketValue: tier.MinBasketValue.toEntity(),
+			Discount:       tier.Discount.toEntity(),
+		})
+	}
+	return entity.Terms{
+		Discount:       t.Discount.toEntity(),
+		MinBasketValue: t.MinBasketValue.toEntity(),
+		Tiers:          tiers,
+	}
+}
+
+func newCouponTerms(t entity.Terms) CouponTerms {
+	var tiers []Tier
+	for _, tier := range t.Tiers {
+		tiers = append(tiers, Tier{
+			MinBasketValue: newMoney(tier.MinBasketValue),
+			Discount:       newMoney(tier.Discount),
+		})
+	}
+	return CouponTerms{
+		Discount:       newMoney(t.Discount),
+		MinBasketValue: newMoney(t.MinBasketValue),
+		Tiers:          tiers,
+	}
+}
+
+// validate checks the amounts the coupon effect relies on.
+func (t CouponTerms) validate(effect entity.Effect) error {
+	if effect == entity.EffectTieredDiscount {
+		for _, tier := range t.Tiers {
+			if err := tier.Discount.validatePositive("tier discount"); err != nil {
+				return err
+			}
+			if err := tier.MinBasketValue.validatePositive("tier minimum_basket_value"); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if effect == entity.EffectFixedDiscount {
+		if err := t.Discount.validatePositive("discount"); err != nil {
+			return err
+		}
+	}
+	return t.MinBasketValue.validatePositive("minimum_basket_value")
+}
+
+// BuyXGetY grants discount_percent off "get" units of the qualifying SKUs for every "buy" units bought.
+// An empty SKU list makes every product qualify.
+type BuyXGetY struct {
+	SKUs            []string `json:"skus"`
+	Buy             int      `json:"buy"`
+	Get             int      `json:"get"`
+	DiscountPercent int64    `json:"discount_percent"`
+}
+
+func (b *BuyXGetY) toEntity() *entity.BuyXGetY {
+	if b == nil {
+		return nil
+	}
+	return &entity.BuyXGetY{
+		SKUs:            b.SKUs,
+		Buy:             b.Buy,
+		Get:             b.Get,
+		DiscountPercent: b.DiscountPercent,
+	}
+}
+
+func newBuyXGetY(b *entity.BuyXGetY) *BuyXGetY {
+	if b == nil {
+		return nil
+	}
+	return &BuyXGetY{
+		SKUs:            b.SKUs,
+		Buy:             b.Buy,
+		Get:             b.Get,
+		DiscountPercent: b.DiscountPercent,
+	}
 }
 
 // CreateCoupon godoc
 // @Summary      Create a new coupon
-// @Description  Creates a new coupon with the specified code, discount, and minimum basket value
+// @Description  Creates a new coupon with the specified or a generated code, effect and, per supported currency, discount and minimum basket value
 // @Tags         coupons
 // @Accept       json
 // @Security     BearerAuth
+// @Produce      json
 // @Param        request body CreateCouponRequest true "Coupon details"
-// @Success      201
+// @Param        Idempotency-Key header string false "Key identifying retries of the request, whose first response is replayed"
+// @Success      201 {object} CouponResponse
 // @Failure      400 {object} pkg.Error
 // @Success      401
 // @Failure      403
 // @Failure      409 {object} pkg.Error
+// @Failure      429 {object} pkg.Error
 // @Router       /coupon [post]
 func (a *API) CreateCoupon(c *gin.Context) {
 	input := CreateCouponRequest{}
@@ -88,32 +290,56 @@ func (a *API) CreateCoupon(c *gin.Context) {
 		return
 	}
 
-	if input.Code == "" {
+	if input.Code == "" && !input.GenerateCode {
 		WebErr(c, pkg.Errorf(pkg.EINVALID, "code cannot be empty", nil))
 		return
 	}
 
-	if input.Discount <= 0 {
-		WebErr(c, pkg.Errorf(pkg.EINVALID, "discount should be positive", nil))
+	if input.Code != "" && input.GenerateCode {
+		WebErr(c, pkg.Errorf(pkg.EINVALID, "code must be empty when generate_code is set", nil))
 		return
 	}
 
-	if input.MinBasketValue <= 0 {
-		WebErr(c, pkg.Errorf(pkg.EINVALID, "minimum_basket_value should be positive", nil))
+	if len(input.Terms) == 0 {
+		WebErr(c, pkg.Errorf(pkg.EINVALID, "minimum of one currency terms required", nil))
 		return
 	}
 
-	err := a.svc.CreateCoupon(input.Code, input.Discount, input.MinBasketValue)
+	effect := entity.Effect(input.Effect)
+	if effect == "" {
+		effect = entity.EffectFixedDiscount
+	}
+
+	terms := make([]entity.Terms, len(input.Terms))
+	for i, t := range input.Terms {
+		if err := t.validate(effect); err != nil {
+			WebErr(c, err)
+			return
+		}
+		terms[i] = t.toEntity()
+	}
+
+	coupon, err := a.svc.CreateCoupon(a.requestContext(c), entity.Coupon{
+		Code:        input.Code,
+		Effect:      effect,
+		Terms:       terms,
+		BuyXGetY:    input.BuyXGetY.toEntity(),
+		Owner:       input.Owner,
+		Description: input.Description,
+		Labels:      input.Labels,
+	})
 	if err != nil {
 		WebErr(c, err)
 		return
 	}
 
-	c.Status(http.StatusCreated)
+	c.JSON(http.StatusCreated, newCouponResponse(coupon))
 }
 
 type GetCouponsRequest struct {
-	Codes []string `json:"codes"`
+	Codes     []string `json:"codes"`
+	Labels    []string `json:"labels,omitempty"`
+	CreatedBy string   `json:"created_by,omitempty"`
 }
 
 type GetCouponsResponse struct {
@@ -121,15 +347,44 @@ type GetCouponsResponse struct {
 }
 
 type CouponResponse struct {
-	ID             string `json:"id"`
-	Code           string `json:"code"`
-	Discount       int    `json:"discount"`
-	MinBasketValue int    `json:"minimum_basket_value"`
+	ID          string        `json:"id"`
+	Code        string        `json:"code"`
+	Effect      string        `json:"effect"`
+	Terms       []CouponTerms `json:"terms"`
+	BuyXGetY    *BuyXGetY     `json:"buy_x_get_y,omitempty"`
+	Owner       string        `json:"owner,omitempty"`
+	Description string        `json:"description,omitempty"`
+	Labels      []string      `json:"labels,omitempty"`
+	CreatedAt   *time.Time    `json:"created_at,omitempty"`
+	CreatedBy   string        `json:"created_by,omitempty"`
+	UpdatedAt   *time.Time    `json:"updated_at,omitempty"`
+	UpdatedBy   string        `json:"updated_by,omitempty"`
+}
+
+func newCouponResponse(c entity.Coupon) CouponResponse {
+	terms := make([]CouponTerms, len(c.Terms))
+	for i, t := range c.Terms {
+		terms[i] = newCouponTerms(t)
+	}
+	return CouponResponse{
+		ID:          c.ID,
+		Code:        c.Code,
+		Effect:      string(c.Effect),
+		Terms:       terms,
+		BuyXGetY:    newBuyXGetY(c.BuyXGetY),
+		Owner:       c.Owner,
+		Description: c.Description,
+		Labels:      c.Labels,
+		CreatedAt:   optionalTime(c.CreatedAt),
+		CreatedBy:   c.CreatedBy,
+		UpdatedAt:   optionalTime(c.UpdatedAt),
+		UpdatedBy:   c.UpdatedBy,
+	}
 }
 
 // GetCoupons godoc
 // @Summary      Get coupons by codes
-// @Description  Retrieves coupon details for the provided list of coupons if they are all existent
+// @Description  Retrieves coupon details for the provided list of coupons if they are all existent, keeping only the coupons with all the given labels and created by the given user, if any
 // @Tags         coupons
 // @Accept       json
 // @Security     BearerAuth
@@ -140,6 +395,8 @@ type CouponResponse struct {
 // @Success      401
 // @Failure      403
 // @Failure      404 {object} pkg.Error
+// @Failure      422 {object} pkg.Error
+// @Failure      429 {object} pkg.Error
 // @Router       /coupons [get]
 func (a *API) GetCoupons(c *gin.Context) {
 	input := GetCouponsRequest{}
@@ -153,7 +410,10 @@ func (a *API) GetCoupons(c *gin.Context) {
 		return
 	}
 
-	coupons, err := a.svc.GetCoupons(input.Codes)
+	coupons, err := a.svc.GetCoupons(a.requestContext(c), input.Codes, entity.CouponFilter{
+		Labels:    input.Labels,
+		CreatedBy: input.CreatedBy,
+	})
 	if err != nil {
 		WebErr(c, err)
 		return
@@ -161,13 +421,16 @@ func (a *API) GetCoupons(c *gin.Context) {
 
 	couponsResponse := make([]CouponResponse, len(coupons))
 	for i, c := range coupons {
-		couponsResponse[i] = CouponResponse{
-			ID:             c.ID,
-			Code:           c.Code,
-			Discount:       c.Discount,
-			MinBasketValue: c.MinBasketValue,
-		}
+		couponsResponse[i] = newCouponResponse(c)
 	}
 
 	c.JSON(http.StatusOK, couponsResponse)
 }
+
+// optionalTime returns nil for the zero time, so that it is omitted from responses.
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -2,13 +2,19 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"coupon_service/internal/api/auth"
 	"coupon_service/internal/entity"
+	"coupon_service/internal/identity"
 	"coupon_service/internal/service"
+	"coupon_service/internal/signedcode"
 	"coupon_service/pkg"
 
 	"github.com/gin-gonic/gin"
@@ -17,9 +23,15 @@ import (
 
 func setupRouter(api *API) *gin.Engine {
 	r := gin.Default()
+	// stands in for the auth middlewares
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", "user123")
+		c.Set("roles", []string{"user"})
+	})
 	r.POST("/coupon/apply", api.ApplyCoupon)
 	r.POST("/coupon/create", api.CreateCoupon)
 	r.POST("/coupon/get", api.GetCoupons)
+	r.POST("/coupon/signed", api.IssueSignedCodes)
 	return r
 }
 
@@ -36,11 +48,11 @@ func TestAPI_ApplyCoupon(t *testing.T) {
 			name: "success",
 			input: ApplyCouponRequest{
 				Code:  "ABCDEF123",
-				Value: 100,
+				Value: Money{Amount: 100, Currency: "EUR"},
 			},
 			mockBasket: entity.Basket{
-				Value:                 100,
-				AppliedDiscount:       10,
+				Value:                 entity.NewMoney(100, "EUR"),
+				AppliedDiscount:       entity.NewMoney(10, "EUR"),
 				ApplicationSuccessful: true,
 			},
 			mockSvcError: nil,
@@ -50,7 +62,7 @@ func TestAPI_ApplyCoupon(t *testing.T) {
 			name: "invalid: code is empty",
 			input: ApplyCouponRequest{
 				Code:  "",
-				Value: 100,
+				Value: Money{Amount: 100, Currency: "EUR"},
 			},
 			expectedCode: http.StatusBadRequest,
 			expectedBody: "code cannot be empty",
@@ -59,7 +71,7 @@ func TestAPI_ApplyCoupon(t *testing.T) {
 			name: "invalid: value is 0",
 			input: ApplyCouponRequest{
 				Code:  "ABCDEF123",
-				Value: 0,
+				Value: Money{Amount: 0, Currency: "EUR"},
 			},
 			expectedCode: http.StatusBadRequest,
 			expectedBody: "value should be positive",
@@ -68,29 +80,110 @@ func TestAPI_ApplyCoupon(t *testing.T) {
 			name: "invalid: value is negative",
 			input: ApplyCouponRequest{
 				Code:  "ABCDEF123",
-				Value: -20,
+				Value: Money{Amount: -20, Currency: "EUR"},
 			},
 			expectedCode: http.StatusBadRequest,
 			expectedBody: "value should be positive",
 		},
+		{
+			name: "invalid: currency is not an ISO 4217 code",
+			input: ApplyCouponRequest{
+				Code:  "ABCDEF123",
+				Value: Money{Amount: 100, Currency: "EURO"},
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "value currency must be an ISO 4217 code",
+		},
+		{
+			name: "success with lines",
+			input: ApplyCouponRequest{
+				Code:  "ABCDEF123",
+				Value: Money{Amount: 2500, Currency: "EUR"},
+				Lines: []BasketLine{
+					{SKU: "A", Quantity: 2, UnitPrice: Money{Amount: 1000, Currency: "EUR"}},
+					{SKU: "SHIP", Type: "shipping", Quantity: 1, UnitPrice: Money{Amount: 500, Currency: "EUR"}},
+				},
+			},
+			mockBasket: entity.Basket{
+				Value: entity.NewMoney(2500, "EUR"),
+				Lines: []entity.BasketLine{
+					{SKU: "A", Type: entity.LineProduct, Quantity: 2, UnitPrice: entity.NewMoney(1000, "EUR"), AppliedDiscount: entity.NewMoney(0, "EUR")},
+					{SKU: "SHIP", Type: entity.LineShipping, Quantity: 1, UnitPrice: entity.NewMoney(500, "EUR"), AppliedDiscount: entity.NewMoney(500, "EUR"), Effect: entity.EffectFreeShipping},
+				},
+				AppliedDiscount:       entity.NewMoney(500, "EUR"),
+				ApplicationSuccessful: true,
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `"effect":"free_shipping"`,
+		},
+		{
+			name: "invalid: line quantity is 0",
+			input: ApplyCouponRequest{
+				Code:  "ABCDEF123",
+				Value: Money{Amount: 2500, Currency: "EUR"},
+				Lines: []BasketLine{
+					{SKU: "A", Quantity: 0, UnitPrice: Money{Amount: 1000, Currency: "EUR"}},
+				},
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "line quantity should be positive",
+		},
+		{
+			name: "invalid: line quantity too large",
+			input: ApplyCouponRequest{
+				Code:  "ABCDEF123",
+				Value: Money{Amount: 2500, Currency: "EUR"},
+				Lines: []BasketLine{
+					{SKU: "A", Quantity: 1 << 40, UnitPrice: Money{Amount: 1000, Currency: "EUR"}},
+				},
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "line quantity cannot exceed 1000000",
+		},
+		{
+			name: "invalid: unknown line type",
+			input: ApplyCouponRequest{
+				Code:  "ABCDEF123",
+				Value: Money{Amount: 2500, Currency: "EUR"},
+				Lines: []BasketLine{
+					{SKU: "A", Type: "gift", Quantity: 1, UnitPrice: Money{Amount: 1000, Currency: "EUR"}},
+				},
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "line type should be product or shipping",
+		},
 		{
 			name: "service error",
 			input: ApplyCouponRequest{
 				Code:  "ABCDEF",
-				Value: 100,
+				Value: Money{Amount: 100, Currency: "EUR"},
 			},
 			mockSvcError: pkg.Errorf(pkg.EINVALID, "abc test error", nil),
 			expectedCode: http.StatusBadRequest,
 			expectedBody: "abc test error",
 		},
+		{
+			name: "not found with suggestions",
+			input: ApplyCouponRequest{
+				Code:  "SAVE1O",
+				Value: Money{Amount: 100, Currency: "EUR"},
+			},
+			mockSvcError: pkg.Errorf(pkg.ENOTFOUND, "coupon not found", nil).WithDetail("suggestions", []string{"SAVE10"}),
+			expectedCode: http.StatusNotFound,
+			expectedBody: `"details":{"suggestions":["SAVE10"]}`,
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			svcMock := &service.CouponServiceMock{
-				ApplyCouponFunc: func(code string, value int) (entity.Basket, error) {
+				ApplyCouponFunc: func(ctx context.Context, code string, basket entity.Basket) (entity.Basket, error) {
+					principal, ok := identity.FromContext(ctx)
+					assert.True(t, ok)
+					assert.Equal(t, identity.Principal{UserID: "user123", Roles: []string{"user"}}, principal)
 					assert.Equal(t, tt.input.Code, code)
-					assert.Equal(t, tt.input.Value, value)
+					assert.Equal(t, tt.input.Value.toEntity(), basket.Value)
+					assert.Len(t, basket.Lines, len(tt.input.Lines))
 					return tt.mockBasket, tt.mockSvcError
 				},
 			}
@@ -123,9 +216,8 @@ func TestAPI_CreateCoupon(t *testing.T) {
 		{
 			name: "success",
 			input: CreateCouponRequest{
-				Code:           "ABCDEF123",
-				Discount:       10,
-				MinBasketValue: 20,
+				Code:  "ABCDEF123",
+				Terms: []CouponTerms{{Discount: Money{Amount: 10, Currency: "EUR"}, MinBasketValue: Money{Amount: 20, Currency: "EUR"}}},
 			},
 			mockSvcError: nil,
 			expectedCode: http.StatusCreated,
@@ -133,20 +225,27 @@ func TestAPI_CreateCoupon(t *testing.T) {
 		{
 			name: "empty code",
 			input: CreateCouponRequest{
-				Code:           "",
-				Discount:       10,
-				MinBasketValue: 20,
+				Code:  "",
+				Terms: []CouponTerms{{Discount: Money{Amount: 10, Currency: "EUR"}, MinBasketValue: Money{Amount: 20, Currency: "EUR"}}},
 			},
 			mockSvcError: nil,
 			expectedCode: http.StatusBadRequest,
 			expectedBody: "code cannot be empty",
 		},
+		{
+			name: "invalid, no terms",
+			input: CreateCouponRequest{
+				Code: "ABCDEF123",
+			},
+			mockSvcError: nil,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "minimum of one currency terms required",
+		},
 		{
 			name: "invalid, discount is 0",
 			input: CreateCouponRequest{
-				Code:           "ABCDEF123",
-				Discount:       0,
-				MinBasketValue: 20,
+				Code:  "ABCDEF123",
+				Terms: []CouponTerms{{Discount: Money{Amount: 0, Currency: "EUR"}, MinBasketValue: Money{Amount: 20, Currency: "EUR"}}},
 			},
 			mockSvcError: nil,
 			expectedCode: http.StatusBadRequest,
@@ -155,9 +254,8 @@ func TestAPI_CreateCoupon(t *testing.T) {
 		{
 			name: "invalid, discount is negative",
 			input: CreateCouponRequest{
-				Code:           "ABCDEF123",
-				Discount:       -20,
-				MinBasketValue: 20,
+				Code:  "ABCDEF123",
+				Terms: []CouponTerms{{Discount: Money{Amount: -20, Currency: "EUR"}, MinBasketValue: Money{Amount: 20, Currency: "EUR"}}},
 			},
 			mockSvcError: nil,
 			expectedCode: http.StatusBadRequest,
@@ -166,9 +264,8 @@ func TestAPI_CreateCoupon(t *testing.T) {
 		{
 			name: "invalid, minimum_basket_value is 0",
 			input: CreateCouponRequest{
-				Code:           "ABCDEF123",
-				Discount:       10,
-				MinBasketValue: 0,
+				Code:  "ABCDEF123",
+				Terms: []CouponTerms{{Discount: Money{Amount: 10, Currency: "EUR"}, MinBasketValue: Money{Amount: 0, Currency: "EUR"}}},
 			},
 			mockSvcError: nil,
 			expectedCode: http.StatusBadRequest,
@@ -177,20 +274,95 @@ func TestAPI_CreateCoupon(t *testing.T) {
 		{
 			name: "invalid, minimum_basket_value is negative",
 			input: CreateCouponRequest{
-				Code:           "ABCDEF123",
-				Discount:       10,
-				MinBasketValue: -20,
+				Code:  "ABCDEF123",
+				Terms: []CouponTerms{{Discount: Money{Amount: 10, Currency: "EUR"}, MinBasketValue: Money{Amount: -20, Currency: "EUR"}}},
 			},
 			mockSvcError: nil,
 			expectedCode: http.StatusBadRequest,
 			expectedBody: "minimum_basket_value should be positive",
 		},
+		{
+			name: "invalid, discount currency is missing",
+			input: CreateCouponRequest{
+				Code:  "ABCDEF123",
+				Terms: []CouponTerms{{Discount: Money{Amount: 10}, MinBasketValue: Money{Amount: 20, Currency: "EUR"}}},
+			},
+			mockSvcError: nil,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "discount currency must be an ISO 4217 code",
+		},
+		{
+			name: "success, tiered discount",
+			input: CreateCouponRequest{
+				Code:   "ABCDEF123",
+				Effect: "tiered_discount",
+				Terms: []CouponTerms{{Tiers: []Tier{
+					{MinBasketValue: Money{Amount: 5000, Currency: "EUR"}, Discount: Money{Amount: 500, Currency: "EUR"}},
+					{MinBasketValue: Money{Amount: 10000, Currency: "EUR"}, Discount: Money{Amount: 1500, Currency: "EUR"}},
+				}}},
+			},
+			mockSvcError: nil,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name: "invalid, tier discount is 0",
+			input: CreateCouponRequest{
+				Code:   "ABCDEF123",
+				Effect: "tiered_discount",
+				Terms: []CouponTerms{{Tiers: []Tier{
+					{MinBasketValue: Money{Amount: 5000, Currency: "EUR"}, Discount: Money{Amount: 0, Currency: "EUR"}},
+				}}},
+			},
+			mockSvcError: nil,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "tier discount should be positive",
+		},
+		{
+			name: "success, free shipping without discount",
+			input: CreateCouponRequest{
+				Code:   "ABCDEF123",
+				Effect: "free_shipping",
+				Terms:  []CouponTerms{{MinBasketValue: Money{Amount: 20, Currency: "EUR"}}},
+			},
+			mockSvcError: nil,
+			expectedCode: http.StatusCreated,
+		},
+		{
+			name: "success, generated code",
+			input: CreateCouponRequest{
+				GenerateCode: true,
+				Terms:        []CouponTerms{{Discount: Money{Amount: 10, Currency: "EUR"}, MinBasketValue: Money{Amount: 20, Currency: "EUR"}}},
+			},
+			mockSvcError: nil,
+			expectedCode: http.StatusCreated,
+			expectedBody: `"code":"GENERATED1"`,
+		},
+		{
+			name: "invalid, code given with generate_code",
+			input: CreateCouponRequest{
+				Code:         "ABCDEF",
+				GenerateCode: true,
+				Terms:        []CouponTerms{{Discount: Money{Amount: 10, Currency: "EUR"}, MinBasketValue: Money{Amount: 20, Currency: "EUR"}}},
+			},
+			mockSvcError: nil,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "code must be empty when generate_code is set",
+		},
+		{
+			name: "service validation error",
+			input: CreateCouponRequest{
+				Code:  "ABC",
+				Terms: []CouponTerms{{Discount: Money{Amount: 10, Currency: "EUR"}, MinBasketValue: Money{Amount: 20, Currency: "EUR"}}},
+			},
+			mockSvcError: pkg.ValidationErrorf("invalid coupon code", pkg.FieldError{Field: "code", Message: "minimum length of code is 6 characters"}),
+			expectedCode: http.StatusBadRequest,
+			expectedBody: `"fields":[{"field":"code","message":"minimum length of code is 6 characters"}]`,
+		},
 		{
 			name: "service error",
 			input: CreateCouponRequest{
-				Code:           "ABCDEF",
-				Discount:       10,
-				MinBasketValue: 20,
+				Code:  "ABCDEF",
+				Terms: []CouponTerms{{Discount: Money{Amount: 10, Currency: "EUR"}, MinBasketValue: Money{Amount: 20, Currency: "EUR"}}},
 			},
 			mockSvcError: pkg.Errorf(pkg.ECONFLICT, "coupon already exists", nil),
 			expectedCode: http.StatusConflict,
@@ -201,11 +373,20 @@ func TestAPI_CreateCoupon(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			svcMock := &service.CouponServiceMock{
-				CreateCouponFunc: func(code string, discount, minBasketValue int) error {
-					assert.Equal(t, tt.input.Code, code)
-					assert.Equal(t, tt.input.Discount, discount)
-					assert.Equal(t, tt.input.MinBasketValue, minBasketValue)
-					return tt.mockSvcError
+				CreateCouponFunc: func(ctx context.Context, coupon entity.Coupon) (entity.Coupon, error) {
+					assert.Equal(t, tt.input.Code, coupon.Code)
+					assert.NotEmpty(t, coupon.Effect)
+					for i, terms := range tt.input.Terms {
+						assert.Equal(t, terms.toEntity(), coupon.Terms[i])
+					}
+					if tt.mockSvcError != nil {
+						return entity.Coupon{}, tt.mockSvcError
+					}
+					coupon.ID = "id"
+					if coupon.Code == "" {
+						coupon.Code = "GENERATED1"
+					}
+					return coupon, nil
 				},
 			}
 			api := &API{svc: svcMock}
@@ -242,21 +423,42 @@ func TestAPI_GetCoupons(t *testing.T) {
 			},
 			mockCoupons: []entity.Coupon{
 				{
-					ID:             "1",
-					Code:           "ABCDEF123",
-					Discount:       10,
-					MinBasketValue: 20,
+					ID:    "1",
+					Code:  "ABCDEF123",
+					Terms: []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(20, "EUR")}},
 				},
 				{
-					ID:             "2",
-					Code:           "XYZ987COUPON",
-					Discount:       10,
-					MinBasketValue: 20,
+					ID:    "2",
+					Code:  "XYZ987COUPON",
+					Terms: []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(20, "EUR")}},
 				},
 			},
 			mockSvcError: nil,
 			expectedCode: http.StatusOK,
 		},
+		{
+			name: "success, filtered by labels and creator",
+			input: GetCouponsRequest{
+				Codes:     []string{"ABCDEF123", "XYZ987COUPON"},
+				Labels:    []string{"summer"},
+				CreatedBy: "admin1",
+			},
+			mockCoupons: []entity.Coupon{
+				{
+					ID:          "1",
+					Code:        "ABCDEF123",
+					Terms:       []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(20, "EUR")}},
+					Description: "Summer sale",
+					Labels:      []string{"summer"},
+					CreatedAt:   time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC),
+					CreatedBy:   "admin1",
+					UpdatedAt:   time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC),
+					UpdatedBy:   "admin1",
+				},
+			},
+			expectedCode: http.StatusOK,
+			expectedBody: `"description":"Summer sale","labels":["summer"],"created_at":"2026-06-01T12:00:00Z","created_by":"admin1","updated_at":"2026-06-01T12:00:00Z","updated_by":"admin1"`,
+		},
 		{
 			name: "invalid: no coupon is provided",
 			input: GetCouponsRequest{
@@ -279,12 +481,15 @@ func TestAPI_GetCoupons(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			svcMock := &service.CouponServiceMock{
-				GetCouponsFunc: func(codes []string) ([]entity.Coupon, error) {
+				GetCouponsFunc: func(ctx context.Context, codes []string, filter entity.CouponFilter) ([]entity.Coupon, error) {
 					assert.Equal(t, tt.input.Codes, codes)
+					assert.Equal(t, entity.CouponFilter{Labels: tt.input.Labels, CreatedBy: tt.input.CreatedBy}, filter)
+					principal, _ := identity.FromContext(ctx)
+					assert.Equal(t, []string{"coupons:read", "coupons:apply"}, principal.Permissions)
 					return tt.mockCoupons, tt.mockSvcError
 				},
 			}
-			api := &API{svc: svcMock}
+			api := &API{svc: svcMock, accessPolicy: auth.DefaultAccessPolicy()}
 			router := setupRouter(api)
 
 			body, _ := json.Marshal(tt.input)
@@ -301,3 +506,102 @@ func TestAPI_GetCoupons(t *testing.T) {
 		})
 	}
 }
+
+func TestAPI_IssueSignedCodes(t *testing.T) {
+	tests := []struct {
+		name         string
+		input        IssueSignedCodesRequest
+		mockSvcError error
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name: "success",
+			input: IssueSignedCodesRequest{
+				CampaignID: 7,
+				Discount:   Money{Amount: 500, Currency: "EUR"},
+				ExpiresOn:  "2026-12-31",
+				SerialFrom: 100,
+				Count:      2,
+			},
+			expectedCode: http.StatusCreated,
+			expectedBody: `"codes":["CODE100","CODE101"]`,
+		},
+		{
+			name: "invalid, discount is 0",
+			input: IssueSignedCodesRequest{
+				Discount:  Money{Amount: 0, Currency: "EUR"},
+				ExpiresOn: "2026-12-31",
+				Count:     1,
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "discount should be positive",
+		},
+		{
+			name: "invalid, expiry date format",
+			input: IssueSignedCodesRequest{
+				Discount:  Money{Amount: 500, Currency: "EUR"},
+				ExpiresOn: "31/12/2026",
+				Count:     1,
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "expires_on must be formatted as YYYY-MM-DD",
+		},
+		{
+			name: "invalid, too many codes",
+			input: IssueSignedCodesRequest{
+				Discount:  Money{Amount: 500, Currency: "EUR"},
+				ExpiresOn: "2026-12-31",
+				Count:     1001,
+			},
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "count should be between 1 and 1000",
+		},
+		{
+			name: "signed codes not enabled",
+			input: IssueSignedCodesRequest{
+				Discount:  Money{Amount: 500, Currency: "EUR"},
+				ExpiresOn: "2026-12-31",
+				Count:     1,
+			},
+			mockSvcError: pkg.Errorf(pkg.ENOTIMPLEMENTED, "signed codes are not enabled", nil),
+			expectedCode: http.StatusNotImplemented,
+			expectedBody: "signed codes are not enabled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svcMock := &service.CouponServiceMock{
+				IssueSignedCodesFunc: func(ctx context.Context, claims signedcode.Claims, count int) ([]string, error) {
+					assert.Equal(t, tt.input.CampaignID, claims.CampaignID)
+					assert.Equal(t, tt.input.Discount.toEntity(), claims.Discount)
+					assert.Equal(t, tt.input.ExpiresOn, claims.ExpiresOn.Format(time.DateOnly))
+					assert.Equal(t, tt.input.SerialFrom, claims.Serial)
+					if tt.mockSvcError != nil {
+						return nil, tt.mockSvcError
+					}
+					codes := make([]string, count)
+					for i := range codes {
+						codes[i] = fmt.Sprintf("CODE%d", claims.Serial+uint32(i))
+					}
+					return codes, nil
+				},
+			}
+			api := &API{svc: svcMock}
+			router := setupRouter(api)
+
+			body, _ := json.Marshal(tt.input)
+			req := httptest.NewRequest(http.MethodPost, "/coupon/signed", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			rec := httptest.NewRecorder()
+
+			router.ServeHTTP(rec, req)
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			if tt.expectedBody != "" {
+				assert.Contains(t, rec.Body.String(), tt.expectedBody)
+			}
+		})
+	}
+}
@@ -1,6 +1,11 @@
 package api
 
 import (
+	"context"
+	"fmt"
+
+	"coupon_service/internal/api/auth"
+	"coupon_service/internal/identity"
 	"coupon_service/pkg"
 	"github.com/gin-gonic/gin"
 )
@@ -9,3 +14,22 @@ import (
 func WebErr(c *gin.Context, err error) {
 	c.JSON(pkg.ErrorStatusCode(pkg.ErrorCode(err)), err)
 }
+
+// requestContext returns the context of the request carrying its ID and the caller set by the auth middlewares,
+// with the permissions the access policy grants it.
+func (a *API) requestContext(c *gin.Context) context.Context {
+	ctx := c.Request.Context()
+	var p identity.Principal
+	if userID, ok := c.Get("user_id"); ok && userID != nil {
+		p.UserID = fmt.Sprint(userID)
+	}
+	p.Roles = c.GetStringSlice("roles")
+	p.TenantID = c.GetString("tenant_id")
+	for _, perm := range auth.Permissions {
+		if a.accessPolicy.Granted(c, perm) {
+			p.Permissions = append(p.Permissions, string(perm))
+		}
+	}
+	ctx = identity.WithRequestID(ctx, c.GetString("request_id"))
+	return identity.NewContext(ctx, p)
+}
@@ -0,0 +1,130 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"coupon_service/internal/idempotency"
+	"coupon_service/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	// maxIdempotencyKeyLength bounds the length of idempotency keys, UUIDs being recommended.
+	maxIdempotencyKeyLength = 255
+)
+
+// idempotent answers retries of POST requests made with the same Idempotency-Key header with the
+// response of the first request, for ttl. Keys are scoped to the tenant and the user, and reusing a key with a
+// different request is rejected. Server errors and 429 Too Many Requests are not stored, so such requests can be retried.
+func idempotent(store idempotency.Store, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			WebErr(c, pkg.Errorf(pkg.EINVALID, "Idempotency-Key must be at most 255 characters long", nil))
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			WebErr(c, pkg.Errorf(pkg.EINVALID, "invalid request body", err))
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var userID string
+		if id, ok := c.Get("user_id"); ok && id != nil {
+			userID = fmt.Sprint(id)
+		}
+		key = scopedIdempotencyKey(c.GetString("tenant_id"), userID, key)
+		fingerprint := requestFingerprint(c.Request.Method, c.FullPath(), body)
+
+		ctx := c.Request.Context()
+		record, reserved, err := store.Reserve(ctx, key, fingerprint, ttl)
+		if err != nil {
+			WebErr(c, pkg.Errorf(pkg.EINTERNAL, "failed to check Idempotency-Key", err))
+			c.Abort()
+			return
+		}
+		if !reserved {
+			replay(c, record, fingerprint)
+			return
+		}
+
+		recorder := &bodyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		status := c.Writer.Status()
+		if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests {
+			err = store.Release(ctx, key)
+		} else {
+			err = store.Complete(ctx, key, idempotency.Response{
+				Status:      status,
+				ContentType: c.Writer.Header().Get("Content-Type"),
+				Body:        recorder.body.Bytes(),
+			}, ttl)
+		}
+		if err != nil {
+			log.Printf("failed to store the response of Idempotency-Key: %v", err)
+		}
+	}
+}
+
+// replay answers a request whose idempotency key is already used.
+func replay(c *gin.Context, record idempotency.Record, fingerprint string) {
+	defer c.Abort()
+	switch {
+	case record.Fingerprint != fingerprint:
+		WebErr(c, pkg.Errorf(pkg.EUNPROCESSABLEENTITY, "Idempotency-Key was already used with a different request", nil))
+	case record.Response == nil:
+		WebErr(c, pkg.Errorf(pkg.ECONFLICT, "a request with the same Idempotency-Key is still being processed", nil))
+	default:
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(record.Response.Status, record.Response.ContentType, record.Response.Body)
+	}
+}
+
+// scopedIdempotencyKey scopes the key to the tenant and the user, prefixing both with their length so that
+// different tenants, users and keys never make the same scoped key.
+func scopedIdempotencyKey(tenantID, userID, key string) string {
+	return strconv.Itoa(len(tenantID)) + ":" + tenantID + strconv.Itoa(len(userID)) + ":" + userID + key
+}
+
+// requestFingerprint identifies a request by its method, route and body.
+func requestFingerprint(method, route string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + route + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// bodyRecorder copies the response body written through it.
+type bodyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
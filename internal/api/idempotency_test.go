@@ -0,0 +1,167 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"coupon_service/internal/bruteforce"
+	"coupon_service/internal/idempotency"
+	"coupon_service/pkg"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotent(t *testing.T) {
+	var calls atomic.Int32
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user_id", c.GetHeader("X-User"))
+		c.Set("tenant_id", c.GetHeader("X-Tenant"))
+	}, idempotent(idempotency.NewMemoryStore(), time.Hour))
+	r.POST("/coupon", func(c *gin.Context) {
+		n := calls.Add(1)
+		if n == 1 {
+			c.JSON(http.StatusCreated, gin.H{"call": n})
+			return
+		}
+		WebErr(c, pkg.Errorf(pkg.ECONFLICT, "coupon already exists", nil))
+	})
+	r.POST("/failing", func(c *gin.Context) {
+		calls.Add(1)
+		WebErr(c, pkg.Errorf(pkg.EINTERNAL, "save coupon error", nil))
+	})
+	r.POST("/limited", func(c *gin.Context) {
+		if calls.Add(1)%2 == 1 {
+			c.Header("Retry-After", "1")
+			WebErr(c, pkg.Errorf(pkg.ETOOMANYREQUESTS, "too many requests", nil))
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	post := func(path, user, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("X-User", user)
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := post("/coupon", "alice", "key-1", `{"code":"SAVE10"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, `{"call":1}`, rec.Body.String())
+
+	// retries are answered with the first response
+	rec = post("/coupon", "alice", "key-1", `{"code":"SAVE10"}`)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, `{"call":1}`, rec.Body.String())
+	assert.Equal(t, "true", rec.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.Equal(t, int32(1), calls.Load())
+
+	// reusing the key with another payload is rejected
+	rec = post("/coupon", "alice", "key-1", `{"code":"SAVE20"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), "Idempotency-Key was already used with a different request")
+
+	// keys are scoped to the user
+	rec = post("/coupon", "bob", "key-1", `{"code":"SAVE10"}`)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, int32(2), calls.Load())
+
+	// and to the tenant
+	req := httptest.NewRequest(http.MethodPost, "/coupon", strings.NewReader(`{"code":"SAVE10"}`))
+	req.Header.Set("X-User", "alice")
+	req.Header.Set("X-Tenant", "acme")
+	req.Header.Set("Idempotency-Key", "key-1")
+	rec = httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, int32(3), calls.Load())
+
+	// whatever characters the user and the key contain
+	post("/coupon", "alice", "key:1", `{"code":"SAVE10"}`)
+	rec = post("/coupon", "alice:key", "1", `{"code":"SAVE10"}`)
+	assert.Empty(t, rec.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, int32(5), calls.Load())
+
+	// requests without key are processed every time
+	post("/coupon", "alice", "", `{"code":"SAVE10"}`)
+	assert.Equal(t, int32(6), calls.Load())
+
+	// server errors are not stored, so the request can be retried
+	post("/failing", "alice", "key-2", `{}`)
+	rec = post("/failing", "alice", "key-2", `{}`)
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Empty(t, rec.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, int32(8), calls.Load())
+
+	// nor are 429 Too Many Requests, so the request can be retried once the limit is lifted
+	rec = post("/limited", "alice", "key-3", `{}`)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	rec = post("/limited", "alice", "key-3", `{}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Empty(t, rec.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, int32(10), calls.Load())
+
+	rec = post("/coupon", "alice", strings.Repeat("k", 256), `{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestIdempotent_InProgress(t *testing.T) {
+	store := idempotency.NewMemoryStore()
+	fingerprint := requestFingerprint(http.MethodPost, "/coupon", []byte(`{}`))
+	_, _, err := store.Reserve(t.Context(), scopedIdempotencyKey("", "", "key"), fingerprint, time.Hour)
+	assert.NoError(t, err)
+
+	r := gin.New()
+	r.POST("/coupon", idempotent(store, time.Hour), func(c *gin.Context) {
+		t.Error("requests in progress should not be processed again")
+	})
+	req := httptest.NewRequest(http.MethodPost, "/coupon", strings.NewReader(`{}`))
+	req.Header.Set("Idempotency-Key", "key")
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "a request with the same Idempotency-Key is still being processed")
+}
+
+func TestIdempotent_LockedOut(t *testing.T) {
+	guard := bruteforce.New(bruteforce.NewMemoryStore(), bruteforce.Config{
+		Threshold:   1,
+		BaseLockout: time.Minute,
+		MaxLockout:  time.Hour,
+		Window:      time.Hour,
+	})
+	store := idempotency.NewMemoryStore()
+	r := gin.New()
+	r.POST("/coupon/validation", bruteForceProtection(guard), idempotent(store, time.Hour), func(c *gin.Context) {
+		WebErr(c, pkg.Errorf(pkg.ENOTFOUND, "coupon not found", nil))
+	})
+	post := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/coupon/validation", strings.NewReader(`{"code":"GUESS"}`))
+		req.Header.Set("Idempotency-Key", key)
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusNotFound, post("key-1").Code)
+	rec := post("key-2")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Retry-After"))
+
+	// the lockout does not use up the key, which stays free for a retry once the lockout expires
+	fingerprint := requestFingerprint(http.MethodPost, "/coupon/validation", []byte(`{"code":"GUESS"}`))
+	_, reserved, err := store.Reserve(t.Context(), scopedIdempotencyKey("", "", "key-2"), fingerprint, time.Hour)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"coupon_service/internal/entity"
+	"coupon_service/pkg"
+)
+
+// Money is an amount in the minor units (e.g. cents) of an ISO 4217 currency.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func (m Money) toEntity() entity.Money {
+	return entity.NewMoney(m.Amount, m.Currency)
+}
+
+func newMoney(m entity.Money) Money {
+	return Money{Amount: m.Amount, Currency: m.Currency}
+}
+
+// validatePositive checks that the amount is positive and the currency is a valid ISO 4217 code.
+func (m Money) validatePositive(field string) error {
+	if m.Amount <= 0 {
+		return pkg.Errorf(pkg.EINVALID, field+" should be positive", nil)
+	}
+	if !pkg.IsCurrencyCode(m.toEntity().Currency) {
+		return pkg.Errorf(pkg.EINVALID, field+" currency must be an ISO 4217 code", nil)
+	}
+	return nil
+}
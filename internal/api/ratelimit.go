@@ -0,0 +1,46 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"coupon_service/internal/ratelimit"
+	"coupon_service/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimit limits the requests of every client, identified by its user ID or else by its IP,
+// reporting its quota in RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers.
+// A nil limiter lets every request through.
+func rateLimit(limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limiter == nil {
+			c.Next()
+			return
+		}
+
+		key := "ip:" + c.ClientIP()
+		if userID, ok := c.Get("user_id"); ok && userID != nil {
+			key = "user:" + fmt.Sprint(userID)
+		}
+
+		res := limiter.Allow(key)
+		c.Header("RateLimit-Limit", strconv.Itoa(res.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+		c.Header("RateLimit-Reset", seconds(res.Reset))
+		if !res.Allowed {
+			c.Header("Retry-After", seconds(res.RetryAfter))
+			WebErr(c, pkg.Errorf(pkg.ETOOMANYREQUESTS, "rate limit exceeded, retry later", nil))
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// seconds formats a duration as a number of seconds, rounded up.
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"coupon_service/internal/ratelimit"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if user := c.GetHeader("X-User"); user != "" {
+			c.Set("user_id", user)
+		}
+	})
+	r.GET("/limited", rateLimit(ratelimit.NewLimiter(ratelimit.Limit{PerMinute: 6, Burst: 2})), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	request := func(user, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+		req.Header.Set("X-User", user)
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		r.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request("alice", "10.0.0.1")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "10", rec.Header().Get("RateLimit-Reset"))
+
+	// the quota follows the user across IPs
+	assert.Equal(t, http.StatusOK, request("alice", "10.0.0.2").Code)
+	rec = request("alice", "10.0.0.3")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "0", rec.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "10", rec.Header().Get("Retry-After"))
+	assert.Contains(t, rec.Body.String(), `"code":"too_many_requests"`)
+
+	// anonymous clients are limited per IP
+	assert.Equal(t, http.StatusOK, request("", "10.0.0.1").Code)
+	assert.Equal(t, http.StatusOK, request("bob", "10.0.0.1").Code)
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"coupon_service/internal/entity"
+	"coupon_service/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+type RevokeTokenRequest struct {
+	// JTI is the ID of the token, its jti claim.
+	JTI string `json:"jti" example:"5b0d2e47-8f5c-4a1e-a1f4-3c2b9e7d6a10"`
+	// ExpiresAt is the expiry of the token, its exp claim, after which it no longer needs to be remembered.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+type RevokeSubjectTokensRequest struct {
+	Subject string `json:"subject" example:"user123"`
+	// IssuedBefore revokes the tokens issued before this time, now by default.
+	IssuedBefore *time.Time `json:"issued_before,omitempty"`
+}
+
+type TokenRevocationResponse struct {
+	JTI          string     `json:"jti,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	Subject      string     `json:"subject,omitempty"`
+	IssuedBefore *time.Time `json:"issued_before,omitempty"`
+}
+
+func newTokenRevocationResponse(r entity.TokenRevocation) TokenRevocationResponse {
+	return TokenRevocationResponse{
+		JTI:          r.TokenID,
+		ExpiresAt:    optionalTime(r.ExpiresAt),
+		Subject:      r.Subject,
+		IssuedBefore: optionalTime(r.IssuedBefore),
+	}
+}
+
+// RevokeToken godoc
+// @Summary      Revoke a bearer token
+// @Description  Revokes the bearer token of the tenant of the caller with the given ID, which is rejected from then on until its expiry. Revocations are picked up by the other instances of the service within REVOCATION_CACHE_TTL.
+// @Tags         revocations
+// @Accept       json
+// @Security     BearerAuth
+// @Produce      json
+// @Param        request body RevokeTokenRequest true "ID and optional expiry of the token"
+// @Param        Idempotency-Key header string false "Key identifying retries of the request, whose first response is replayed"
+// @Success      201 {object} TokenRevocationResponse
+// @Failure      400 {object} pkg.Error
+// @Success      401
+// @Failure      403
+// @Failure      429 {object} pkg.Error
+// @Failure      501 {object} pkg.Error
+// @Router       /revocations/tokens [post]
+func (a *API) RevokeToken(c *gin.Context) {
+	input := RevokeTokenRequest{}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		WebErr(c, pkg.Errorf(pkg.EINVALID, "invalid request body", err))
+		return
+	}
+
+	var expiresAt time.Time
+	if input.ExpiresAt != nil {
+		expiresAt = *input.ExpiresAt
+	}
+	r, err := a.svc.RevokeToken(a.requestContext(c), input.JTI, expiresAt)
+	if err != nil {
+		WebErr(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, newTokenRevocationResponse(r))
+}
+
+// RevokeSubjectTokens godoc
+// @Summary      Revoke the bearer tokens of a subject
+// @Description  Revokes every bearer token of the subject in the tenant of the caller issued before the given time, now by default, such as all the tokens of a user whose credentials leaked. Tokens without an issue time (iat) are revoked as well.
+// @Tags         revocations
+// @Accept       json
+// @Security     BearerAuth
+// @Produce      json
+// @Param        request body RevokeSubjectTokensRequest true "Subject and optional time before which its tokens were issued"
+// @Param        Idempotency-Key header string false "Key identifying retries of the request, whose first response is replayed"
+// @Success      201 {object} TokenRevocationResponse
+// @Failure      400 {object} pkg.Error
+// @Success      401
+// @Failure      403
+// @Failure      429 {object} pkg.Error
+// @Failure      501 {object} pkg.Error
+// @Router       /revocations/subjects [post]
+func (a *API) RevokeSubjectTokens(c *gin.Context) {
+	input := RevokeSubjectTokensRequest{}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		WebErr(c, pkg.Errorf(pkg.EINVALID, "invalid request body", err))
+		return
+	}
+
+	var issuedBefore time.Time
+	if input.IssuedBefore != nil {
+		issuedBefore = *input.IssuedBefore
+	}
+	r, err := a.svc.RevokeSubjectTokens(a.requestContext(c), input.Subject, issuedBefore)
+	if err != nil {
+		WebErr(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, newTokenRevocationResponse(r))
+}
@@ -0,0 +1,128 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"coupon_service/internal/entity"
+	"coupon_service/internal/service"
+	"coupon_service/pkg"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAPI_RevokeToken(t *testing.T) {
+	expiresAt := time.Date(2026, 6, 1, 13, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		body          string
+		wantExpiresAt time.Time
+		mockSvcError  error
+		expectedCode  int
+		expectedBody  string
+	}{
+		{
+			name:          "revoked until its expiry",
+			body:          `{"jti": "token-1", "expires_at": "2026-06-01T13:00:00Z"}`,
+			wantExpiresAt: expiresAt,
+			expectedCode:  http.StatusCreated,
+			expectedBody:  `{"jti":"token-1","expires_at":"2026-06-01T13:00:00Z"}`,
+		},
+		{
+			name:         "expiry not given",
+			body:         `{"jti": "token-1"}`,
+			expectedCode: http.StatusCreated,
+			expectedBody: `"jti":"token-1"`,
+		},
+		{
+			name:         "invalid, rejected by the service",
+			body:         `{"jti": "token-1"}`,
+			mockSvcError: pkg.Errorf(pkg.EINVALID, "token has already expired", nil),
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "token has already expired",
+		},
+		{
+			name:         "invalid, expiry is not a timestamp",
+			body:         `{"jti": "token-1", "expires_at": "tomorrow"}`,
+			expectedCode: http.StatusBadRequest,
+			expectedBody: "invalid request body",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svcMock := &service.CouponServiceMock{
+				RevokeTokenFunc: func(ctx context.Context, jti string, at time.Time) (entity.TokenRevocation, error) {
+					assert.Equal(t, "token-1", jti)
+					assert.True(t, tt.wantExpiresAt.Equal(at))
+					if tt.mockSvcError != nil {
+						return entity.TokenRevocation{}, tt.mockSvcError
+					}
+					return entity.TokenRevocation{TokenID: jti, ExpiresAt: at.UTC()}, nil
+				},
+			}
+			api := &API{svc: svcMock}
+			router := setupRouter(api)
+			router.POST("/revocations/tokens", api.RevokeToken)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/revocations/tokens", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+		})
+	}
+}
+
+func TestAPI_RevokeSubjectTokens(t *testing.T) {
+	issuedBefore := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		body         string
+		mockSvcError error
+		expectedCode int
+		expectedBody string
+	}{
+		{
+			name:         "revoked",
+			body:         `{"subject": "user1", "issued_before": "2026-06-01T12:00:00Z"}`,
+			expectedCode: http.StatusCreated,
+			expectedBody: `{"subject":"user1","issued_before":"2026-06-01T12:00:00Z"}`,
+		},
+		{
+			name:         "revocation not enabled",
+			body:         `{"subject": "user1", "issued_before": "2026-06-01T12:00:00Z"}`,
+			mockSvcError: pkg.Errorf(pkg.ENOTIMPLEMENTED, "token revocation is not enabled", nil),
+			expectedCode: http.StatusNotImplemented,
+			expectedBody: "token revocation is not enabled",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svcMock := &service.CouponServiceMock{
+				RevokeSubjectTokensFunc: func(ctx context.Context, subject string, before time.Time) (entity.TokenRevocation, error) {
+					assert.Equal(t, "user1", subject)
+					assert.True(t, issuedBefore.Equal(before))
+					if tt.mockSvcError != nil {
+						return entity.TokenRevocation{}, tt.mockSvcError
+					}
+					return entity.TokenRevocation{Subject: subject, IssuedBefore: before}, nil
+				},
+			}
+			api := &API{svc: svcMock}
+			router := setupRouter(api)
+			router.POST("/revocations/subjects", api.RevokeSubjectTokens)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/revocations/subjects", strings.NewReader(tt.body)))
+
+			assert.Equal(t, tt.expectedCode, rec.Code)
+			assert.Contains(t, rec.Body.String(), tt.expectedBody)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"coupon_service/internal/signedcode"
+	"coupon_service/pkg"
+	"github.com/gin-gonic/gin"
+)
+
+// maxSignedCodesPerRequest bounds how many signed codes a single request issues.
+const maxSignedCodesPerRequest = 1000
+
+type IssueSignedCodesRequest struct {
+	CampaignID uint32 `json:"campaign_id"`
+	Discount   Money  `json:"discount"`
+	ExpiresOn  string `json:"expires_on" example:"2026-12-31"`
+	SerialFrom uint32 `json:"serial_from"`
+	Count      int    `json:"count"`
+}
+
+type IssueSignedCodesResponse struct {
+	Codes []string `json:"codes"`
+}
+
+// IssueSignedCodes godoc
+// @Summary      Issue signed coupon codes
+// @Description  Issues fixed discount codes signed with the active key, which encode their campaign, discount, expiry and serial and need no stored coupon. Each code can be applied once.
+// @Tags         coupons
+// @Accept       json
+// @Security     BearerAuth
+// @Produce      json
+// @Param        request body IssueSignedCodesRequest true "Campaign, discount, expiry and serials of the codes"
+// @Param        Idempotency-Key header string false "Key identifying retries of the request, whose first response is replayed"
+// @Success      201 {object} IssueSignedCodesResponse
+// @Failure      400 {object} pkg.Error
+// @Success      401
+// @Failure      403
+// @Failure      429 {object} pkg.Error
+// @Failure      501 {object} pkg.Error
+// @Router       /coupon/signed [post]
+func (a *API) IssueSignedCodes(c *gin.Context) {
+	input := IssueSignedCodesRequest{}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		WebErr(c, pkg.Errorf(pkg.EINVALID, "invalid request body", err))
+		return
+	}
+
+	if err := input.Discount.validatePositive("discount"); err != nil {
+		WebErr(c, err)
+		return
+	}
+
+	expiresOn, err := time.Parse(time.DateOnly, input.ExpiresOn)
+	if err != nil {
+		WebErr(c, pkg.Errorf(pkg.EINVALID, "expires_on must be formatted as YYYY-MM-DD", err))
+		return
+	}
+
+	if input.Count < 1 || input.Count > maxSignedCodesPerRequest {
+		WebErr(c, pkg.Errorf(pkg.EINVALID, "count should be between 1 and 1000", nil))
+		return
+	}
+
+	codes, err := a.svc.IssueSignedCodes(a.requestContext(c), signedcode.Claims{
+		CampaignID: input.CampaignID,
+		Discount:   input.Discount.toEntity(),
+		ExpiresOn:  expiresOn,
+		Serial:     input.SerialFrom,
+	}, input.Count)
+	if err != nil {
+		WebErr(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, IssueSignedCodesResponse{Codes: codes})
+}
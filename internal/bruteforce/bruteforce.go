@@ -0,0 +1,100 @@
+// Package bruteforce locks out callers repeatedly looking up unknown coupon codes.
+//
+// Every failed lookup is counted per key (e.g. per user and per IP). Once a key reaches
+// the threshold of failures it is locked out, for a duration doubling with every further
+// failure. Failures are forgotten after a window without any.
+package bruteforce
+
+import (
+	"context"
+	"time"
+)
+
+// Store keeps the failure counters and lockouts of keys.
+type Store interface {
+	// RecordFailure counts a failure of key and returns its number of failures. The count is
+	// forgotten once window elapses without failure.
+	RecordFailure(ctx context.Context, key string, window time.Duration) (int64, error)
+	// Lock locks key out for d.
+	Lock(ctx context.Context, key string, d time.Duration) error
+	// LockedFor returns how long key remains locked out, zero when it is not.
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+}
+
+// Config defines when and for how long keys are locked out.
+type Config struct {
+	// Threshold is the number of failures locking a key out for the first time.
+	Threshold int64
+	// BaseLockout is the duration of the first lockout, doubled by every further failure.
+	BaseLockout time.Duration
+	// MaxLockout caps the duration of lockouts.
+	MaxLockout time.Duration
+	// Window is the time without failure after which the failures of a key are forgotten.
+	Window time.Duration
+}
+
+// DefaultConfig returns the configuration used when none is given.
+func DefaultConfig() Config {
+	return Config{
+		Threshold:   5,
+		BaseLockout: 30 * time.Second,
+		MaxLockout:  time.Hour,
+		Window:      time.Hour,
+	}
+}
+
+// Guard tracks failures and lockouts in a Store.
+type Guard struct {
+	store Store
+	cfg   Config
+}
+
+func New(store Store, cfg Config) *Guard {
+	return &Guard{store: store, cfg: cfg}
+}
+
+// Check returns how long the most restricted of keys remains locked out, zero when none is.
+func (g *Guard) Check(ctx context.Context, keys ...string) (time.Duration, error) {
+	var longest time.Duration
+	for _, key := range keys {
+		d, err := g.store.LockedFor(ctx, key)
+		if err != nil {
+			return 0, err
+		}
+		longest = max(longest, d)
+	}
+	return longest, nil
+}
+
+// Fail records a failure of every key, locks out the keys reaching the threshold
+// and returns the longest lockout applied.
+func (g *Guard) Fail(ctx context.Context, keys ...string) (time.Duration, error) {
+	var longest time.Duration
+	for _, key := range keys {
+		failures, err := g.store.RecordFailure(ctx, key, g.cfg.Window)
+		if err != nil {
+			return 0, err
+		}
+		d := g.lockout(failures)
+		if d == 0 {
+			continue
+		}
+		if err := g.store.Lock(ctx, key, d); err != nil {
+			return 0, err
+		}
+		longest = max(longest, d)
+	}
+	return longest, nil
+}
+
+// lockout returns the lockout duration after the given number of failures.
+func (g *Guard) lockout(failures int64) time.Duration {
+	if failures < g.cfg.Threshold {
+		return 0
+	}
+	d := g.cfg.BaseLockout
+	for i := g.cfg.Threshold; i < failures && d < g.cfg.MaxLockout; i++ {
+		d *= 2
+	}
+	return min(d, g.cfg.MaxLockout)
+}
@@ -0,0 +1,131 @@
+package bruteforce
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGuard_lockout(t *testing.T) {
+	g := New(nil, Config{Threshold: 3, BaseLockout: time.Second, MaxLockout: 10 * time.Second})
+
+	tests := []struct {
+		failures int64
+		want     time.Duration
+	}{
+		{failures: 1, want: 0},
+		{failures: 2, want: 0},
+		{failures: 3, want: time.Second},
+		{failures: 4, want: 2 * time.Second},
+		{failures: 5, want: 4 * time.Second},
+		{failures: 6, want: 8 * time.Second},
+		{failures: 7, want: 10 * time.Second},
+		{failures: 100, want: 10 * time.Second},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, g.lockout(tt.failures), tt.failures)
+	}
+}
+
+func TestGuard_MemoryStore(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	g := New(store, Config{Threshold: 2, BaseLockout: time.Second, MaxLockout: time.Minute, Window: time.Hour})
+	ctx := context.Background()
+
+	d, err := g.Fail(ctx, "user:alice", "ip:1.2.3.4")
+	assert.NoError(t, err)
+	assert.Zero(t, d)
+	d, err = g.Check(ctx, "user:alice", "ip:1.2.3.4")
+	assert.NoError(t, err)
+	assert.Zero(t, d)
+
+	// the second failure of the IP locks it out, even for another user
+	_, err = g.Fail(ctx, "user:bob", "ip:1.2.3.4")
+	assert.NoError(t, err)
+	d, err = g.Check(ctx, "user:alice", "ip:1.2.3.4")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, d)
+	d, err = g.Check(ctx, "user:bob", "ip:5.6.7.8")
+	assert.NoError(t, err)
+	assert.Zero(t, d)
+
+	// further failures double the lockout
+	d, err = g.Fail(ctx, "user:alice")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Second, d)
+	d, err = g.Fail(ctx, "user:alice")
+	assert.NoError(t, err)
+	assert.Equal(t, 2*time.Second, d)
+
+	now = now.Add(1500 * time.Millisecond)
+	d, err = g.Check(ctx, "user:alice")
+	assert.NoError(t, err)
+	assert.Equal(t, 500*time.Millisecond, d)
+
+	// failures are forgotten after the window
+	now = now.Add(2 * time.Hour)
+	d, err = g.Check(ctx, "user:alice")
+	assert.NoError(t, err)
+	assert.Zero(t, d)
+	d, err = g.Fail(ctx, "user:alice")
+	assert.NoError(t, err)
+	assert.Zero(t, d)
+}
+
+func TestMemoryStore_sweep(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_, _ = store.RecordFailure(ctx, "forgotten", time.Minute)
+	_, _ = store.RecordFailure(ctx, "locked", time.Minute)
+	_ = store.Lock(ctx, "locked", time.Hour)
+	now = now.Add(2 * time.Minute)
+	store.sweep(now)
+
+	assert.NotContains(t, store.entries, "forgotten")
+	assert.Contains(t, store.entries, "locked")
+}
+
+func TestRedisStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	store := NewRedisStore(client, "coupon:")
+	ctx := context.Background()
+
+	failures, err := store.RecordFailure(ctx, "user:alice", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), failures)
+	failures, err = store.RecordFailure(ctx, "user:alice", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), failures)
+
+	d, err := store.LockedFor(ctx, "user:alice")
+	assert.NoError(t, err)
+	assert.Zero(t, d)
+
+	assert.NoError(t, store.Lock(ctx, "user:alice", 30*time.Second))
+	d, err = store.LockedFor(ctx, "user:alice")
+	assert.NoError(t, err)
+	assert.Equal(t, 30*time.Second, d)
+	assert.True(t, mr.Exists("coupon:lock:user:alice"))
+
+	mr.FastForward(time.Minute)
+	d, err = store.LockedFor(ctx, "user:alice")
+	assert.NoError(t, err)
+	assert.Zero(t, d)
+	failures, err = store.RecordFailure(ctx, "user:alice", time.Minute)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), failures)
+
+	mr.Close()
+	_, err = store.RecordFailure(ctx, "user:alice", time.Minute)
+	assert.Error(t, err)
+}
@@ -0,0 +1,91 @@
+package bruteforce
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepEvery is the number of recorded failures after which forgotten entries are removed.
+const sweepEvery = 1024
+
+type memoryEntry struct {
+	failures    int64
+	forgetAt    time.Time
+	lockedUntil time.Time
+}
+
+// MemoryStore is a Store keeping counters in memory, so they are neither shared between
+// instances of the service nor kept across restarts.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries map[string]*memoryEntry
+	records int
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]*memoryEntry),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) RecordFailure(_ context.Context, key string, window time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.records++
+	if s.records%sweepEvery == 0 {
+		s.sweep(now)
+	}
+
+	e := s.entry(key, now)
+	e.failures++
+	e.forgetAt = now.Add(window)
+	return e.failures, nil
+}
+
+func (s *MemoryStore) Lock(_ context.Context, key string, d time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	e := s.entry(key, now)
+	e.lockedUntil = now.Add(d)
+	return nil
+}
+
+func (s *MemoryStore) LockedFor(_ context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		return 0, nil
+	}
+	return max(e.lockedUntil.Sub(s.now()), 0), nil
+}
+
+// entry returns the entry of key, resetting its failures when they are forgotten.
+func (s *MemoryStore) entry(key string, now time.Time) *memoryEntry {
+	e, ok := s.entries[key]
+	if !ok {
+		e = &memoryEntry{}
+		s.entries[key] = e
+	}
+	if !e.forgetAt.IsZero() && !now.Before(e.forgetAt) {
+		e.failures = 0
+	}
+	return e
+}
+
+// sweep removes the entries whose failures are forgotten and which are not locked out.
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, e := range s.entries {
+		if !now.Before(e.forgetAt) && !now.Before(e.lockedUntil) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package bruteforce
+
+import (
+	"context"
+	"time"
+
+	"coupon_service/pkg"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore is a Store keeping counters in Redis, so they are shared between instances of the service.
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisStore creates a RedisStore prefixing its keys with prefix.
+func NewRedisStore(client redis.UniversalClient, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix}
+}
+
+func (s *RedisStore) RecordFailure(ctx context.Context, key string, window time.Duration) (int64, error) {
+	pipe := s.client.TxPipeline()
+	incr := pipe.Incr(ctx, s.prefix+"failures:"+key)
+	pipe.PExpire(ctx, s.prefix+"failures:"+key, window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, pkg.Errorf(pkg.EINTERNAL, "failed to record failed attempt", err)
+	}
+	return incr.Val(), nil
+}
+
+func (s *RedisStore) Lock(ctx context.Context, key string, d time.Duration) error {
+	if err := s.client.Set(ctx, s.prefix+"lock:"+key, 1, d).Err(); err != nil {
+		return pkg.Errorf(pkg.EINTERNAL, "failed to lock out", err)
+	}
+	return nil
+}
+
+func (s *RedisStore) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := s.client.PTTL(ctx, s.prefix+"lock:"+key).Result()
+	if err != nil {
+		return 0, pkg.Errorf(pkg.EINTERNAL, "failed to read lockout", err)
+	}
+	// negative TTLs report missing keys or keys without expiry, which Lock never sets
+	return max(ttl, 0), nil
+}
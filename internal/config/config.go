@@ -1,7 +1,9 @@
 package config
 
 import (
+	"net"
 	"strings"
+	"time"
 
 	"coupon_service/pkg"
 
@@ -20,11 +22,84 @@ type Config struct {
 	Env Env
 }
 type Env struct {
-	Environment string `env:"API_ENV,notEmpty"`
-	LogLevel    string `env:"API_LOG_LEVEL" envDefault:"info"`
-	Port        int    `env:"API_PORT"`
-	AuthConfig  struct {
-		JWTSecret string `env:"JWT_SECRET,required"`
+	Environment    string        `env:"API_ENV,notEmpty"`
+	LogLevel       string        `env:"API_LOG_LEVEL" envDefault:"info"`
+	Port           int           `env:"API_PORT"`
+	Rounding       string        `env:"API_ROUNDING_MODE" envDefault:"half_up"`
+	IdempotencyTTL time.Duration `env:"API_IDEMPOTENCY_TTL" envDefault:"24h"`
+	AuditKey       string        `env:"AUDIT_HMAC_KEY"`
+	TrustedProxies []string      `env:"API_TRUSTED_PROXIES" envSeparator:","`
+	AuthConfig     struct {
+		TokenMode   string        `env:"AUTH_TOKEN_MODE" envDefault:"jwt"`
+		JWTSecret   string        `env:"JWT_SECRET"`
+		JWKSURL     string        `env:"JWT_JWKS_URL"`
+		JWKSRefresh time.Duration `env:"JWT_JWKS_REFRESH" envDefault:"1h"`
+		Algorithms  []string      `env:"JWT_ALGORITHMS" envSeparator:","`
+		Issuers     []string      `env:"JWT_ISSUERS" envSeparator:","`
+		Audiences   []string      `env:"JWT_AUDIENCES" envSeparator:","`
+		Leeway      time.Duration `env:"JWT_LEEWAY" envDefault:"30s"`
+		RolesClaim  string        `env:"JWT_ROLES_CLAIM" envDefault:"roles"`
+		PolicyFile  string        `env:"AUTH_POLICY_FILE"`
+		// TenantClaim and TenantHeader tell the tenant of the callers, who all belong to the default tenant when both are empty.
+		TenantClaim  string `env:"TENANT_CLAIM"`
+		TenantHeader string `env:"TENANT_HEADER"`
+
+		// DevMode authenticates the requests without credentials as DevUser, for development and tests only.
+		DevMode     bool     `env:"AUTH_DEV_MODE" envDefault:"false"`
+		DevUser     string   `env:"AUTH_DEV_USER" envDefault:"dev"`
+		DevRoles    []string `env:"AUTH_DEV_ROLES" envSeparator:"," envDefault:"admin"`
+		DevTenantID string   `env:"AUTH_DEV_TENANT"`
+
+		IntrospectionURL          string `env:"AUTH_INTROSPECTION_URL"`
+		IntrospectionClientID     string `env:"AUTH_INTROSPECTION_CLIENT_ID"`
+		IntrospectionClientSecret string `env:"AUTH_INTROSPECTION_CLIENT_SECRET"`
+	}
+	CodePolicy struct {
+		MinLength       int      `env:"COUPON_CODE_MIN_LENGTH" envDefault:"6"`
+		MaxLength       int      `env:"COUPON_CODE_MAX_LENGTH" envDefault:"32"`
+		ASCIIOnly       bool     `env:"COUPON_CODE_ASCII_ONLY" envDefault:"true"`
+		Reserved        []string `env:"COUPON_CODE_RESERVED" envSeparator:","`
+		BlockedWords    []string `env:"COUPON_CODE_BLOCKED_WORDS" envSeparator:","`
+		Profanity       []string `env:"COUPON_CODE_PROFANITY_WORDS" envSeparator:","`
+		GeneratedLength int      `env:"COUPON_CODE_GENERATED_LENGTH" envDefault:"10"`
+		CheckCharacter  bool     `env:"COUPON_CODE_CHECK_CHARACTER" envDefault:"false"`
+	}
+	SignedCodes struct {
+		Keys      []string `env:"SIGNED_CODE_KEYS" envSeparator:","`
+		ActiveKey uint8    `env:"SIGNED_CODE_ACTIVE_KEY"`
+	}
+	BruteForce struct {
+		Store       string        `env:"BRUTEFORCE_STORE" envDefault:"memory"`
+		Threshold   int64         `env:"BRUTEFORCE_THRESHOLD" envDefault:"5"`
+		BaseLockout time.Duration `env:"BRUTEFORCE_BASE_LOCKOUT" envDefault:"30s"`
+		MaxLockout  time.Duration `env:"BRUTEFORCE_MAX_LOCKOUT" envDefault:"1h"`
+		Window      time.Duration `env:"BRUTEFORCE_WINDOW" envDefault:"1h"`
+	}
+	Revocation struct {
+		Store       string        `env:"REVOCATION_STORE" envDefault:"memory"`
+		CacheTTL    time.Duration `env:"REVOCATION_CACHE_TTL" envDefault:"30s"`
+		MaxLifetime time.Duration `env:"REVOCATION_MAX_TOKEN_LIFETIME" envDefault:"24h"`
+	}
+	RateLimit struct {
+		AdminPerMinute int `env:"RATE_LIMIT_ADMIN_PER_MINUTE" envDefault:"60"`
+		AdminBurst     int `env:"RATE_LIMIT_ADMIN_BURST" envDefault:"10"`
+		UserPerMinute  int `env:"RATE_LIMIT_USER_PER_MINUTE" envDefault:"300"`
+		UserBurst      int `env:"RATE_LIMIT_USER_BURST" envDefault:"30"`
+	}
+	TLS struct {
+		CertFile     string `env:"TLS_CERT_FILE"`
+		KeyFile      string `env:"TLS_KEY_FILE"`
+		MinVersion   string `env:"TLS_MIN_VERSION" envDefault:"1.2"`
+		CipherPolicy string `env:"TLS_CIPHER_POLICY" envDefault:"modern"`
+		ClientCAFile string `env:"TLS_CLIENT_CA_FILE"`
+		ClientAuth   string `env:"TLS_CLIENT_AUTH" envDefault:"optional"`
+		// SubjectsFile maps the subjects of client certificates to roles, client certificates authenticating no caller without it.
+		SubjectsFile string `env:"TLS_CLIENT_SUBJECTS_FILE"`
+	}
+	Redis struct {
+		Addr     string `env:"REDIS_ADDR"`
+		Password string `env:"REDIS_PASSWORD"`
+		DB       int    `env:"REDIS_DB"`
 	}
 }
 
@@ -50,6 +125,49 @@ func loadEnv() (Env, error) {
 	}
 	e.Environment = strings.ToLower(e.Environment)
 	e.LogLevel = strings.ToLower(e.LogLevel)
+	e.Rounding = strings.ToLower(e.Rounding)
+	e.BruteForce.Store = strings.ToLower(e.BruteForce.Store)
+	e.Revocation.Store = strings.ToLower(e.Revocation.Store)
+	e.AuthConfig.TokenMode = strings.ToLower(e.AuthConfig.TokenMode)
+	switch e.AuthConfig.TokenMode {
+	case "jwt":
+		if e.AuthConfig.JWTSecret == "" && e.AuthConfig.JWKSURL == "" {
+			return e, pkg.Errorf(pkg.EINTERNAL, "JWT_SECRET or JWT_JWKS_URL is required", nil)
+		}
+	case "introspection":
+		if e.AuthConfig.IntrospectionURL == "" {
+			return e, pkg.Errorf(pkg.EINTERNAL, "AUTH_INTROSPECTION_URL is required by the introspection token mode", nil)
+		}
+	default:
+		return e, pkg.Errorf(pkg.EINTERNAL, "AUTH_TOKEN_MODE must be jwt or introspection", nil)
+	}
+	// the development auth mode lets anyone in, so it must never be enabled where the service is reachable by real callers
+	if e.AuthConfig.DevMode && e.Environment != DevelopmentEnv && e.Environment != TestEnv {
+		return e, pkg.Errorf(pkg.EINTERNAL, "AUTH_DEV_MODE is only allowed with API_ENV=development or test, not "+e.Environment, nil)
+	}
+	// with the header alone, callers would choose their tenant and reach the coupons of every other tenant
+	if e.AuthConfig.TenantHeader != "" && e.AuthConfig.TenantClaim == "" && e.Environment != DevelopmentEnv && e.Environment != TestEnv {
+		return e, pkg.Errorf(pkg.EINTERNAL, "TENANT_HEADER requires TENANT_CLAIM unless API_ENV is development or test", nil)
+	}
+	if (e.TLS.CertFile == "") != (e.TLS.KeyFile == "") {
+		return e, pkg.Errorf(pkg.EINTERNAL, "TLS_CERT_FILE and TLS_KEY_FILE must be set together", nil)
+	}
+	if e.TLS.ClientCAFile != "" && e.TLS.CertFile == "" {
+		return e, pkg.Errorf(pkg.EINTERNAL, "TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE", nil)
+	}
+	if e.TLS.SubjectsFile != "" && e.TLS.ClientCAFile == "" {
+		return e, pkg.Errorf(pkg.EINTERNAL, "TLS_CLIENT_SUBJECTS_FILE requires TLS_CLIENT_CA_FILE", nil)
+	}
+	for i, proxy := range e.TrustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return e, pkg.Errorf(pkg.EINTERNAL, "API_TRUSTED_PROXIES must list IP addresses or CIDR ranges, not "+proxy, nil)
+		}
+		e.TrustedProxies[i] = proxy
+	}
+	for i, alg := range e.AuthConfig.Algorithms {
+		e.AuthConfig.Algorithms[i] = strings.ToUpper(strings.TrimSpace(alg))
+	}
 
 	return e, nil
 }
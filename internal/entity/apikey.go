@@ -0,0 +1,26 @@
+package entity
+
+import "time"
+
+// APIKey is a credential of a machine to machine caller, such as the checkout backend.
+// Only the hash of its secret is stored, the secret being shown once when the key is created.
+type APIKey struct {
+	ID string
+	// TenantID is the tenant the callers of the key act for.
+	TenantID string
+	Name     string
+	// Hash is the hex encoded SHA-256 hash of the secret of the key.
+	Hash string
+	// Scopes are the permissions granted to the key.
+	Scopes []string
+	// ExpiresAt is zero for keys that do not expire.
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+	CreatedBy  string
+	LastUsedAt time.Time
+}
+
+// Expired reports whether the key has expired at now.
+func (k APIKey) Expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
@@ -0,0 +1,161 @@
+package entity
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"coupon_service/pkg"
+)
+
+// minAuditKeyLength is the shortest accepted audit key, in bytes.
+const minAuditKeyLength = 32
+
+// AuditAction is the kind of administrative change recorded in an AuditEvent.
+type AuditAction string
+
+const (
+	AuditCouponCreated     AuditAction = "coupon.created"
+	AuditSignedCodesIssued AuditAction = "signed_codes.issued"
+	AuditAPIKeyCreated     AuditAction = "api_key.created"
+	AuditAPIKeyRevoked     AuditAction = "api_key.revoked"
+	AuditTokenRevoked      AuditAction = "token.revoked"
+	AuditSubjectRevoked    AuditAction = "subject_tokens.revoked"
+)
+
+// AuditEvent records an administrative change. Events are append-only and chained per tenant: each event holds the
+// hash of the previous event of its tenant, so editing, removing or reordering stored events breaks the chain, and
+// the chain of a tenant is verified without reading the events of the others. Hashes are keyed with a secret of the
+// server, kept out of the store, so that whoever can write to the store cannot rehash the events they edit.
+type AuditEvent struct {
+	ID       string
+	TenantID string
+	Time     time.Time
+	// Actor is the ID of the user who made the change.
+	Actor  string
+	Action AuditAction
+	// CouponCode is the code of the coupon changed, if any.
+	CouponCode string
+	// Before and After are JSON snapshots of the changed object, empty when it did not exist before or after the change.
+	Before    json.RawMessage
+	After     json.RawMessage
+	RequestID string
+	// PrevHash is the hash of the previous event of the tenant, empty for its first one.
+	PrevHash string
+	Hash     string
+}
+
+// ParseAuditKey returns the base64 encoded key of the audit chain hashes, at least 32 bytes long.
+func ParseAuditKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, pkg.Errorf(pkg.EINVALID, "invalid audit key", err)
+	}
+	if len(key) < minAuditKeyLength {
+		return nil, pkg.Errorf(pkg.EINVALID, "audit key must be at least 32 bytes long", nil)
+	}
+	return key, nil
+}
+
+// ComputeHash returns the hex encoded HMAC-SHA256 of the event content and of the hash of the previous event, keyed with key.
+func (e AuditEvent) ComputeHash(key []byte) string {
+	h := hmac.New(sha256.New, key)
+	for _, field := range [][]byte{
+		[]byte(e.ID),
+		[]byte(e.TenantID),
+		[]byte(e.Time.UTC().Format(time.RFC3339Nano)),
+		[]byte(e.Actor),
+		[]byte(e.Action),
+		[]byte(e.CouponCode),
+		e.Before,
+		e.After,
+		[]byte(e.RequestID),
+		[]byte(e.PrevHash),
+	} {
+		// fields are length prefixed so that moving bytes between fields changes the hash
+		_ = binary.Write(h, binary.BigEndian, uint64(len(field)))
+		h.Write(field)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Chain links the event after prev, whose hash is empty for the first event, and seals it with its hash keyed with key.
+func (e AuditEvent) Chain(prevHash string, key []byte) AuditEvent {
+	e.PrevHash = prevHash
+	e.Hash = e.ComputeHash(key)
+	return e
+}
+
+// AuditVerification is the result of walking the audit chain of a tenant.
+type AuditVerification struct {
+	Valid bool
+	// Checked is the number of events found valid before the first broken link, if any.
+	Checked int
+	// BrokenEventID is the ID of the first event whose link is broken.
+	BrokenEventID string
+	Reason        string
+}
+
+// VerifyAuditChain walks the events of a tenant, oldest first, and reports the first event that does not match
+// its hash keyed with key or does not link to the previous event. head is the hash of the last event of the tenant,
+// read before the events: the chain must reach it, or its last events were removed. Events appended after head
+// was read are verified too.
+func VerifyAuditChain(events []AuditEvent, head string, key []byte) AuditVerification {
+	prevHash := ""
+	reached := head == ""
+	for i, e := range events {
+		var reason string
+		switch {
+		case e.PrevHash != prevHash:
+			reason = "previous hash does not match the hash of the previous event"
+		case !hmac.Equal([]byte(e.Hash), []byte(e.ComputeHash(key))):
+			reason = "hash does not match the event content"
+		}
+		if reason != "" {
+			return AuditVerification{Checked: i, BrokenEventID: e.ID, Reason: reason}
+		}
+		prevHash = e.Hash
+		reached = reached || e.Hash == head
+	}
+	if !reached {
+		v := AuditVerification{Checked: len(events), Reason: "chain ends before its head, the last events were removed"}
+		if len(events) > 0 {
+			v.BrokenEventID = events[len(events)-1].ID
+		}
+		return v
+	}
+	return AuditVerification{Valid: true, Checked: len(events)}
+}
+
+// AuditFilter selects audit events. Zero fields match every event, except TenantID: the events of a single
+// tenant are selected, the default tenant having an empty ID, unless AnyTenant is set.
+type AuditFilter struct {
+	TenantID   string
+	AnyTenant  bool
+	CouponCode string
+	Actor      string
+	// From and To bound the time of the events, both inclusive.
+	From time.Time
+	To   time.Time
+}
+
+// Matches reports whether the event is selected by the filter.
+func (f AuditFilter) Matches(e AuditEvent) bool {
+	switch {
+	case !f.AnyTenant && f.TenantID != e.TenantID:
+		return false
+	case f.CouponCode != "" && f.CouponCode != e.CouponCode:
+		return false
+	case f.Actor != "" && f.Actor != e.Actor:
+		return false
+	case !f.From.IsZero() && e.Time.Before(f.From):
+		return false
+	case !f.To.IsZero() && e.Time.After(f.To):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package entity
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyAuditChain(t *testing.T) {
+	key := []byte("audit-key-of-at-least-32-bytes!!")
+	chain := func() []AuditEvent {
+		start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+		events := []AuditEvent{
+			{ID: "1", Time: start, Actor: "alice", Action: AuditCouponCreated, CouponCode: "SUMMER", After: []byte(`{"Code":"SUMMER"}`)},
+			{ID: "2", Time: start.Add(time.Hour), Actor: "bob", Action: AuditCouponCreated, CouponCode: "WINTER"},
+			{ID: "3", Time: start.Add(2 * time.Hour), Actor: "alice", Action: AuditSignedCodesIssued},
+		}
+		prevHash := ""
+		for i := range events {
+			events[i] = events[i].Chain(prevHash, key)
+			prevHash = events[i].Hash
+		}
+		return events
+	}
+
+	head := chain()[2].Hash
+
+	tests := []struct {
+		name   string
+		tamper func([]AuditEvent) []AuditEvent
+		head   string
+		want   AuditVerification
+	}{
+		{
+			name:   "intact chain",
+			tamper: func(e []AuditEvent) []AuditEvent { return e },
+			head:   head,
+			want:   AuditVerification{Valid: true, Checked: 3},
+		},
+		{
+			name:   "empty chain",
+			tamper: func([]AuditEvent) []AuditEvent { return nil },
+			want:   AuditVerification{Valid: true},
+		},
+		{
+			name:   "events appended after the head was read",
+			tamper: func(e []AuditEvent) []AuditEvent { return e },
+			head:   chain()[1].Hash,
+			want:   AuditVerification{Valid: true, Checked: 3},
+		},
+		{
+			name:   "removed last event",
+			tamper: func(e []AuditEvent) []AuditEvent { return e[:2] },
+			head:   head,
+			want:   AuditVerification{Checked: 2, BrokenEventID: "2", Reason: "chain ends before its head, the last events were removed"},
+		},
+		{
+			name:   "removed every event",
+			tamper: func([]AuditEvent) []AuditEvent { return nil },
+			head:   head,
+			want:   AuditVerification{Reason: "chain ends before its head, the last events were removed"},
+		},
+		{
+			name: "edited content",
+			tamper: func(e []AuditEvent) []AuditEvent {
+				e[1].Actor = "mallory"
+				return e
+			},
+			want: AuditVerification{Checked: 1, BrokenEventID: "2", Reason: "hash does not match the event content"},
+		},
+		{
+			name: "edited snapshot",
+			tamper: func(e []AuditEvent) []AuditEvent {
+				e[0].After = []byte(`{"Code":"WINTER"}`)
+				return e
+			},
+			want: AuditVerification{BrokenEventID: "1", Reason: "hash does not match the event content"},
+		},
+		{
+			name: "edited and rehashed without the key",
+			tamper: func(e []AuditEvent) []AuditEvent {
+				e[1].Actor = "mallory"
+				e[1].Hash = e[1].ComputeHash([]byte("guessed-key"))
+				return e
+			},
+			want: AuditVerification{Checked: 1, BrokenEventID: "2", Reason: "hash does not match the event content"},
+		},
+		{
+			name: "edited and rehashed with the key",
+			tamper: func(e []AuditEvent) []AuditEvent {
+				e[1].Actor = "mallory"
+				e[1].Hash = e[1].ComputeHash(key)
+				return e
+			},
+			want: AuditVerification{Checked: 2, BrokenEventID: "3", Reason: "previous hash does not match the hash of the previous event"},
+		},
+		{
+			name:   "removed event",
+			tamper: func(e []AuditEvent) []AuditEvent { return append(e[:1], e[2:]...) },
+			want:   AuditVerification{Checked: 1, BrokenEventID: "3", Reason: "previous hash does not match the hash of the previous event"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, VerifyAuditChain(tt.tamper(chain()), tt.head, key))
+		})
+	}
+}
+
+func TestParseAuditKey(t *testing.T) {
+	key, err := ParseAuditKey(base64.StdEncoding.EncodeToString([]byte(strings.Repeat("k", 32))))
+	assert.NoError(t, err)
+	assert.Len(t, key, 32)
+
+	_, err = ParseAuditKey(base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.ErrorContains(t, err, "audit key must be at least 32 bytes long")
+	_, err = ParseAuditKey("not base64!")
+	assert.ErrorContains(t, err, "invalid audit key")
+}
@@ -1,7 +1,56 @@
 package entity
 
 type Basket struct {
-	Value                 int
-	AppliedDiscount       int
+	Value                 Money
+	Lines                 []BasketLine
+	AppliedDiscount       Money
 	ApplicationSuccessful bool
 }
+
+// LineType distinguishes products from other basket lines.
+type LineType string
+
+const (
+	LineProduct  LineType = "product"
+	LineShipping LineType = "shipping"
+)
+
+// Bounds of basket lines, low enough for line totals to never overflow.
+const (
+	MaxLineQuantity  = 1_000_000
+	MaxLineUnitPrice = 1_000_000_000_000
+)
+
+// BasketLine is a single line of a basket together with the effect a coupon had on it.
+type BasketLine struct {
+	SKU       string
+	Type      LineType
+	Quantity  int
+	UnitPrice Money
+
+	AppliedDiscount Money
+	// Effect is the coupon effect applied to the line, empty when the line was not affected.
+	Effect Effect
+}
+
+// LinesTotal reports whether the lines of the basket add up to its value.
+// Baskets without lines always do.
+func (b Basket) LinesTotal() bool {
+	if len(b.Lines) == 0 {
+		return true
+	}
+	remaining := b.Value.Amount
+	for _, l := range b.Lines {
+		total := l.Total().Amount
+		if total > remaining {
+			return false
+		}
+		remaining -= total
+	}
+	return remaining == 0
+}
+
+// Total returns the value of the line before any discount.
+func (l BasketLine) Total() Money {
+	return Money{Amount: l.UnitPrice.Amount * int64(l.Quantity), Currency: l.UnitPrice.Currency}
+}
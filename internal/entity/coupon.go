@@ -1,8 +1,149 @@
 package entity
 
+import (
+	"slices"
+	"time"
+)
+
+// Effect is the kind of benefit a coupon grants to a basket.
+type Effect string
+
+const (
+	// EffectFixedDiscount subtracts the discount of the coupon terms from the basket.
+	EffectFixedDiscount Effect = "fixed_discount"
+	// EffectFreeShipping zeroes the shipping lines of the basket.
+	EffectFreeShipping Effect = "free_shipping"
+	// EffectBuyXGetY discounts Y units of qualifying products for every X units bought.
+	EffectBuyXGetY Effect = "buy_x_get_y"
+	// EffectCheapestItemFree zeroes one unit of the cheapest product of the basket.
+	EffectCheapestItemFree Effect = "cheapest_item_free"
+	// EffectTieredDiscount subtracts the discount of the highest tier the basket value reaches.
+	EffectTieredDiscount Effect = "tiered_discount"
+)
+
+// Valid reports whether the effect is one of the known effects.
+func (e Effect) Valid() bool {
+	switch e {
+	case EffectFixedDiscount, EffectFreeShipping, EffectBuyXGetY, EffectCheapestItemFree, EffectTieredDiscount:
+		return true
+	}
+	return false
+}
+
 type Coupon struct {
-	ID             string
-	Code           string
-	Discount       int
-	MinBasketValue int
+	ID string
+	// TenantID is the brand owning the coupon. Codes are unique within a tenant only.
+	TenantID string
+	Code     string
+	Effect   Effect
+	// Terms holds the discount and minimum basket value for every currency the coupon supports.
+	Terms []Terms
+	// BuyXGetY holds the offer details of EffectBuyXGetY coupons.
+	BuyXGetY *BuyXGetY
+	// Owner is the ID of the only user allowed to apply a personal coupon. Empty for public coupons.
+	Owner       string
+	Description string
+	// Labels are free-form tags used to organize and filter coupons, normalized to lower case.
+	Labels    []string
+	CreatedAt time.Time
+	// CreatedBy and UpdatedBy are the IDs of the users who created and last changed the coupon.
+	CreatedBy string
+	UpdatedAt time.Time
+	UpdatedBy string
+}
+
+// CouponFilter selects coupons by their metadata. Zero fields match every coupon.
+type CouponFilter struct {
+	// Labels the coupon must all have.
+	Labels    []string
+	CreatedBy string
+}
+
+// Matches reports whether the coupon is selected by the filter.
+func (f CouponFilter) Matches(c Coupon) bool {
+	if f.CreatedBy != "" && f.CreatedBy != c.CreatedBy {
+		return false
+	}
+	for _, l := range f.Labels {
+		if !slices.Contains(c.Labels, l) {
+			return false
+		}
+	}
+	return true
+}
+
+// Terms are the conditions of a coupon in a single currency.
+type Terms struct {
+	Discount       Money
+	MinBasketValue Money
+	// Tiers of EffectTieredDiscount coupons, sorted by ascending minimum basket value.
+	Tiers []Tier
+}
+
+// Tier is a discount granted from a minimum basket value on.
+type Tier struct {
+	MinBasketValue Money
+	Discount       Money
+}
+
+// BuyXGetY describes a "buy X, get Y free or at a discount" offer.
+type BuyXGetY struct {
+	// SKUs qualifying for the offer. When empty every product qualifies.
+	SKUs []string
+	Buy  int
+	Get  int
+	// DiscountPercent is the discount applied to the Y units, 100 meaning free.
+	DiscountPercent int64
+}
+
+// Qualifies reports whether the product with the given SKU takes part in the offer.
+func (b BuyXGetY) Qualifies(sku string) bool {
+	if len(b.SKUs) == 0 {
+		return true
+	}
+	for _, s := range b.SKUs {
+		if s == sku {
+			return true
+		}
+	}
+	return false
+}
+
+// Currency returns the currency the terms apply to.
+func (t Terms) Currency() string {
+	if len(t.Tiers) > 0 {
+		return t.Tiers[0].MinBasketValue.Currency
+	}
+	return t.MinBasketValue.Currency
+}
+
+// TierFor returns the highest tier reached by the basket value.
+func (t Terms) TierFor(value Money) (Tier, bool) {
+	tier, ok := Tier{}, false
+	for _, candidate := range t.Tiers {
+		if candidate.MinBasketValue.Currency != value.Currency || candidate.MinBasketValue.Amount > value.Amount {
+			break
+		}
+		tier, ok = candidate, true
+	}
+	return tier, ok
+}
+
+// TermsFor returns the terms of the coupon for the given currency.
+func (c Coupon) TermsFor(currency string) (Terms, bool) {
+	for _, t := range c.Terms {
+		if t.Currency() == currency {
+			return t, true
+		}
+	}
+	return Terms{}, false
+}
+
+// Currencies returns the currencies supported by the coupon.
+func (c Coupon) Currencies() []string {
+	currencies := make([]string, len(c.Terms))
+	for i, t := range c.Terms {
+		currencies[i] = t.Currency()
+	}
+	return currencies
 }
@@ -0,0 +1,109 @@
+package entity
+
+import (
+	"strings"
+
+	"coupon_service/pkg"
+)
+
+// Money is an amount expressed in the minor units (e.g. cents) of an ISO 4217 currency.
+type Money struct {
+	Amount   int64
+	Currency string
+}
+
+// RoundingMode defines how fractional minor units are rounded.
+type RoundingMode string
+
+const (
+	// RoundHalfUp rounds halves away from zero (1.5 -> 2, 2.5 -> 3).
+	RoundHalfUp RoundingMode = "half_up"
+	// RoundHalfEven rounds halves to the nearest even number, also known as banker's rounding (1.5 -> 2, 2.5 -> 2).
+	RoundHalfEven RoundingMode = "half_even"
+)
+
+// ParseRoundingMode returns the RoundingMode matching s.
+func ParseRoundingMode(s string) (RoundingMode, error) {
+	switch mode := RoundingMode(strings.ToLower(s)); mode {
+	case RoundHalfUp, RoundHalfEven:
+		return mode, nil
+	default:
+		return "", pkg.Errorf(pkg.EINVALID, "unknown rounding mode: "+s, nil)
+	}
+}
+
+// NewMoney creates a Money value, normalizing the currency code to upper case.
+func NewMoney(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+// Add returns m + o. Both values must share the same currency.
+func (m Money) Add(o Money) (Money, error) {
+	if err := m.checkCurrency(o); err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: m.Amount + o.Amount, Currency: m.Currency}, nil
+}
+
+// Sub returns m - o. Both values must share the same currency.
+func (m Money) Sub(o Money) (Money, error) {
+	if err := m.checkCurrency(o); err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: m.Amount - o.Amount, Currency: m.Currency}, nil
+}
+
+// Compare returns -1, 0 or 1 when m is respectively less than, equal to or greater than o.
+// Both values must share the same currency.
+func (m Money) Compare(o Money) (int, error) {
+	if err := m.checkCurrency(o); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Amount < o.Amount:
+		return -1, nil
+	case m.Amount > o.Amount:
+		return 1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// MulRatio returns m * num / den, rounding fractional minor units with the given mode.
+// It is the building block for percentage discounts, e.g. MulRatio(15, 100, mode) for 15%.
+func (m Money) MulRatio(num, den int64, mode RoundingMode) Money {
+	if den == 0 {
+		return Money{Currency: m.Currency}
+	}
+	product := m.Amount * num
+	if den < 0 {
+		product, den = -product, -den
+	}
+	quotient, remainder := product/den, product%den
+
+	sign := int64(1)
+	if remainder < 0 {
+		sign, remainder = -1, -remainder
+	}
+	switch twice := 2 * remainder; {
+	case twice > den:
+		quotient += sign
+	case twice == den:
+		if mode != RoundHalfEven || quotient%2 != 0 {
+			quotient += sign
+		}
+	}
+	return Money{Amount: quotient, Currency: m.Currency}
+}
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) checkCurrency(o Money) error {
+	if m.Currency != o.Currency {
+		return pkg.Errorf(pkg.EINVALID, "currency mismatch: "+m.Currency+" and "+o.Currency, nil)
+	}
+	return nil
+}
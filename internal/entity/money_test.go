@@ -0,0 +1,78 @@
+package entity
+
+import (
+	"testing"
+
+	"coupon_service/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMoney_MulRatio(t *testing.T) {
+	tests := []struct {
+		name   string
+		amount int64
+		num    int64
+		den    int64
+		mode   RoundingMode
+		want   int64
+	}{
+		{name: "exact", amount: 1000, num: 15, den: 100, mode: RoundHalfUp, want: 150},
+		{name: "half up rounds half away from zero", amount: 25, num: 1, den: 10, mode: RoundHalfUp, want: 3},
+		{name: "half even rounds half to even", amount: 25, num: 1, den: 10, mode: RoundHalfEven, want: 2},
+		{name: "half even rounds odd half up", amount: 35, num: 1, den: 10, mode: RoundHalfEven, want: 4},
+		{name: "below half rounds down", amount: 24, num: 1, den: 10, mode: RoundHalfUp, want: 2},
+		{name: "above half rounds up", amount: 26, num: 1, den: 10, mode: RoundHalfEven, want: 3},
+		{name: "negative half up", amount: -25, num: 1, den: 10, mode: RoundHalfUp, want: -3},
+		{name: "negative half even", amount: -25, num: 1, den: 10, mode: RoundHalfEven, want: -2},
+		{name: "zero denominator", amount: 25, num: 1, den: 0, mode: RoundHalfUp, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NewMoney(tt.amount, "EUR").MulRatio(tt.num, tt.den, tt.mode)
+			assert.Equal(t, NewMoney(tt.want, "EUR"), got)
+		})
+	}
+}
+
+func TestMoney_Compare(t *testing.T) {
+	tests := []struct {
+		name    string
+		a       Money
+		b       Money
+		want    int
+		wantErr error
+	}{
+		{name: "less", a: NewMoney(1, "EUR"), b: NewMoney(2, "EUR"), want: -1},
+		{name: "equal", a: NewMoney(2, "EUR"), b: NewMoney(2, "eur"), want: 0},
+		{name: "greater", a: NewMoney(3, "EUR"), b: NewMoney(2, "EUR"), want: 1},
+		{
+			name:    "currency mismatch",
+			a:       NewMoney(3, "EUR"),
+			b:       NewMoney(2, "PLN"),
+			wantErr: pkg.Errorf(pkg.EINVALID, "currency mismatch: EUR and PLN", nil),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.a.Compare(tt.b)
+			if tt.wantErr != nil {
+				assert.Error(t, err)
+				assert.Equal(t, tt.wantErr.Error(), err.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseRoundingMode(t *testing.T) {
+	mode, err := ParseRoundingMode("HALF_EVEN")
+	assert.NoError(t, err)
+	assert.Equal(t, RoundHalfEven, mode)
+
+	_, err = ParseRoundingMode("ceiling")
+	assert.Error(t, err)
+}
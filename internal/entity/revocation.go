@@ -0,0 +1,13 @@
+package entity
+
+import "time"
+
+// TokenRevocation revokes bearer tokens before their expiry: either the token with the ID TokenID,
+// or every token of Subject issued before IssuedBefore.
+type TokenRevocation struct {
+	TokenID string
+	// ExpiresAt is the expiry of the revoked token, after which it no longer needs to be remembered.
+	ExpiresAt    time.Time
+	Subject      string
+	IssuedBefore time.Time
+}
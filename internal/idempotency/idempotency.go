@@ -0,0 +1,104 @@
+// Package idempotency stores the responses of requests made with an idempotency key,
+// so that retries of a request are answered with its first response instead of being processed again.
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// sweepEvery is the number of reservations after which expired records are removed.
+const sweepEvery = 1024
+
+// Response is a stored response.
+type Response struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// Record is the state of an idempotency key.
+type Record struct {
+	// Fingerprint identifies the request the key was first used with.
+	Fingerprint string
+	// Response is the response of that request, nil while it is being processed.
+	Response *Response
+}
+
+// Store keeps the records of idempotency keys.
+type Store interface {
+	// Reserve claims key for the request with the given fingerprint for ttl. When the key is already
+	// claimed, its record is returned and reserved is false.
+	Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (record Record, reserved bool, err error)
+	// Complete stores the response of the request that reserved key, keeping it for ttl.
+	Complete(ctx context.Context, key string, response Response, ttl time.Duration) error
+	// Release frees key, so the request can be retried.
+	Release(ctx context.Context, key string) error
+}
+
+type memoryRecord struct {
+	Record
+	expiresAt time.Time
+}
+
+// MemoryStore is a Store keeping records in memory.
+type MemoryStore struct {
+	mu           sync.Mutex
+	records      map[string]*memoryRecord
+	reservations int
+	now          func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: make(map[string]*memoryRecord),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Reserve(_ context.Context, key, fingerprint string, ttl time.Duration) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.reservations++
+	if s.reservations%sweepEvery == 0 {
+		s.sweep(now)
+	}
+
+	if r, ok := s.records[key]; ok && now.Before(r.expiresAt) {
+		return r.Record, false, nil
+	}
+	s.records[key] = &memoryRecord{Record: Record{Fingerprint: fingerprint}, expiresAt: now.Add(ttl)}
+	return Record{}, true, nil
+}
+
+func (s *MemoryStore) Complete(_ context.Context, key string, response Response, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.records[key]
+	if !ok {
+		return nil
+	}
+	r.Response = &response
+	r.expiresAt = s.now().Add(ttl)
+	return nil
+}
+
+func (s *MemoryStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
+
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, r := range s.records {
+		if !now.Before(r.expiresAt) {
+			delete(s.records, key)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_, reserved, err := s.Reserve(ctx, "key", "fingerprint", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+
+	record, reserved, err := s.Reserve(ctx, "key", "fingerprint", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, reserved)
+	assert.Equal(t, Record{Fingerprint: "fingerprint"}, record)
+
+	response := Response{Status: 201, ContentType: "application/json", Body: []byte(`{}`)}
+	assert.NoError(t, s.Complete(ctx, "key", response, time.Hour))
+	record, reserved, err = s.Reserve(ctx, "key", "other", time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, reserved)
+	assert.Equal(t, Record{Fingerprint: "fingerprint", Response: &response}, record)
+
+	// records expire after the ttl given on completion
+	now = now.Add(time.Hour)
+	_, reserved, err = s.Reserve(ctx, "key", "other", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+
+	// released keys can be reserved again
+	assert.NoError(t, s.Release(ctx, "key"))
+	_, reserved, err = s.Reserve(ctx, "key", "fingerprint", time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+
+	s.sweep(now.Add(time.Minute))
+	assert.Empty(t, s.records)
+}
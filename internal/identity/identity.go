@@ -0,0 +1,50 @@
+// Package identity carries the authenticated caller and the ID of a request through the layers of the service.
+package identity
+
+import (
+	"context"
+	"slices"
+)
+
+type (
+	contextKey   struct{}
+	requestIDKey struct{}
+)
+
+// Principal is the authenticated caller of a request.
+type Principal struct {
+	// UserID is the subject of the caller's token.
+	UserID string
+	Roles  []string
+	// TenantID is the brand the caller belongs to, empty for the default tenant of single-tenant deployments.
+	TenantID string
+	// Permissions are the permissions granted to the caller by the access policy of the API, e.g. "coupons:create".
+	Permissions []string
+}
+
+// Granted reports whether the caller has the permission.
+func (p Principal) Granted(perm string) bool {
+	return slices.Contains(p.Permissions, perm)
+}
+
+// NewContext returns a copy of ctx carrying the principal.
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the principal carried by ctx, if any.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(Principal)
+	return p, ok
+}
+
+// WithRequestID returns a copy of ctx carrying the ID of the request.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the ID of the request carried by ctx, or an empty string.
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
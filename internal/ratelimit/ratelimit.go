@@ -0,0 +1,115 @@
+// Package ratelimit limits the rate of requests of clients with token buckets.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepEvery is the number of requests after which buckets back to full are removed.
+const sweepEvery = 1024
+
+// Limit is the quota of a client: Burst requests at once, refilled at PerMinute requests per minute.
+type Limit struct {
+	PerMinute int
+	Burst     int
+}
+
+func (l Limit) perSecond() float64 {
+	return float64(l.PerMinute) / 60
+}
+
+// Result is the outcome of a request and the state of the client's bucket after it.
+type Result struct {
+	Allowed bool
+	// Limit is the capacity of the bucket.
+	Limit int
+	// Remaining is the number of requests the client can still make at once.
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, zero when it already is.
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Limiter keeps a token bucket per client key, in memory.
+type Limiter struct {
+	mu       sync.Mutex
+	limit    Limit
+	buckets  map[string]*bucket
+	requests int
+	now      func() time.Time
+}
+
+func NewLimiter(limit Limit) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from the bucket of key, if there is one left.
+func (l *Limiter) Allow(key string) Result {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.requests++
+	if l.requests%sweepEvery == 0 {
+		l.sweep(now)
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(l.limit.Burst), updated: now}
+		l.buckets[key] = b
+	}
+	l.refill(b, now)
+
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+
+	res := Result{
+		Allowed:   allowed,
+		Limit:     l.limit.Burst,
+		Remaining: int(math.Floor(b.tokens)),
+		Reset:     l.timeFor(float64(l.limit.Burst) - b.tokens),
+	}
+	if !allowed {
+		res.RetryAfter = l.timeFor(1 - b.tokens)
+	}
+	return res
+}
+
+func (l *Limiter) refill(b *bucket, now time.Time) {
+	elapsed := now.Sub(b.updated).Seconds()
+	b.tokens = math.Min(float64(l.limit.Burst), b.tokens+elapsed*l.limit.perSecond())
+	b.updated = now
+}
+
+// timeFor returns the time needed to refill the given number of tokens.
+func (l *Limiter) timeFor(tokens float64) time.Duration {
+	if tokens <= 0 || l.limit.PerMinute <= 0 {
+		return 0
+	}
+	return time.Duration(tokens / l.limit.perSecond() * float64(time.Second))
+}
+
+// sweep removes the buckets that are full again, which behave as new ones.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= float64(l.limit.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewLimiter(Limit{PerMinute: 60, Burst: 3})
+	l.now = func() time.Time { return now }
+
+	for remaining := 2; remaining >= 0; remaining-- {
+		res := l.Allow("alice")
+		assert.True(t, res.Allowed)
+		assert.Equal(t, 3, res.Limit)
+		assert.Equal(t, remaining, res.Remaining)
+		assert.Equal(t, time.Duration(3-remaining)*time.Second, res.Reset)
+		assert.Zero(t, res.RetryAfter)
+	}
+
+	res := l.Allow("alice")
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	assert.Equal(t, 3*time.Second, res.Reset)
+	assert.Equal(t, time.Second, res.RetryAfter)
+
+	// other clients have their own bucket
+	assert.True(t, l.Allow("bob").Allowed)
+
+	// tokens are refilled over time
+	now = now.Add(1500 * time.Millisecond)
+	res = l.Allow("alice")
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 0, res.Remaining)
+	res = l.Allow("alice")
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 500*time.Millisecond, res.RetryAfter)
+
+	// up to the burst
+	now = now.Add(time.Hour)
+	res = l.Allow("alice")
+	assert.True(t, res.Allowed)
+	assert.Equal(t, 2, res.Remaining)
+}
+
+func TestLimiter_sweep(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := NewLimiter(Limit{PerMinute: 60, Burst: 3})
+	l.now = func() time.Time { return now }
+
+	l.Allow("alice")
+	l.Allow("bob")
+	now = now.Add(500 * time.Millisecond)
+	l.Allow("bob")
+	l.sweep(now.Add(time.Second))
+
+	assert.NotContains(t, l.buckets, "alice")
+	assert.Contains(t, l.buckets, "bob")
+}
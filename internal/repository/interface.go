@@ -1,11 +1,45 @@
 package repository
 
 import (
+	"time"
+
 	"coupon_service/internal/entity"
 )
 
 //go:generate go run github.com/matryer/moq -out repository_mock.go -stub . CouponRepository
 type CouponRepository interface {
-	FindByCode(string) (entity.Coupon, error)
+	// FindByCode returns the coupon of the tenant with the code, codes being unique within a tenant.
+	FindByCode(tenantID, code string) (entity.Coupon, error)
+	// Save stores the coupon in its tenant.
 	Save(entity.Coupon) error
+	// SuggestCodes returns up to limit codes of the personal coupons of the owner in the tenant close to the given code.
+	SuggestCodes(tenantID, code, owner string, limit int) ([]string, error)
+}
+
+//go:generate go run github.com/matryer/moq -out ledger_mock.go -stub . RedemptionLedger
+type RedemptionLedger interface {
+	// Redeem records the redemption of a signed code, returning a pkg.ECONFLICT error
+	// when the serial of the campaign of the tenant was already redeemed.
+	Redeem(tenantID string, campaignID, serial uint32) error
+}
+
+//go:generate go run github.com/matryer/moq -out audit_mock.go -stub . AuditRepository
+type AuditRepository interface {
+	// Append adds an event at the end of the audit log, chained to the last event of its tenant.
+	Append(entity.AuditEvent) error
+	// Query returns the events matching the filter, oldest first.
+	Query(entity.AuditFilter) ([]entity.AuditEvent, error)
+	// Head returns the hash of the last event of the tenant, empty when it has none.
+	Head(tenantID string) (string, error)
+}
+
+//go:generate go run github.com/matryer/moq -out apikey_mock.go -stub . APIKeyRepository
+type APIKeyRepository interface {
+	FindByID(id string) (entity.APIKey, error)
+	// List returns the keys of the tenant.
+	List(tenantID string) ([]entity.APIKey, error)
+	Save(entity.APIKey) error
+	Delete(id string) error
+	// Touch records that the key was used at the given time.
+	Touch(id string, at time.Time) error
 }
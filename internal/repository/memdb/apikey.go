@@ -0,0 +1,84 @@
+package memdb
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"coupon_service/internal/entity"
+	"coupon_service/pkg"
+)
+
+// APIKeys is an in-memory store of API keys.
+type APIKeys struct {
+	mu   sync.RWMutex
+	keys map[string]entity.APIKey
+}
+
+func NewAPIKeys() *APIKeys {
+	return &APIKeys{keys: make(map[string]entity.APIKey)}
+}
+
+func (s *APIKeys) FindByID(id string) (entity.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return entity.APIKey{}, pkg.Errorf(pkg.ENOTFOUND, "API key not found", nil)
+	}
+	return key, nil
+}
+
+// List returns the keys of the tenant sorted by creation time.
+func (s *APIKeys) List(tenantID string) ([]entity.APIKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]entity.APIKey, 0, len(s.keys))
+	for _, k := range s.keys {
+		if k.TenantID == tenantID {
+			keys = append(keys, k)
+		}
+	}
+	slices.SortFunc(keys, func(a, b entity.APIKey) int {
+		if c := a.CreatedAt.Compare(b.CreatedAt); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID, b.ID)
+	})
+	return keys, nil
+}
+
+func (s *APIKeys) Save(key entity.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[key.ID] = key
+	return nil
+}
+
+func (s *APIKeys) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[id]; !ok {
+		return pkg.Errorf(pkg.ENOTFOUND, "API key not found", nil)
+	}
+	delete(s.keys, id)
+	return nil
+}
+
+func (s *APIKeys) Touch(id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return pkg.Errorf(pkg.ENOTFOUND, "API key not found", nil)
+	}
+	key.LastUsedAt = at
+	s.keys[id] = key
+	return nil
+}
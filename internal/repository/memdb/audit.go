@@ -0,0 +1,52 @@
+package memdb
+
+import (
+	"sync"
+
+	"coupon_service/internal/entity"
+)
+
+// AuditLog is an in-memory, append-only audit log.
+type AuditLog struct {
+	mu     sync.RWMutex
+	events []entity.AuditEvent
+	// heads holds the hash of the last event of each tenant.
+	heads map[string]string
+	// key keys the hashes chaining the events.
+	key []byte
+}
+
+// NewAuditLog returns an empty audit log chaining its events with hashes keyed with key.
+func NewAuditLog(key []byte) *AuditLog {
+	return &AuditLog{heads: make(map[string]string), key: key}
+}
+
+func (l *AuditLog) Append(event entity.AuditEvent) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	event = event.Chain(l.heads[event.TenantID], l.key)
+	l.events = append(l.events, event)
+	l.heads[event.TenantID] = event.Hash
+	return nil
+}
+
+func (l *AuditLog) Query(filter entity.AuditFilter) ([]entity.AuditEvent, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	var events []entity.AuditEvent
+	for _, e := range l.events {
+		if filter.Matches(e) {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (l *AuditLog) Head(tenantID string) (string, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.heads[tenantID], nil
+}
@@ -1,15 +1,28 @@
 package memdb
 
 import (
+	"sync"
+
 	"coupon_service/internal/entity"
 )
 
 type Repository struct {
-	entries map[string]entity.Coupon
+	mu sync.RWMutex
+	// entries are the coupons of every tenant by code.
+	entries map[string]map[string]entity.Coupon
+	// byOwner is the suggestion index: the codes of the personal coupons of every owner.
+	byOwner map[owner]map[string]struct{}
+}
+
+// owner identifies a user within a tenant.
+type owner struct {
+	tenantID string
+	userID   string
 }
 
 func NewRepository() *Repository {
 	return &Repository{
-		entries: make(map[string]entity.Coupon),
+		entries: make(map[string]map[string]entity.Coupon),
+		byOwner: make(map[owner]map[string]struct{}),
 	}
 }
@@ -0,0 +1,36 @@
+package memdb
+
+import (
+	"sync"
+
+	"coupon_service/pkg"
+)
+
+// Ledger is an in-memory redemption ledger of signed codes.
+type Ledger struct {
+	mu       sync.Mutex
+	redeemed map[redemption]struct{}
+}
+
+// redemption identifies a signed code, campaigns being numbered per tenant.
+type redemption struct {
+	tenantID   string
+	campaignID uint32
+	serial     uint32
+}
+
+func NewLedger() *Ledger {
+	return &Ledger{redeemed: make(map[redemption]struct{})}
+}
+
+func (l *Ledger) Redeem(tenantID string, campaignID, serial uint32) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := redemption{tenantID: tenantID, campaignID: campaignID, serial: serial}
+	if _, ok := l.redeemed[key]; ok {
+		return pkg.Errorf(pkg.ECONFLICT, "coupon already redeemed", nil)
+	}
+	l.redeemed[key] = struct{}{}
+	return nil
+}
@@ -1,12 +1,20 @@
 package memdb
 
 import (
+	"sort"
+	"strings"
+
 	"coupon_service/internal/entity"
 	"coupon_service/pkg"
 )
 
-func (r *Repository) FindByCode(code string) (entity.Coupon, error) {
-	coupon, ok := r.entries[code]
+// maxSuggestionDistance is the maximum number of edits between a code and the codes suggested for it.
+const maxSuggestionDistance = 2
+
+func (r *Repository) FindByCode(tenantID, code string) (entity.Coupon, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	coupon, ok := r.entries[tenantID][pkg.NormalizeCode(code)]
 	if !ok {
 		return entity.Coupon{}, pkg.Errorf(pkg.ENOTFOUND, "coupon not found", nil)
 	}
@@ -14,6 +22,121 @@ func (r *Repository) FindByCode(code string) (entity.Coupon, error) {
 }
 
 func (r *Repository) Save(coupon entity.Coupon) error {
-	r.entries[coupon.Code] = coupon
+	coupon.Code = pkg.NormalizeCode(coupon.Code)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := r.entries[coupon.TenantID]
+	if entries == nil {
+		entries = make(map[string]entity.Coupon)
+		r.entries[coupon.TenantID] = entries
+	}
+	if previous, ok := entries[coupon.Code]; ok {
+		r.unindex(previous)
+	}
+	entries[coupon.Code] = coupon
+	r.index(coupon)
 	return nil
 }
+
+// SuggestCodes returns up to limit codes of the personal coupons of the owner in the tenant closest to code,
+// ordered by ascending distance. Codes more than maxSuggestionDistance edits away are left out.
+func (r *Repository) SuggestCodes(tenantID, code, userID string, limit int) ([]string, error) {
+	if userID == "" || limit <= 0 {
+		return nil, nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	type candidate struct {
+		code     string
+		distance int
+	}
+	var candidates []candidate
+	for c := range r.byOwner[owner{tenantID: tenantID, userID: userID}] {
+		if d := pkg.CodeDistance(code, c); d <= maxSuggestionDistance {
+			candidates = append(candidates, candidate{code: c, distance: d})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].distance != candidates[j].distance {
+			return candidates[i].distance < candidates[j].distance
+		}
+		return candidates[i].code < candidates[j].code
+	})
+
+	var codes []string
+	for _, c := range candidates[:min(limit, len(candidates))] {
+		codes = append(codes, c.code)
+	}
+	return codes, nil
+}
+
+// index adds a personal coupon to the suggestion index.
+func (r *Repository) index(coupon entity.Coupon) {
+	if coupon.Owner == "" {
+		return
+	}
+	if r.byOwner == nil {
+		r.byOwner = make(map[owner]map[string]struct{})
+	}
+	key := owner{tenantID: coupon.TenantID, userID: coupon.Owner}
+	if r.byOwner[key] == nil {
+		r.byOwner[key] = make(map[string]struct{})
+	}
+	r.byOwner[key][coupon.Code] = struct{}{}
+}
+
+// unindex removes a personal coupon from the suggestion index.
+func (r *Repository) unindex(coupon entity.Coupon) {
+	key := owner{tenantID: coupon.TenantID, userID: coupon.Owner}
+	codes := r.byOwner[key]
+	delete(codes, coupon.Code)
+	if len(codes) == 0 {
+		delete(r.byOwner, key)
+	}
+}
+
+// MigrateCodes rewrites the stored coupons to their normalized code and returns how many were changed.
+// When two stored codes of a tenant normalize to the same code nothing is migrated and a conflict error
+// listing them is returned.
+func (r *Repository) MigrateCodes() (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	migrated := make(map[string]map[string]entity.Coupon, len(r.entries))
+	var collisions []string
+	changed := 0
+	for tenantID, entries := range r.entries {
+		tenantMigrated := make(map[string]entity.Coupon, len(entries))
+		originals := make(map[string]string, len(entries))
+		for key, coupon := range entries {
+			code := pkg.NormalizeCode(coupon.Code)
+			if original, ok := originals[code]; ok {
+				pair := []string{original, coupon.Code}
+				sort.Strings(pair)
+				collisions = append(collisions, strings.Join(pair, " / "))
+				continue
+			}
+			if code != key || code != coupon.Code {
+				changed++
+			}
+			originals[code] = coupon.Code
+			coupon.Code = code
+			tenantMigrated[code] = coupon
+		}
+		migrated[tenantID] = tenantMigrated
+	}
+
+	if len(collisions) > 0 {
+		sort.Strings(collisions)
+		return 0, pkg.Errorf(pkg.ECONFLICT, "coupon codes collide once normalized: "+strings.Join(collisions, ", "), nil)
+	}
+
+	r.entries = migrated
+	r.byOwner = make(map[owner]map[string]struct{})
+	for _, entries := range migrated {
+		for _, coupon := range entries {
+			r.index(coupon)
+		}
+	}
+	return changed, nil
+}
@@ -1,7 +1,10 @@
 package memdb
 
 import (
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"coupon_service/internal/entity"
 	"coupon_service/pkg"
@@ -10,19 +13,29 @@ import (
 
 func TestRepository_FindByCode(t *testing.T) {
 	tests := []struct {
-		name    string
-		entries map[string]entity.Coupon
-		code    string
-		want    entity.Coupon
-		wantErr error
+		name     string
+		entries  map[string]entity.Coupon
+		tenantID string
+		code     string
+		want     entity.Coupon
+		wantErr  error
 	}{
 		{
 			name: "coupon found",
 			entries: map[string]entity.Coupon{
-				"ABC123": {Code: "ABC123", Discount: 10, MinBasketValue: 100},
+				"ABC123": {Code: "ABC123", Terms: []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}}},
 			},
 			code:    "ABC123",
-			want:    entity.Coupon{Code: "ABC123", Discount: 10, MinBasketValue: 100},
+			want:    entity.Coupon{Code: "ABC123", Terms: []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}}},
+			wantErr: nil,
+		},
+		{
+			name: "coupon found with non normalized code",
+			entries: map[string]entity.Coupon{
+				"ABC123": {Code: "ABC123", Terms: []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}}},
+			},
+			code:    " abc-123",
+			want:    entity.Coupon{Code: "ABC123", Terms: []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}}},
 			wantErr: nil,
 		},
 		{
@@ -32,12 +45,22 @@ func TestRepository_FindByCode(t *testing.T) {
 			want:    entity.Coupon{},
 			wantErr: pkg.Errorf(pkg.ENOTFOUND, "coupon not found", nil),
 		},
+		{
+			name: "coupon of another tenant not found",
+			entries: map[string]entity.Coupon{
+				"ABC123": {Code: "ABC123"},
+			},
+			tenantID: "acme",
+			code:     "ABC123",
+			want:     entity.Coupon{},
+			wantErr:  pkg.Errorf(pkg.ENOTFOUND, "coupon not found", nil),
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			r := &Repository{entries: tt.entries}
-			got, err := r.FindByCode(tt.code)
+			r := &Repository{entries: map[string]map[string]entity.Coupon{"": tt.entries}}
+			got, err := r.FindByCode(tt.tenantID, tt.code)
 			if tt.wantErr != nil {
 				assert.Error(t, err)
 				assert.Equal(t, tt.wantErr.Error(), err.Error())
@@ -54,27 +77,256 @@ func TestRepository_Save(t *testing.T) {
 		name    string
 		initial map[string]entity.Coupon
 		coupon  entity.Coupon
-		want    map[string]entity.Coupon
+		want    map[string]map[string]entity.Coupon
 	}{
 		{
 			name: "save new coupon",
 			initial: map[string]entity.Coupon{
-				"OLDCOUPON10": {Code: "OLDCOUPON10", Discount: 10, MinBasketValue: 100},
+				"OLDCOUPON10": {Code: "OLDCOUPON10", Terms: []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}}},
+			},
+			coupon: entity.Coupon{Code: "NEW123", Terms: []entity.Terms{{Discount: entity.NewMoney(15, "EUR"), MinBasketValue: entity.NewMoney(200, "EUR")}}},
+			want: map[string]map[string]entity.Coupon{"": {
+				"OLDCOUPON10": {Code: "OLDCOUPON10", Terms: []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}}},
+				"NEW123":      {Code: "NEW123", Terms: []entity.Terms{{Discount: entity.NewMoney(15, "EUR"), MinBasketValue: entity.NewMoney(200, "EUR")}}},
+			}},
+		},
+		{
+			name:    "save coupon with non normalized code",
+			initial: map[string]entity.Coupon{},
+			coupon:  entity.Coupon{Code: "new-123"},
+			want: map[string]map[string]entity.Coupon{"": {
+				"NEW123": {Code: "NEW123"},
+			}},
+		},
+		{
+			name: "save coupon of another tenant",
+			initial: map[string]entity.Coupon{
+				"NEW123": {Code: "NEW123"},
 			},
-			coupon: entity.Coupon{Code: "NEW123", Discount: 15, MinBasketValue: 200},
-			want: map[string]entity.Coupon{
-				"OLDCOUPON10": {Code: "OLDCOUPON10", Discount: 10, MinBasketValue: 100},
-				"NEW123":      {Code: "NEW123", Discount: 15, MinBasketValue: 200},
+			coupon: entity.Coupon{TenantID: "acme", Code: "NEW123"},
+			want: map[string]map[string]entity.Coupon{
+				"":     {"NEW123": {Code: "NEW123"}},
+				"acme": {"NEW123": {TenantID: "acme", Code: "NEW123"}},
 			},
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			r := &Repository{entries: tt.initial}
+			r := &Repository{entries: map[string]map[string]entity.Coupon{"": tt.initial}}
 			err := r.Save(tt.coupon)
 			assert.NoError(t, err)
 			assert.Equal(t, tt.want, r.entries)
 		})
 	}
 }
+
+func TestRepository_Concurrent(t *testing.T) {
+	r := NewRepository()
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			code := fmt.Sprintf("CODE%d", i)
+			assert.NoError(t, r.Save(entity.Coupon{Code: code, Owner: "alice"}))
+			_, err := r.FindByCode("", code)
+			assert.NoError(t, err)
+			_, err = r.SuggestCodes("", code, "alice", 3)
+			assert.NoError(t, err)
+			_, err = r.MigrateCodes()
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+	assert.Len(t, r.entries[""], 8)
+}
+
+func TestRepository_MigrateCodes(t *testing.T) {
+	tests := []struct {
+		name         string
+		initial      map[string]map[string]entity.Coupon
+		want         map[string]map[string]entity.Coupon
+		wantMigrated int
+		wantErr      error
+	}{
+		{
+			name: "codes normalized",
+			initial: map[string]map[string]entity.Coupon{"": {
+				"SAVE10ABC":  {ID: "1", Code: "SAVE10ABC"},
+				"save-20abc": {ID: "2", Code: "save-20abc"},
+			}},
+			want: map[string]map[string]entity.Coupon{"": {
+				"SAVE10ABC": {ID: "1", Code: "SAVE10ABC"},
+				"SAVE20ABC": {ID: "2", Code: "SAVE20ABC"},
+			}},
+			wantMigrated: 1,
+		},
+		{
+			name: "same codes of different tenants",
+			initial: map[string]map[string]entity.Coupon{
+				"":     {"SAVE10ABC": {ID: "1", Code: "SAVE10ABC"}},
+				"acme": {"save10abc": {ID: "2", TenantID: "acme", Code: "save10abc"}},
+			},
+			want: map[string]map[string]entity.Coupon{
+				"":     {"SAVE10ABC": {ID: "1", Code: "SAVE10ABC"}},
+				"acme": {"SAVE10ABC": {ID: "2", TenantID: "acme", Code: "SAVE10ABC"}},
+			},
+			wantMigrated: 1,
+		},
+		{
+			name: "codes colliding once normalized",
+			initial: map[string]map[string]entity.Coupon{"": {
+				"SAVE10ABC": {ID: "1", Code: "SAVE10ABC"},
+				"save10abc": {ID: "2", Code: "save10abc"},
+			}},
+			want: map[string]map[string]entity.Coupon{"": {
+				"SAVE10ABC": {ID: "1", Code: "SAVE10ABC"},
+				"save10abc": {ID: "2", Code: "save10abc"},
+			}},
+			wantErr: pkg.Errorf(pkg.ECONFLICT, "coupon codes collide once normalized: SAVE10ABC / save10abc", nil),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &Repository{entries: tt.initial}
+			migrated, err := r.MigrateCodes()
+			if tt.wantErr != nil {
+				assert.Error(t, err)
+				assert.Equal(t, tt.wantErr.Error(), err.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.wantMigrated, migrated)
+			}
+			assert.Equal(t, tt.want, r.entries)
+		})
+	}
+}
+
+func TestRepository_SuggestCodes(t *testing.T) {
+	r := NewRepository()
+	for _, c := range []entity.Coupon{
+		{Code: "ALICE10", Owner: "alice"},
+		{Code: "ALICE20", Owner: "alice"},
+		{Code: "SPRING2025", Owner: "alice"},
+		{Code: "ALICE11", Owner: "bob"},
+		{Code: "ALICE12"},
+		{TenantID: "acme", Code: "ALICE13", Owner: "alice"},
+	} {
+		assert.NoError(t, r.Save(c))
+	}
+
+	tests := []struct {
+		name  string
+		code  string
+		owner string
+		limit int
+		want  []string
+	}{
+		{name: "O and 0 confusion ranks first", code: "ALICE1O", owner: "alice", limit: 3, want: []string{"ALICE10", "ALICE20"}},
+		{name: "transposed characters", code: "ALCIE10", owner: "alice", limit: 3, want: []string{"ALICE10", "ALICE20"}},
+		{name: "limited", code: "ALICE10", owner: "alice", limit: 1, want: []string{"ALICE10"}},
+		{name: "too far away", code: "WINTER", owner: "alice", limit: 3, want: nil},
+		{name: "only codes of the owner", code: "ALICE10", owner: "bob", limit: 3, want: []string{"ALICE11"}},
+		{name: "public coupons are never suggested", code: "ALICE12", owner: "carol", limit: 3, want: nil},
+		{name: "no owner", code: "ALICE10", owner: "", limit: 3, want: nil},
+		{name: "only codes of the tenant", code: "ALICE13", owner: "alice", limit: 1, want: []string{"ALICE10"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := r.SuggestCodes("", tt.code, tt.owner, tt.limit)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	// the index follows the owner of a coupon saved again
+	assert.NoError(t, r.Save(entity.Coupon{Code: "ALICE11", Owner: "alice"}))
+	got, err := r.SuggestCodes("", "ALICE11", "bob", 3)
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+func TestLedger_Redeem(t *testing.T) {
+	l := NewLedger()
+	assert.NoError(t, l.Redeem("", 1, 1))
+	assert.NoError(t, l.Redeem("", 1, 2))
+	assert.NoError(t, l.Redeem("", 2, 1))
+	// campaigns are numbered per tenant
+	assert.NoError(t, l.Redeem("acme", 1, 1))
+
+	err := l.Redeem("", 1, 1)
+	assert.Equal(t, pkg.Errorf(pkg.ECONFLICT, "coupon already redeemed", nil).Error(), err.Error())
+}
+
+// auditKey keys the audit chains of the tests.
+var auditKey = []byte("audit-key-of-at-least-32-bytes!!")
+
+func TestAuditLog_Query(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	events := []entity.AuditEvent{
+		{ID: "1", Time: start, Actor: "alice", Action: entity.AuditCouponCreated, CouponCode: "SUMMER"},
+		{ID: "2", Time: start.Add(time.Hour), Actor: "bob", Action: entity.AuditCouponCreated, CouponCode: "WINTER"},
+		{ID: "3", Time: start.Add(2 * time.Hour), Actor: "alice", Action: entity.AuditSignedCodesIssued},
+		{ID: "4", TenantID: "acme", Time: start.Add(3 * time.Hour), Actor: "alice", Action: entity.AuditCouponCreated, CouponCode: "SUMMER"},
+	}
+	l := NewAuditLog(auditKey)
+	for _, e := range events {
+		assert.NoError(t, l.Append(e))
+	}
+
+	tests := []struct {
+		name   string
+		filter entity.AuditFilter
+		want   []string
+	}{
+		{name: "no filter returns every event of the default tenant in order", want: []string{"1", "2", "3"}},
+		{name: "by tenant", filter: entity.AuditFilter{TenantID: "acme"}, want: []string{"4"}},
+		{name: "any tenant", filter: entity.AuditFilter{AnyTenant: true, CouponCode: "SUMMER"}, want: []string{"1", "4"}},
+		{name: "by coupon code", filter: entity.AuditFilter{CouponCode: "WINTER"}, want: []string{"2"}},
+		{name: "by actor", filter: entity.AuditFilter{Actor: "alice"}, want: []string{"1", "3"}},
+		{name: "time range is inclusive", filter: entity.AuditFilter{From: start.Add(time.Hour), To: start.Add(2 * time.Hour)}, want: []string{"2", "3"}},
+		{name: "no match", filter: entity.AuditFilter{Actor: "carol"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := l.Query(tt.filter)
+			assert.NoError(t, err)
+			var ids []string
+			for _, e := range got {
+				ids = append(ids, e.ID)
+			}
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+}
+
+func TestAuditLog_Append(t *testing.T) {
+	l := NewAuditLog(auditKey)
+	assert.NoError(t, l.Append(entity.AuditEvent{ID: "1", Actor: "alice"}))
+	assert.NoError(t, l.Append(entity.AuditEvent{ID: "2", TenantID: "acme", Actor: "carol"}))
+	assert.NoError(t, l.Append(entity.AuditEvent{ID: "3", Actor: "bob"}))
+
+	events, err := l.Query(entity.AuditFilter{})
+	assert.NoError(t, err)
+	assert.Empty(t, events[0].PrevHash)
+	assert.Equal(t, events[0].Hash, events[1].PrevHash)
+	head, err := l.Head("")
+	assert.NoError(t, err)
+	assert.Equal(t, events[1].Hash, head)
+	assert.Equal(t, entity.AuditVerification{Valid: true, Checked: 2}, entity.VerifyAuditChain(events, head, auditKey))
+
+	// each tenant has its own chain
+	events, err = l.Query(entity.AuditFilter{TenantID: "acme"})
+	assert.NoError(t, err)
+	assert.Empty(t, events[0].PrevHash)
+	head, err = l.Head("acme")
+	assert.NoError(t, err)
+	assert.Equal(t, entity.AuditVerification{Valid: true, Checked: 1}, entity.VerifyAuditChain(events, head, auditKey))
+
+	head, err = l.Head("globex")
+	assert.NoError(t, err)
+	assert.Empty(t, head)
+}
@@ -19,12 +19,15 @@ var _ CouponRepository = &CouponRepositoryMock{}
 //
 //		// make and configure a mocked CouponRepository
 //		mockedCouponRepository := &CouponRepositoryMock{
-//			FindByCodeFunc: func(s string) (entity.Coupon, error) {
+//			FindByCodeFunc: func(tenantID string, code string) (entity.Coupon, error) {
 //				panic("mock out the FindByCode method")
 //			},
 //			SaveFunc: func(coupon entity.Coupon) error {
 //				panic("mock out the Save method")
 //			},
+//			SuggestCodesFunc: func(tenantID string, code string, owner string, limit int) ([]string, error) {
+//				panic("mock out the SuggestCodes method")
+//			},
 //		}
 //
 //		// use mockedCouponRepository in code that requires CouponRepository
@@ -33,34 +36,53 @@ var _ CouponRepository = &CouponRepositoryMock{}
 //	}
 type CouponRepositoryMock struct {
 	// FindByCodeFunc mocks the FindByCode method.
-	FindByCodeFunc func(s string) (entity.Coupon, error)
+	FindByCodeFunc func(tenantID string, code string) (entity.Coupon, error)
 
 	// SaveFunc mocks the Save method.
 	SaveFunc func(coupon entity.Coupon) error
 
+	// SuggestCodesFunc mocks the SuggestCodes method.
+	SuggestCodesFunc func(tenantID string, code string, owner string, limit int) ([]string, error)
+
 	// calls tracks calls to the methods.
 	calls struct {
 		// FindByCode holds details about calls to the FindByCode method.
 		FindByCode []struct {
-			// S is the s argument value.
-			S string
+			// TenantID is the tenantID argument value.
+			TenantID string
+			// Code is the code argument value.
+			Code string
 		}
 		// Save holds details about calls to the Save method.
 		Save []struct {
 			// Coupon is the coupon argument value.
 			Coupon entity.Coupon
 		}
+		// SuggestCodes holds details about calls to the SuggestCodes method.
+		SuggestCodes []struct {
+			// TenantID is the tenantID argument value.
+			TenantID string
+			// Code is the code argument value.
+			Code string
+			// Owner is the owner argument value.
+			Owner string
+			// Limit is the limit argument value.
+			Limit int
+		}
 	}
-	lockFindByCode sync.RWMutex
-	lockSave       sync.RWMutex
+	lockFindByCode   sync.RWMutex
+	lockSave         sync.RWMutex
+	lockSuggestCodes sync.RWMutex
 }
 
 // FindByCode calls FindByCodeFunc.
-func (mock *CouponRepositoryMock) FindByCode(s string) (entity.Coupon, error) {
+func (mock *CouponRepositoryMock) FindByCode(tenantID string, code string) (entity.Coupon, error) {
 	callInfo := struct {
-		S string
+		TenantID string
+		Code     string
 	}{
-		S: s,
+		TenantID: tenantID,
+		Code:     code,
 	}
 	mock.lockFindByCode.Lock()
 	mock.calls.FindByCode = append(mock.calls.FindByCode, callInfo)
@@ -72,7 +94,7 @@ func (mock *CouponRepositoryMock) FindByCode(s string) (entity.Coupon, error) {
 		)
 		return couponOut, errOut
 	}
-	return mock.FindByCodeFunc(s)
+	return mock.FindByCodeFunc(tenantID, code)
 }
 
 // FindByCodeCalls gets all the calls that were made to FindByCode.
@@ -80,10 +102,12 @@ func (mock *CouponRepositoryMock) FindByCode(s string) (entity.Coupon, error) {
 //
 //	len(mockedCouponRepository.FindByCodeCalls())
 func (mock *CouponRepositoryMock) FindByCodeCalls() []struct {
-	S string
+	TenantID string
+	Code     string
 } {
 	var calls []struct {
-		S string
+		TenantID string
+		Code     string
 	}
 	mock.lockFindByCode.RLock()
 	calls = mock.calls.FindByCode
@@ -125,3 +149,51 @@ func (mock *CouponRepositoryMock) SaveCalls() []struct {
 	mock.lockSave.RUnlock()
 	return calls
 }
+
+// SuggestCodes calls SuggestCodesFunc.
+func (mock *CouponRepositoryMock) SuggestCodes(tenantID string, code string, owner string, limit int) ([]string, error) {
+	callInfo := struct {
+		TenantID string
+		Code     string
+		Owner    string
+		Limit    int
+	}{
+		TenantID: tenantID,
+		Code:     code,
+		Owner:    owner,
+		Limit:    limit,
+	}
+	mock.lockSuggestCodes.Lock()
+	mock.calls.SuggestCodes = append(mock.calls.SuggestCodes, callInfo)
+	mock.lockSuggestCodes.Unlock()
+	if mock.SuggestCodesFunc == nil {
+		var (
+			stringsOut []string
+			errOut     error
+		)
+		return stringsOut, errOut
+	}
+	return mock.SuggestCodesFunc(tenantID, code, owner, limit)
+}
+
+// SuggestCodesCalls gets all the calls that were made to SuggestCodes.
+// Check the length with:
+//
+//	len(mockedCouponRepository.SuggestCodesCalls())
+func (mock *CouponRepositoryMock) SuggestCodesCalls() []struct {
+	TenantID string
+	Code     string
+	Owner    string
+	Limit    int
+} {
+	var calls []struct {
+		TenantID string
+		Code     string
+		Owner    string
+		Limit    int
+	}
+	mock.lockSuggestCodes.RLock()
+	calls = mock.calls.SuggestCodes
+	mock.lockSuggestCodes.RUnlock()
+	return calls
+}
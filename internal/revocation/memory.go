@@ -0,0 +1,73 @@
+package revocation
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store keeping revocations in memory, so they are neither shared between
+// instances of the service nor kept across restarts.
+type MemoryStore struct {
+	mu       sync.Mutex
+	tokens   map[string]time.Time
+	subjects map[string]time.Time
+	revoked  int
+	now      func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		tokens:   make(map[string]time.Time),
+		subjects: make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+func (s *MemoryStore) RevokeToken(_ context.Context, tenantID, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revoked++
+	if s.revoked%sweepEvery == 0 {
+		s.sweep(s.now())
+	}
+	s.tokens[tenantKey(tenantID, jti)] = expiresAt
+	return nil
+}
+
+func (s *MemoryStore) RevokeSubject(_ context.Context, tenantID, subject string, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// revocations only ever widen, so that a late request cannot restore revoked tokens
+	key := tenantKey(tenantID, subject)
+	if before.After(s.subjects[key]) {
+		s.subjects[key] = before
+	}
+	return nil
+}
+
+func (s *MemoryStore) TokenRevoked(_ context.Context, tenantID, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiresAt, ok := s.tokens[tenantKey(tenantID, jti)]
+	return ok && s.now().Before(expiresAt), nil
+}
+
+func (s *MemoryStore) SubjectRevokedBefore(_ context.Context, tenantID, subject string) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.subjects[tenantKey(tenantID, subject)], nil
+}
+
+// sweep removes the revoked tokens which have expired.
+func (s *MemoryStore) sweep(now time.Time) {
+	for key, expiresAt := range s.tokens {
+		if !now.Before(expiresAt) {
+			delete(s.tokens, key)
+		}
+	}
+}
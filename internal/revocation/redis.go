@@ -0,0 +1,71 @@
+package revocation
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"coupon_service/pkg"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// raiseSubject sets the revocation time of a subject, unless it is already later.
+var raiseSubject = redis.NewScript(`
+local current = tonumber(redis.call("GET", KEYS[1]) or "0")
+if tonumber(ARGV[1]) > current then
+	redis.call("SET", KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+// RedisStore is a Store keeping revocations in Redis, so they are shared between instances of the service.
+// Revoked tokens expire with the tokens, while subject revocations are kept.
+type RedisStore struct {
+	client redis.UniversalClient
+	prefix string
+	now    func() time.Time
+}
+
+// NewRedisStore creates a RedisStore prefixing its keys with prefix.
+func NewRedisStore(client redis.UniversalClient, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix, now: time.Now}
+}
+
+func (s *RedisStore) RevokeToken(ctx context.Context, tenantID, jti string, expiresAt time.Time) error {
+	ttl := expiresAt.Sub(s.now())
+	if ttl <= 0 {
+		// the token has already expired and is rejected anyway
+		return nil
+	}
+	if err := s.client.Set(ctx, s.prefix+"jti:"+tenantKey(tenantID, jti), 1, ttl).Err(); err != nil {
+		return pkg.Errorf(pkg.EINTERNAL, "failed to revoke the token", err)
+	}
+	return nil
+}
+
+func (s *RedisStore) RevokeSubject(ctx context.Context, tenantID, subject string, before time.Time) error {
+	if err := raiseSubject.Run(ctx, s.client, []string{s.prefix + "sub:" + tenantKey(tenantID, subject)}, before.UnixMilli()).Err(); err != nil {
+		return pkg.Errorf(pkg.EINTERNAL, "failed to revoke the tokens of the subject", err)
+	}
+	return nil
+}
+
+func (s *RedisStore) TokenRevoked(ctx context.Context, tenantID, jti string) (bool, error) {
+	n, err := s.client.Exists(ctx, s.prefix+"jti:"+tenantKey(tenantID, jti)).Result()
+	if err != nil {
+		return false, pkg.Errorf(pkg.EINTERNAL, "failed to check the token revocation", err)
+	}
+	return n > 0, nil
+}
+
+func (s *RedisStore) SubjectRevokedBefore(ctx context.Context, tenantID, subject string) (time.Time, error) {
+	ms, err := s.client.Get(ctx, s.prefix+"sub:"+tenantKey(tenantID, subject)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, pkg.Errorf(pkg.EINTERNAL, "failed to check the token revocation", err)
+	}
+	return time.UnixMilli(ms), nil
+}
@@ -0,0 +1,151 @@
+// Package revocation keeps the bearer tokens revoked before their expiry, so that leaked tokens can be rejected.
+//
+// Tokens are revoked one by one by their ID (the jti claim), or all together by subject: every token of the subject
+// issued before a given time is revoked, e.g. when the credentials of a user are compromised. Revocations belong to
+// a tenant, so that the tokens of other tenants with the same IDs or subjects are left alone.
+package revocation
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// sweepEvery is the number of cached lookups after which expired cache entries are removed.
+const sweepEvery = 1024
+
+// Store keeps the revoked token IDs and subjects of every tenant.
+type Store interface {
+	// RevokeToken revokes the token of the tenant with the ID jti, remembered until the token expires at expiresAt.
+	RevokeToken(ctx context.Context, tenantID, jti string, expiresAt time.Time) error
+	// RevokeSubject revokes the tokens of subject in the tenant issued before the given time.
+	RevokeSubject(ctx context.Context, tenantID, subject string, before time.Time) error
+	// TokenRevoked reports whether the token of the tenant with the ID jti is revoked.
+	TokenRevoked(ctx context.Context, tenantID, jti string) (bool, error)
+	// SubjectRevokedBefore returns the time before which the tokens of subject in the tenant are revoked, zero when none are.
+	SubjectRevokedBefore(ctx context.Context, tenantID, subject string) (time.Time, error)
+}
+
+// tenantKey returns the key of the token ID or subject in the tenant, length prefixed so that no two pairs share a key.
+func tenantKey(tenantID, id string) string {
+	return strconv.Itoa(len(tenantID)) + ":" + tenantID + ":" + id
+}
+
+type cacheEntry struct {
+	revoked   bool
+	before    time.Time
+	expiresAt time.Time
+}
+
+// List checks tokens against a Store, caching the lookups in memory for a TTL so that the store is not queried
+// on every request. Revocations made through the List are visible to it at once, while revocations made by
+// other instances of the service sharing the store are picked up within the TTL.
+type List struct {
+	store Store
+	ttl   time.Duration
+	now   func() time.Time
+
+	mu      sync.Mutex
+	cache   map[string]cacheEntry
+	lookups int
+}
+
+// NewList creates a List over store caching lookups for ttl, not at all when ttl is zero.
+func NewList(store Store, ttl time.Duration) *List {
+	return &List{
+		store: store,
+		ttl:   ttl,
+		now:   time.Now,
+		cache: make(map[string]cacheEntry),
+	}
+}
+
+func (l *List) RevokeToken(ctx context.Context, tenantID, jti string, expiresAt time.Time) error {
+	if err := l.store.RevokeToken(ctx, tenantID, jti, expiresAt); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cache["jti:"+tenantKey(tenantID, jti)] = cacheEntry{revoked: true, expiresAt: expiresAt}
+	return nil
+}
+
+func (l *List) RevokeSubject(ctx context.Context, tenantID, subject string, before time.Time) error {
+	if err := l.store.RevokeSubject(ctx, tenantID, subject, before); err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.cache, "sub:"+tenantKey(tenantID, subject))
+	return nil
+}
+
+func (l *List) TokenRevoked(ctx context.Context, tenantID, jti string) (bool, error) {
+	e, err := l.lookup("jti:"+tenantKey(tenantID, jti), func() (cacheEntry, error) {
+		revoked, err := l.store.TokenRevoked(ctx, tenantID, jti)
+		return cacheEntry{revoked: revoked}, err
+	})
+	return e.revoked, err
+}
+
+func (l *List) SubjectRevokedBefore(ctx context.Context, tenantID, subject string) (time.Time, error) {
+	e, err := l.lookup("sub:"+tenantKey(tenantID, subject), func() (cacheEntry, error) {
+		before, err := l.store.SubjectRevokedBefore(ctx, tenantID, subject)
+		return cacheEntry{before: before}, err
+	})
+	return e.before, err
+}
+
+// Revoked reports whether the token of the tenant with the ID jti, of subject and issued at issuedAt, is revoked.
+// Tokens without an ID can only be revoked by subject, and tokens without an issue time are revoked
+// with every token of their subject, as they cannot be told to be issued after the revocation.
+func (l *List) Revoked(ctx context.Context, tenantID, jti, subject string, issuedAt time.Time) (bool, error) {
+	if jti != "" {
+		revoked, err := l.TokenRevoked(ctx, tenantID, jti)
+		if err != nil || revoked {
+			return revoked, err
+		}
+	}
+	before, err := l.SubjectRevokedBefore(ctx, tenantID, subject)
+	if err != nil {
+		return false, err
+	}
+	return !before.IsZero() && issuedAt.Before(before), nil
+}
+
+// lookup returns the cached entry of key, or the entry fetched from the store which is then cached.
+func (l *List) lookup(key string, fetch func() (cacheEntry, error)) (cacheEntry, error) {
+	l.mu.Lock()
+	now := l.now()
+	if e, ok := l.cache[key]; ok && now.Before(e.expiresAt) {
+		l.mu.Unlock()
+		return e, nil
+	}
+	l.mu.Unlock()
+
+	// the store is queried without holding the lock, concurrent misses of a key fetching it more than once
+	e, err := fetch()
+	if err != nil || l.ttl <= 0 {
+		return e, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lookups++
+	if l.lookups%sweepEvery == 0 {
+		l.sweep(now)
+	}
+	e.expiresAt = now.Add(l.ttl)
+	l.cache[key] = e
+	return e, nil
+}
+
+// sweep removes the expired cache entries.
+func (l *List) sweep(now time.Time) {
+	for key, e := range l.cache {
+		if !now.Before(e.expiresAt) {
+			delete(l.cache, key)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package revocation
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingStore counts the lookups reaching the store.
+type countingStore struct {
+	Store
+	lookups int
+}
+
+func (s *countingStore) TokenRevoked(ctx context.Context, tenantID, jti string) (bool, error) {
+	s.lookups++
+	return s.Store.TokenRevoked(ctx, tenantID, jti)
+}
+
+func (s *countingStore) SubjectRevokedBefore(ctx context.Context, tenantID, subject string) (time.Time, error) {
+	s.lookups++
+	return s.Store.SubjectRevokedBefore(ctx, tenantID, subject)
+}
+
+func TestList_Revoked(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	shared := NewMemoryStore()
+	shared.now = func() time.Time { return now }
+	store := &countingStore{Store: shared}
+	list := NewList(store, 30*time.Second)
+	list.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		tenantID string
+		jti      string
+		subject  string
+		issuedAt time.Time
+		want     bool
+	}{
+		{name: "revoked by ID", tenantID: "acme", jti: "token-1", subject: "user1", issuedAt: now, want: true},
+		{name: "other ID", tenantID: "acme", jti: "token-2", subject: "user1", issuedAt: now},
+		{name: "issued before the subject revocation", tenantID: "acme", jti: "token-3", subject: "user2", issuedAt: now.Add(-2 * time.Hour), want: true},
+		{name: "issued after the subject revocation", tenantID: "acme", jti: "token-3", subject: "user2", issuedAt: now},
+		{name: "no ID", tenantID: "acme", subject: "user2", issuedAt: now},
+		{name: "no issue time", tenantID: "acme", jti: "token-4", subject: "user2", want: true},
+		{name: "other subject", tenantID: "acme", jti: "token-5", subject: "user3", issuedAt: now.Add(-2 * time.Hour)},
+		{name: "ID revoked in another tenant", tenantID: "globex", jti: "token-1", subject: "user1", issuedAt: now},
+		{name: "subject revoked in another tenant", tenantID: "globex", jti: "token-3", subject: "user2", issuedAt: now.Add(-2 * time.Hour)},
+		{name: "tenant and ID not mixed up", tenantID: "acme:token-1", jti: "", subject: "user1", issuedAt: now},
+	}
+	assert.NoError(t, list.RevokeToken(ctx, "acme", "token-1", now.Add(time.Hour)))
+	assert.NoError(t, list.RevokeSubject(ctx, "acme", "user2", now.Add(-time.Hour)))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := list.Revoked(ctx, tt.tenantID, tt.jti, tt.subject, tt.issuedAt)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	// lookups are cached, revocations by other instances being picked up once the cache expires
+	store.lookups = 0
+	_, err := list.Revoked(ctx, "acme", "token-5", "user3", now)
+	assert.NoError(t, err)
+	assert.Zero(t, store.lookups)
+	assert.NoError(t, shared.RevokeToken(ctx, "acme", "token-5", now.Add(time.Hour)))
+	revoked, err := list.Revoked(ctx, "acme", "token-5", "user3", now)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+	now = now.Add(30 * time.Second)
+	revoked, err = list.Revoked(ctx, "acme", "token-5", "user3", now)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	// revocations through the list are visible at once
+	assert.NoError(t, list.RevokeSubject(ctx, "acme", "user3", now))
+	revoked, err = list.Revoked(ctx, "acme", "", "user3", now.Add(-time.Second))
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	list.sweep(now.Add(time.Hour))
+	assert.Empty(t, list.cache)
+}
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	assert.NoError(t, store.RevokeToken(ctx, "acme", "token-1", now.Add(time.Hour)))
+	revoked, err := store.TokenRevoked(ctx, "acme", "token-1")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = store.TokenRevoked(ctx, "globex", "token-1")
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	// subject revocations only ever widen
+	assert.NoError(t, store.RevokeSubject(ctx, "acme", "user1", now))
+	assert.NoError(t, store.RevokeSubject(ctx, "acme", "user1", now.Add(-time.Hour)))
+	before, err := store.SubjectRevokedBefore(ctx, "acme", "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, now, before)
+	before, err = store.SubjectRevokedBefore(ctx, "globex", "user1")
+	assert.NoError(t, err)
+	assert.Zero(t, before)
+
+	// revoked tokens are forgotten once expired
+	now = now.Add(time.Hour)
+	revoked, err = store.TokenRevoked(ctx, "acme", "token-1")
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+	store.sweep(now)
+	assert.Empty(t, store.tokens)
+}
+
+func TestRedisStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	store := NewRedisStore(client, "coupon:")
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	assert.NoError(t, store.RevokeToken(ctx, "acme", "token-1", now.Add(time.Minute)))
+	assert.NoError(t, store.RevokeToken(ctx, "acme", "expired", now.Add(-time.Minute)))
+	revoked, err := store.TokenRevoked(ctx, "acme", "token-1")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	revoked, err = store.TokenRevoked(ctx, "globex", "token-1")
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+	assert.True(t, mr.Exists("coupon:jti:4:acme:token-1"))
+	assert.False(t, mr.Exists("coupon:jti:4:acme:expired"))
+
+	before, err := store.SubjectRevokedBefore(ctx, "acme", "user1")
+	assert.NoError(t, err)
+	assert.Zero(t, before)
+	assert.NoError(t, store.RevokeSubject(ctx, "acme", "user1", now))
+	assert.NoError(t, store.RevokeSubject(ctx, "acme", "user1", now.Add(-time.Hour)))
+	before, err = store.SubjectRevokedBefore(ctx, "acme", "user1")
+	assert.NoError(t, err)
+	assert.True(t, now.Equal(before))
+	before, err = store.SubjectRevokedBefore(ctx, "globex", "user1")
+	assert.NoError(t, err)
+	assert.Zero(t, before)
+
+	mr.FastForward(time.Minute)
+	revoked, err = store.TokenRevoked(ctx, "acme", "token-1")
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	mr.Close()
+	_, err = store.TokenRevoked(ctx, "acme", "token-1")
+	assert.Error(t, err)
+}
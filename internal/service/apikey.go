@@ -0,0 +1,160 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"coupon_service/internal/entity"
+	"coupon_service/internal/identity"
+	"coupon_service/pkg"
+)
+
+const (
+	// apiKeyPrefix starts every API key, so that leaked keys are easy to recognize by secret scanners.
+	apiKeyPrefix = "cs_"
+	// maxAPIKeyNameLength bounds the name of an API key, in characters.
+	maxAPIKeyNameLength = 100
+)
+
+// apiKeySecret returns the key a caller presents, made of the ID of the key and of its secret.
+func apiKeySecret(id, secret string) string {
+	return apiKeyPrefix + id + "." + secret
+}
+
+// hashAPIKeySecret returns the hex encoded SHA-256 hash of the secret. Secrets are random,
+// so a fast hash does not make them easier to guess.
+func hashAPIKeySecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIKey creates an API key for the tenant of the caller and returns it with the key to present, which is not stored and cannot be shown again.
+func (s Service) CreateAPIKey(ctx context.Context, key entity.APIKey) (entity.APIKey, string, error) {
+	if s.apiKeys == nil {
+		return entity.APIKey{}, "", pkg.Errorf(pkg.ENOTIMPLEMENTED, "API keys are not enabled", nil)
+	}
+	key.Name = strings.TrimSpace(key.Name)
+	if key.Name == "" {
+		return entity.APIKey{}, "", pkg.Errorf(pkg.EINVALID, "name cannot be empty", nil)
+	}
+	if utf8.RuneCountInString(key.Name) > maxAPIKeyNameLength {
+		return entity.APIKey{}, "", pkg.Errorf(pkg.EINVALID, fmt.Sprintf("name cannot be longer than %d characters", maxAPIKeyNameLength), nil)
+	}
+	if len(key.Scopes) == 0 {
+		return entity.APIKey{}, "", pkg.Errorf(pkg.EINVALID, "minimum of one scope required", nil)
+	}
+	now := s.now().UTC()
+	if key.Expired(now) {
+		return entity.APIKey{}, "", pkg.Errorf(pkg.EINVALID, "expiry is in the past", nil)
+	}
+
+	id, err := randomToken(9)
+	if err != nil {
+		return entity.APIKey{}, "", err
+	}
+	secret, err := randomToken(32)
+	if err != nil {
+		return entity.APIKey{}, "", err
+	}
+
+	principal, _ := identity.FromContext(ctx)
+	key.ID = id
+	key.TenantID = principal.TenantID
+	key.Hash = hashAPIKeySecret(secret)
+	key.CreatedAt = now
+	key.CreatedBy = principal.UserID
+	key.LastUsedAt = time.Time{}
+	if err := s.audit(ctx, entity.AuditAPIKeyCreated, "", nil, redactAPIKey(key)); err != nil {
+		return entity.APIKey{}, "", err
+	}
+	if err := s.apiKeys.Save(key); err != nil {
+		return entity.APIKey{}, "", err
+	}
+	return key, apiKeySecret(id, secret), nil
+}
+
+// ListAPIKeys returns every API key of the tenant of the caller, oldest first.
+func (s Service) ListAPIKeys(ctx context.Context) ([]entity.APIKey, error) {
+	if s.apiKeys == nil {
+		return nil, pkg.Errorf(pkg.ENOTIMPLEMENTED, "API keys are not enabled", nil)
+	}
+	principal, _ := identity.FromContext(ctx)
+	return s.apiKeys.List(principal.TenantID)
+}
+
+// RevokeAPIKey deletes the API key of the tenant of the caller, which is rejected from then on.
+func (s Service) RevokeAPIKey(ctx context.Context, id string) error {
+	if s.apiKeys == nil {
+		return pkg.Errorf(pkg.ENOTIMPLEMENTED, "API keys are not enabled", nil)
+	}
+	key, err := s.apiKeys.FindByID(id)
+	if err != nil {
+		return err
+	}
+	// the keys of other tenants are reported missing, so that their IDs are not disclosed
+	if principal, _ := identity.FromContext(ctx); key.TenantID != principal.TenantID {
+		return pkg.Errorf(pkg.ENOTFOUND, "API key not found", nil)
+	}
+	if err := s.audit(ctx, entity.AuditAPIKeyRevoked, "", redactAPIKey(key), nil); err != nil {
+		return err
+	}
+	return s.apiKeys.Delete(id)
+}
+
+// redactAPIKey returns the key without the hash of its secret, to be recorded in the audit log.
+func redactAPIKey(key entity.APIKey) entity.APIKey {
+	key.Hash = ""
+	return key
+}
+
+// AuthenticateAPIKey returns the API key presented by a caller, if it is valid, and records its use.
+func (s Service) AuthenticateAPIKey(_ context.Context, presented string) (entity.APIKey, error) {
+	invalid := pkg.Errorf(pkg.EUNAUTHORIZED, "invalid API key", nil)
+	if s.apiKeys == nil {
+		return entity.APIKey{}, invalid
+	}
+	id, secret, ok := strings.Cut(strings.TrimPrefix(presented, apiKeyPrefix), ".")
+	if !ok || !strings.HasPrefix(presented, apiKeyPrefix) {
+		return entity.APIKey{}, invalid
+	}
+
+	key, err := s.apiKeys.FindByID(id)
+	if err != nil {
+		if pkg.ErrorCode(err) == pkg.ENOTFOUND {
+			return entity.APIKey{}, invalid
+		}
+		return entity.APIKey{}, err
+	}
+	if subtle.ConstantTimeCompare([]byte(hashAPIKeySecret(secret)), []byte(key.Hash)) != 1 {
+		return entity.APIKey{}, invalid
+	}
+	now := s.now().UTC()
+	if key.Expired(now) {
+		return entity.APIKey{}, pkg.Errorf(pkg.EUNAUTHORIZED, "API key has expired", nil)
+	}
+
+	// a failure to record the use of the key does not deny the request
+	if err := s.apiKeys.Touch(key.ID, now); err != nil {
+		log.Printf("failed to record the use of API key %s: %v", key.ID, err)
+	}
+	key.LastUsedAt = now
+	return key, nil
+}
+
+// randomToken returns n random bytes encoded in unpadded base64url.
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", pkg.Errorf(pkg.EINTERNAL, "failed to generate a random token", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+
+	"coupon_service/internal/entity"
+	"coupon_service/internal/identity"
+	"coupon_service/pkg"
+	"github.com/google/uuid"
+)
+
+// audit appends an event for an administrative change to the audit log, if one is configured. Changes are recorded
+// before they are made and abandoned when they cannot be, so that no change escapes the audit log.
+func (s Service) audit(ctx context.Context, action entity.AuditAction, code string, before, after any) error {
+	if s.auditLog == nil {
+		return nil
+	}
+
+	principal, _ := identity.FromContext(ctx)
+	event := entity.AuditEvent{
+		ID:         uuid.New().String(),
+		TenantID:   principal.TenantID,
+		Time:       s.now().UTC(),
+		Actor:      principal.UserID,
+		Action:     action,
+		CouponCode: code,
+		RequestID:  identity.RequestID(ctx),
+	}
+	var err error
+	if event.Before, err = snapshot(before); err != nil {
+		return err
+	}
+	if event.After, err = snapshot(after); err != nil {
+		return err
+	}
+
+	if err := s.auditLog.Append(event); err != nil {
+		return pkg.Errorf(pkg.EINTERNAL, "failed to record audit event", err)
+	}
+	return nil
+}
+
+// snapshot returns the JSON representation of v, or nil when v is nil.
+func snapshot(v any) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, pkg.Errorf(pkg.EINTERNAL, "failed to snapshot audit state", err)
+	}
+	return b, nil
+}
+
+// QueryAudit returns the audit events of the tenant of the caller matching the filter, oldest first.
+func (s Service) QueryAudit(ctx context.Context, filter entity.AuditFilter) ([]entity.AuditEvent, error) {
+	if s.auditLog == nil {
+		return nil, pkg.Errorf(pkg.ENOTIMPLEMENTED, "audit log is not enabled", nil)
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.To.Before(filter.From) {
+		return nil, pkg.Errorf(pkg.EINVALID, "end of time range is before its start", nil)
+	}
+	filter.CouponCode = pkg.NormalizeCode(filter.CouponCode)
+	principal, _ := identity.FromContext(ctx)
+	filter.TenantID, filter.AnyTenant = principal.TenantID, false
+	return s.auditLog.Query(filter)
+}
+
+// VerifyAudit walks the audit chain of the tenant of the caller and reports its first broken link, if any.
+func (s Service) VerifyAudit(ctx context.Context) (entity.AuditVerification, error) {
+	if s.auditLog == nil {
+		return entity.AuditVerification{}, pkg.Errorf(pkg.ENOTIMPLEMENTED, "audit log is not enabled", nil)
+	}
+	principal, _ := identity.FromContext(ctx)
+	// the head is read first, so that events appended meanwhile are not taken for a truncated chain
+	head, err := s.auditLog.Head(principal.TenantID)
+	if err != nil {
+		return entity.AuditVerification{}, err
+	}
+	events, err := s.auditLog.Query(entity.AuditFilter{TenantID: principal.TenantID})
+	if err != nil {
+		return entity.AuditVerification{}, err
+	}
+	return entity.VerifyAuditChain(events, head, s.auditKey), nil
+}
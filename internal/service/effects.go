@@ -0,0 +1,134 @@
+package service
+
+import (
+	"sort"
+
+	"coupon_service/internal/entity"
+	"coupon_service/pkg"
+)
+
+// applyEffect computes the discount granted by the coupon to the basket
+// and records it on every basket line the coupon affected.
+func (s Service) applyEffect(coupon entity.Coupon, terms entity.Terms, basket entity.Basket) (entity.Basket, error) {
+	currency := basket.Value.Currency
+
+	var lines []entity.BasketLine
+	if len(basket.Lines) > 0 {
+		lines = make([]entity.BasketLine, len(basket.Lines))
+		for i, l := range basket.Lines {
+			l.AppliedDiscount = entity.Money{Currency: currency}
+			l.Effect = ""
+			lines[i] = l
+		}
+	}
+
+	var err error
+	switch coupon.Effect {
+	case entity.EffectFreeShipping:
+		err = freeShipping(lines)
+	case entity.EffectBuyXGetY:
+		err = buyXGetY(*coupon.BuyXGetY, lines, s.rounding)
+	case entity.EffectCheapestItemFree:
+		err = cheapestItemFree(lines)
+	case entity.EffectTieredDiscount:
+		tier, ok := terms.TierFor(basket.Value)
+		if !ok {
+			return entity.Basket{}, pkg.Errorf(pkg.EINVALID, "basket value below minimum required for coupon", nil)
+		}
+		return discountBasket(basket, lines, tier.Discount), nil
+	default:
+		return discountBasket(basket, lines, terms.Discount), nil
+	}
+	if err != nil {
+		return entity.Basket{}, err
+	}
+
+	discount := entity.Money{Currency: currency}
+	for _, l := range lines {
+		discount.Amount += l.AppliedDiscount.Amount
+	}
+	discount.Amount = min(discount.Amount, basket.Value.Amount)
+
+	basket.Lines = lines
+	basket.AppliedDiscount = discount
+	basket.ApplicationSuccessful = true
+	return basket, nil
+}
+
+// discountBasket applies a discount to the basket as a whole, leaving its lines untouched.
+func discountBasket(basket entity.Basket, lines []entity.BasketLine, discount entity.Money) entity.Basket {
+	basket.Lines = lines
+	basket.AppliedDiscount = discount
+	basket.ApplicationSuccessful = true
+	return basket
+}
+
+// freeShipping zeroes every shipping line.
+func freeShipping(lines []entity.BasketLine) error {
+	found := false
+	for i := range lines {
+		if lines[i].Type == entity.LineShipping {
+			lines[i].AppliedDiscount = lines[i].Total()
+			lines[i].Effect = entity.EffectFreeShipping
+			found = true
+		}
+	}
+	if !found {
+		return pkg.Errorf(pkg.EINVALID, "basket has no shipping line", nil)
+	}
+	return nil
+}
+
+// buyXGetY discounts Y units for every X+Y qualifying units in the basket.
+// The cheapest qualifying units are the ones discounted.
+func buyXGetY(offer entity.BuyXGetY, lines []entity.BasketLine, rounding entity.RoundingMode) error {
+	var qualifying []int
+	units := 0
+	for i, l := range lines {
+		if l.Type == entity.LineProduct && offer.Qualifies(l.SKU) {
+			qualifying = append(qualifying, i)
+			units += l.Quantity
+		}
+	}
+
+	discounted := units / (offer.Buy + offer.Get) * offer.Get
+	if discounted == 0 {
+		return pkg.Errorf(pkg.EINVALID, "basket does not contain enough qualifying products for coupon", nil)
+	}
+
+	sort.SliceStable(qualifying, func(a, b int) bool {
+		return lines[qualifying[a]].UnitPrice.Amount < lines[qualifying[b]].UnitPrice.Amount
+	})
+	for _, i := range qualifying {
+		if discounted == 0 {
+			break
+		}
+		n := min(discounted, lines[i].Quantity)
+		discounted -= n
+
+		price := entity.Money{Amount: lines[i].UnitPrice.Amount * int64(n), Currency: lines[i].UnitPrice.Currency}
+		lines[i].AppliedDiscount = price.MulRatio(offer.DiscountPercent, 100, rounding)
+		lines[i].Effect = entity.EffectBuyXGetY
+	}
+	return nil
+}
+
+// cheapestItemFree zeroes a single unit of the cheapest product in the basket.
+func cheapestItemFree(lines []entity.BasketLine) error {
+	cheapest := -1
+	for i, l := range lines {
+		if l.Type != entity.LineProduct || l.Quantity == 0 {
+			continue
+		}
+		if cheapest < 0 || l.UnitPrice.Amount < lines[cheapest].UnitPrice.Amount {
+			cheapest = i
+		}
+	}
+	if cheapest < 0 {
+		return pkg.Errorf(pkg.EINVALID, "basket has no products", nil)
+	}
+
+	lines[cheapest].AppliedDiscount = lines[cheapest].UnitPrice
+	lines[cheapest].Effect = entity.EffectCheapestItemFree
+	return nil
+}
@@ -1,13 +1,87 @@
 package service
 
-import "coupon_service/internal/repository"
+import (
+	"time"
+
+	"coupon_service/internal/entity"
+	"coupon_service/internal/repository"
+	"coupon_service/internal/revocation"
+	"coupon_service/internal/signedcode"
+	"coupon_service/pkg"
+)
 
 type Service struct {
-	repo repository.CouponRepository
+	repo        repository.CouponRepository
+	rounding    entity.RoundingMode
+	codePolicy  pkg.CodePolicy
+	signedCodes *signedcode.Codec
+	ledger      repository.RedemptionLedger
+	auditLog    repository.AuditRepository
+	auditKey    []byte
+	apiKeys     repository.APIKeyRepository
+	revocations revocation.Store
+	revokeFor   time.Duration
+	now         func() time.Time
+}
+
+// Option customizes the Service created by New.
+type Option func(*Service)
+
+// WithRounding sets the rounding mode used when discounts produce fractional minor units.
+func WithRounding(mode entity.RoundingMode) Option {
+	return func(s *Service) {
+		s.rounding = mode
+	}
+}
+
+// WithCodePolicy sets the policy coupon codes are validated and generated with.
+func WithCodePolicy(policy pkg.CodePolicy) Option {
+	return func(s *Service) {
+		s.codePolicy = policy
+	}
+}
+
+// WithSignedCodes enables signed codes, verified with the codec and redeemed once through the ledger.
+func WithSignedCodes(codec *signedcode.Codec, ledger repository.RedemptionLedger) Option {
+	return func(s *Service) {
+		s.signedCodes = codec
+		s.ledger = ledger
+	}
 }
 
-func New(repo repository.CouponRepository) Service {
-	return Service{
-		repo: repo,
+// WithAuditLog records every administrative change in the audit log, whose chain of hashes is keyed with key.
+func WithAuditLog(log repository.AuditRepository, key []byte) Option {
+	return func(s *Service) {
+		s.auditLog = log
+		s.auditKey = key
+	}
+}
+
+// WithAPIKeys enables the API keys of machine to machine callers, stored in the repository.
+func WithAPIKeys(repo repository.APIKeyRepository) Option {
+	return func(s *Service) {
+		s.apiKeys = repo
+	}
+}
+
+// WithRevocations enables the revocation of bearer tokens, stored in the store. Revoked tokens whose expiry
+// is not given are remembered for maxLifetime, the longest lifetime of the tokens.
+func WithRevocations(store revocation.Store, maxLifetime time.Duration) Option {
+	return func(s *Service) {
+		s.revocations = store
+		s.revokeFor = maxLifetime
+	}
+}
+
+func New(repo repository.CouponRepository, opts ...Option) Service {
+	s := Service{
+		repo:       repo,
+		rounding:   entity.RoundHalfUp,
+		codePolicy: pkg.DefaultCodePolicy(),
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(&s)
 	}
+	return s
 }
@@ -1,12 +1,25 @@
 package service
 
 import (
+	"context"
+	"time"
+
 	"coupon_service/internal/entity"
+	"coupon_service/internal/signedcode"
 )
 
 //go:generate go run github.com/matryer/moq -out service_mock.go -stub . CouponService
 type CouponService interface {
-	ApplyCoupon(code string, value int) (entity.Basket, error)
-	CreateCoupon(code string, discount, minBasketValue int) error
-	GetCoupons([]string) ([]entity.Coupon, error)
+	ApplyCoupon(ctx context.Context, code string, basket entity.Basket) (entity.Basket, error)
+	CreateCoupon(context.Context, entity.Coupon) (entity.Coupon, error)
+	GetCoupons(ctx context.Context, codes []string, filter entity.CouponFilter) ([]entity.Coupon, error)
+	IssueSignedCodes(ctx context.Context, claims signedcode.Claims, count int) ([]string, error)
+	QueryAudit(context.Context, entity.AuditFilter) ([]entity.AuditEvent, error)
+	VerifyAudit(context.Context) (entity.AuditVerification, error)
+	CreateAPIKey(context.Context, entity.APIKey) (entity.APIKey, string, error)
+	ListAPIKeys(context.Context) ([]entity.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string) error
+	AuthenticateAPIKey(ctx context.Context, key string) (entity.APIKey, error)
+	RevokeToken(ctx context.Context, jti string, expiresAt time.Time) (entity.TokenRevocation, error)
+	RevokeSubjectTokens(ctx context.Context, subject string, issuedBefore time.Time) (entity.TokenRevocation, error)
 }
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"coupon_service/internal/entity"
+	"coupon_service/internal/identity"
+	"coupon_service/pkg"
+)
+
+// RevokeToken revokes the bearer token of the tenant of the caller with the ID jti until its expiry, remembered for the longest lifetime
+// of the tokens when it is not given.
+func (s Service) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) (entity.TokenRevocation, error) {
+	if s.revocations == nil {
+		return entity.TokenRevocation{}, pkg.Errorf(pkg.ENOTIMPLEMENTED, "token revocation is not enabled", nil)
+	}
+	jti = strings.TrimSpace(jti)
+	if jti == "" {
+		return entity.TokenRevocation{}, pkg.Errorf(pkg.EINVALID, "jti cannot be empty", nil)
+	}
+	now := s.now().UTC()
+	if expiresAt.IsZero() {
+		expiresAt = now.Add(s.revokeFor)
+	}
+	if !expiresAt.After(now) {
+		return entity.TokenRevocation{}, pkg.Errorf(pkg.EINVALID, "token has already expired", nil)
+	}
+
+	// tokens are revoked in the tenant of the caller only
+	principal, _ := identity.FromContext(ctx)
+	r := entity.TokenRevocation{TokenID: jti, ExpiresAt: expiresAt.UTC()}
+	if err := s.audit(ctx, entity.AuditTokenRevoked, "", nil, r); err != nil {
+		return entity.TokenRevocation{}, err
+	}
+	if err := s.revocations.RevokeToken(ctx, principal.TenantID, r.TokenID, r.ExpiresAt); err != nil {
+		return entity.TokenRevocation{}, err
+	}
+	return r, nil
+}
+
+// RevokeSubjectTokens revokes every bearer token of the subject in the tenant of the caller issued before issuedBefore,
+// now when zero.
+func (s Service) RevokeSubjectTokens(ctx context.Context, subject string, issuedBefore time.Time) (entity.TokenRevocation, error) {
+	if s.revocations == nil {
+		return entity.TokenRevocation{}, pkg.Errorf(pkg.ENOTIMPLEMENTED, "token revocation is not enabled", nil)
+	}
+	if subject == "" {
+		return entity.TokenRevocation{}, pkg.Errorf(pkg.EINVALID, "subject cannot be empty", nil)
+	}
+	now := s.now().UTC()
+	if issuedBefore.IsZero() {
+		issuedBefore = now
+	}
+	// revoking tokens yet to be issued would lock the subject out
+	if issuedBefore.After(now) {
+		return entity.TokenRevocation{}, pkg.Errorf(pkg.EINVALID, "issued_before cannot be in the future", nil)
+	}
+
+	principal, _ := identity.FromContext(ctx)
+	r := entity.TokenRevocation{Subject: subject, IssuedBefore: issuedBefore.UTC()}
+	if err := s.audit(ctx, entity.AuditSubjectRevoked, "", nil, r); err != nil {
+		return entity.TokenRevocation{}, err
+	}
+	if err := s.revocations.RevokeSubject(ctx, principal.TenantID, r.Subject, r.IssuedBefore); err != nil {
+		return entity.TokenRevocation{}, err
+	}
+	return r, nil
+}
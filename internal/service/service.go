@@ -1,70 +1,421 @@
 package service
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
 	"strings"
+	"unicode/utf8"
 
 	"coupon_service/internal/entity"
+	"coupon_service/internal/identity"
+	"coupon_service/internal/signedcode"
 	"coupon_service/pkg"
 
 	"github.com/google/uuid"
 )
 
-func (s Service) ApplyCoupon(code string, value int) (entity.Basket, error) {
-	coupon, err := s.repo.FindByCode(code)
+const (
+	// maxGeneratedCodeCollisions bounds how many generated codes may collide with existing coupons before giving up.
+	maxGeneratedCodeCollisions = 10
+	// maxSuggestions bounds how many codes are suggested when a coupon is not found.
+	maxSuggestions = 3
+	// maxDescriptionLength, maxLabels and maxLabelLength bound the metadata of a coupon, in characters.
+	maxDescriptionLength = 500
+	maxLabels            = 20
+	maxLabelLength       = 50
+	// permCreateCoupons is the permission of the coupon managers, who read the personal coupons of every user.
+	permCreateCoupons = "coupons:create"
+)
+
+func (s Service) ApplyCoupon(ctx context.Context, code string, basket entity.Basket) (entity.Basket, error) {
+	code = pkg.NormalizeCode(code)
+	principal, _ := identity.FromContext(ctx)
+	// signed codes are verified by their signature, so they are never taken for mistyped generated codes
+	if s.signedCodes != nil && signedcode.Looks(code) {
+		return s.applySignedCode(principal.TenantID, code, basket)
+	}
+	if err := s.codePolicy.VerifyCheckCharacter(code); err != nil {
+		return entity.Basket{}, err
+	}
+
+	coupon, err := s.findCoupon(principal, code, false)
+	if err != nil {
+		return entity.Basket{}, s.withSuggestions(err, code, principal)
+	}
+	return s.apply(coupon, basket)
+}
+
+// findCoupon returns the coupon with the code visible to the principal. Coupons of other tenants are never found,
+// and personal coupons of other users are reported as unknown unless anyOwner is set, so they can be neither read
+// nor applied.
+func (s Service) findCoupon(principal identity.Principal, code string, anyOwner bool) (entity.Coupon, error) {
+	coupon, err := s.repo.FindByCode(principal.TenantID, code)
+	if err != nil {
+		return entity.Coupon{}, err
+	}
+	if !anyOwner && coupon.Owner != "" && coupon.Owner != principal.UserID {
+		return entity.Coupon{}, pkg.Errorf(pkg.ENOTFOUND, "coupon not found", nil)
+	}
+	return coupon, nil
+}
+
+// applySignedCode applies the coupon encoded in a signed code issued for the tenant and records its redemption,
+// so each signed code is applied at most once.
+func (s Service) applySignedCode(tenantID, code string, basket entity.Basket) (entity.Basket, error) {
+	claims, err := s.signedCodes.Decode(code, tenantID)
 	if err != nil {
 		return entity.Basket{}, err
 	}
+	if claims.Expired(s.now()) {
+		return entity.Basket{}, pkg.Errorf(pkg.EINVALID, "coupon expired", nil)
+	}
+
+	applied, err := s.apply(signedCoupon(code, claims), basket)
+	if err != nil {
+		return entity.Basket{}, err
+	}
+	if err := s.ledger.Redeem(tenantID, claims.CampaignID, claims.Serial); err != nil {
+		return entity.Basket{}, err
+	}
+	return applied, nil
+}
 
-	if value < coupon.MinBasketValue {
+// signedCoupon returns the fixed discount coupon encoded in a signed code.
+func signedCoupon(code string, claims signedcode.Claims) entity.Coupon {
+	return entity.Coupon{
+		ID:       fmt.Sprintf("signed-%d-%d", claims.CampaignID, claims.Serial),
+		TenantID: claims.TenantID,
+		Code:     code,
+		Effect:   entity.EffectFixedDiscount,
+		// baskets must be worth at least the discount, so it never exceeds their value
+		Terms: []entity.Terms{{Discount: claims.Discount, MinBasketValue: claims.Discount}},
+	}
+}
+
+// signedCodesIssued is the audit snapshot of a batch of issued signed codes, which are not stored.
+type signedCodesIssued struct {
+	Claims signedcode.Claims
+	Count  int
+}
+
+// IssueSignedCodes signs count codes with the claims, numbered with consecutive serials from claims.Serial on,
+// for the tenant of the caller.
+func (s Service) IssueSignedCodes(ctx context.Context, claims signedcode.Claims, count int) ([]string, error) {
+	if s.signedCodes == nil {
+		return nil, pkg.Errorf(pkg.ENOTIMPLEMENTED, "signed codes are not enabled", nil)
+	}
+	if count < 1 {
+		return nil, pkg.Errorf(pkg.EINVALID, "count should be positive", nil)
+	}
+	if uint64(claims.Serial)+uint64(count)-1 > math.MaxUint32 {
+		return nil, pkg.Errorf(pkg.EINVALID, "serials out of range", nil)
+	}
+	if claims.Expired(s.now()) {
+		return nil, pkg.Errorf(pkg.EINVALID, "expiry date is in the past", nil)
+	}
+
+	principal, _ := identity.FromContext(ctx)
+	claims.TenantID = principal.TenantID
+	codes := make([]string, count)
+	for i := range codes {
+		c := claims
+		c.Serial = claims.Serial + uint32(i)
+		code, err := s.signedCodes.Encode(c)
+		if err != nil {
+			return nil, err
+		}
+		codes[i] = code
+	}
+
+	issued := signedCodesIssued{Claims: claims, Count: count}
+	if err := s.audit(ctx, entity.AuditSignedCodesIssued, "", nil, issued); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// apply checks that the coupon terms allow it to be applied to the basket and applies its effect.
+func (s Service) apply(coupon entity.Coupon, basket entity.Basket) (entity.Basket, error) {
+	value := basket.Value
+	terms, ok := coupon.TermsFor(value.Currency)
+	if !ok {
+		return entity.Basket{}, pkg.Errorf(pkg.EINVALID,
+			fmt.Sprintf("coupon does not support basket currency %s, supported currencies: %s",
+				value.Currency, strings.Join(coupon.Currencies(), ", ")), nil)
+	}
+
+	if value.Amount < terms.MinBasketValue.Amount {
 		return entity.Basket{}, pkg.Errorf(pkg.EINVALID, "basket value below minimum required for coupon", nil)
 	}
 
-	return entity.Basket{
-		Value:                 value,
-		AppliedDiscount:       coupon.Discount,
-		ApplicationSuccessful: true,
-	}, nil
+	for _, l := range basket.Lines {
+		if l.UnitPrice.Currency != value.Currency {
+			return entity.Basket{}, pkg.Errorf(pkg.EINVALID, "basket line currency does not match basket currency", nil)
+		}
+		if l.Quantity < 0 || l.Quantity > entity.MaxLineQuantity || l.UnitPrice.Amount < 0 || l.UnitPrice.Amount > entity.MaxLineUnitPrice {
+			return entity.Basket{}, pkg.Errorf(pkg.EINVALID, "basket line quantity or unit price out of bounds", nil)
+		}
+	}
+	// discounts are computed from the lines, which must therefore describe the basket
+	if !basket.LinesTotal() {
+		return entity.Basket{}, pkg.Errorf(pkg.EINVALID, "basket lines do not add up to basket value", nil)
+	}
+
+	return s.applyEffect(coupon, terms, basket)
 }
 
-func (s Service) CreateCoupon(code string, discount, minBasketValue int) error {
-	if len(code) < 6 {
-		return pkg.Errorf(pkg.EINVALID, "minimum length of code is 6 characters", nil)
+// CreateCoupon validates and stores a new coupon, generating its code when none is given.
+func (s Service) CreateCoupon(ctx context.Context, coupon entity.Coupon) (entity.Coupon, error) {
+	generate := coupon.Code == ""
+	code := pkg.NormalizeCode(coupon.Code)
+	if !generate {
+		if err := s.codePolicy.Validate("code", code); err != nil {
+			return entity.Coupon{}, err
+		}
 	}
 
-	if !pkg.IsAlphaNumericOnly(code) {
-		return pkg.Errorf(pkg.EINVALID, "code must contain only number and letters", nil)
+	if coupon.Effect == "" {
+		coupon.Effect = entity.EffectFixedDiscount
+	}
+	if err := validateEffect(coupon); err != nil {
+		return entity.Coupon{}, err
 	}
 
-	if discount > minBasketValue {
-		return pkg.Errorf(pkg.EINVALID, "discount bigger than minimum basket value", nil)
+	if err := validateTerms(coupon.Effect, coupon.Terms); err != nil {
+		return entity.Coupon{}, err
+	}
+	if utf8.RuneCountInString(coupon.Description) > maxDescriptionLength {
+		return entity.Coupon{}, pkg.Errorf(pkg.EINVALID, fmt.Sprintf("description cannot be longer than %d characters", maxDescriptionLength), nil)
+	}
+	labels, err := validateLabels(coupon.Labels)
+	if err != nil {
+		return entity.Coupon{}, err
+	}
+	coupon.Labels = labels
+	if coupon.Effect != entity.EffectFixedDiscount {
+		for i, t := range coupon.Terms {
+			if len(t.Tiers) > 0 {
+				// the lowest tier is the minimum basket value for the coupon to apply
+				coupon.Terms[i].MinBasketValue = t.Tiers[0].MinBasketValue
+			}
+			coupon.Terms[i].Discount = entity.Money{Currency: t.Currency()}
+		}
 	}
 
-	_, err := s.repo.FindByCode(code)
-	if err == nil {
-		return pkg.Errorf(pkg.ECONFLICT, "coupon already exists", nil)
+	// codes are unique within the tenant of the caller, which owns the coupon
+	principal, _ := identity.FromContext(ctx)
+	if generate {
+		generated, err := s.generateCode(principal.TenantID)
+		if err != nil {
+			return entity.Coupon{}, err
+		}
+		code = generated
+	} else if _, err := s.repo.FindByCode(principal.TenantID, code); err == nil {
+		return entity.Coupon{}, pkg.Errorf(pkg.ECONFLICT, "coupon already exists", nil)
 	}
 
-	coupon := entity.Coupon{
-		ID:             uuid.New().String(),
-		Code:           strings.ToUpper(code),
-		Discount:       discount,
-		MinBasketValue: minBasketValue,
+	coupon.ID = uuid.New().String()
+	coupon.TenantID = principal.TenantID
+	coupon.Code = code
+	coupon.CreatedAt = s.now().UTC()
+	coupon.CreatedBy = principal.UserID
+	coupon.UpdatedAt = coupon.CreatedAt
+	coupon.UpdatedBy = coupon.CreatedBy
+	if err := s.audit(ctx, entity.AuditCouponCreated, coupon.Code, nil, coupon); err != nil {
+		return entity.Coupon{}, err
 	}
 	if err := s.repo.Save(coupon); err != nil {
+		return entity.Coupon{}, err
+	}
+	return coupon, nil
+}
+
+// generateCode returns a generated code not used by any existing coupon of the tenant.
+func (s Service) generateCode(tenantID string) (string, error) {
+	for range maxGeneratedCodeCollisions {
+		code, err := s.codePolicy.Generate()
+		if err != nil {
+			return "", err
+		}
+		if _, err := s.repo.FindByCode(tenantID, code); err != nil {
+			return code, nil
+		}
+	}
+	return "", pkg.Errorf(pkg.ECONFLICT, "could not generate an unused coupon code", nil)
+}
+
+// withSuggestions adds to a not found error the codes of the personal coupons of the user close to the
+// code looked up. Only the user's own coupons are suggested, so no other code is disclosed.
+func (s Service) withSuggestions(err error, code string, principal identity.Principal) error {
+	var e *pkg.Error
+	if principal.UserID == "" || pkg.ErrorCode(err) != pkg.ENOTFOUND || !errors.As(err, &e) {
 		return err
 	}
-	return nil
+	suggestions, suggestErr := s.repo.SuggestCodes(principal.TenantID, code, principal.UserID, maxSuggestions)
+	if suggestErr != nil || len(suggestions) == 0 {
+		return err
+	}
+	return pkg.Errorf(e.Code, e.Message, e.Err).WithDetail("suggestions", suggestions)
 }
 
-func (s Service) GetCoupons(codes []string) ([]entity.Coupon, error) {
-	coupons := make([]entity.Coupon, len(codes))
+// GetCoupons returns the coupons of the tenant of the caller with the given codes, matching the filter.
+func (s Service) GetCoupons(ctx context.Context, codes []string, filter entity.CouponFilter) ([]entity.Coupon, error) {
+	principal, _ := identity.FromContext(ctx)
+	coupons := make([]entity.Coupon, 0, len(codes))
+	filter.Labels = normalizeLabels(filter.Labels)
 
-	for i, code := range codes {
-		coupon, err := s.repo.FindByCode(code)
+	for _, code := range codes {
+		code = pkg.NormalizeCode(code)
+		if s.signedCodes != nil && signedcode.Looks(code) {
+			claims, err := s.signedCodes.Decode(code, principal.TenantID)
+			if err != nil {
+				return nil, err
+			}
+			if coupon := signedCoupon(code, claims); filter.Matches(coupon) {
+				coupons = append(coupons, coupon)
+			}
+			continue
+		}
+		if err := s.codePolicy.VerifyCheckCharacter(code); err != nil {
+			return nil, err
+		}
+		// coupon managers read the personal coupons they hand out, which are only applied by their owner
+		coupon, err := s.findCoupon(principal, code, principal.Granted(permCreateCoupons))
 		if err != nil {
 			return nil, err
 		}
-		coupons[i] = coupon
+		if filter.Matches(coupon) {
+			coupons = append(coupons, coupon)
+		}
 	}
 	return coupons, nil
 }
+
+// validateEffect checks that the coupon effect is known and carries the details it requires.
+func validateEffect(coupon entity.Coupon) error {
+	if !coupon.Effect.Valid() {
+		return pkg.Errorf(pkg.EINVALID, "unknown coupon effect "+string(coupon.Effect), nil)
+	}
+
+	if coupon.Effect != entity.EffectBuyXGetY {
+		if coupon.BuyXGetY != nil {
+			return pkg.Errorf(pkg.EINVALID, "buy_x_get_y details are only allowed for buy_x_get_y coupons", nil)
+		}
+		return nil
+	}
+
+	offer := coupon.BuyXGetY
+	if offer == nil {
+		return pkg.Errorf(pkg.EINVALID, "buy_x_get_y coupons require buy_x_get_y details", nil)
+	}
+	if offer.Buy < 1 || offer.Get < 1 {
+		return pkg.Errorf(pkg.EINVALID, "buy and get quantities should be positive", nil)
+	}
+	if offer.DiscountPercent < 1 || offer.DiscountPercent > 100 {
+		return pkg.Errorf(pkg.EINVALID, "discount percent should be between 1 and 100", nil)
+	}
+	return nil
+}
+
+// validateTerms checks that a coupon has at most one set of terms per currency and that
+// the terms match the coupon effect: fixed discounts are validated as a single tier,
+// tiered discounts through their tiers, while other effects compute their discount
+// from the basket, so their terms carry none.
+func validateTerms(effect entity.Effect, terms []entity.Terms) error {
+	if len(terms) == 0 {
+		return pkg.Errorf(pkg.EINVALID, "coupon must support at least one currency", nil)
+	}
+
+	seen := make(map[string]bool, len(terms))
+	for _, t := range terms {
+		if seen[t.Currency()] {
+			return pkg.Errorf(pkg.EINVALID, "duplicated terms for currency "+t.Currency(), nil)
+		}
+		seen[t.Currency()] = true
+
+		if effect != entity.EffectTieredDiscount && len(t.Tiers) > 0 {
+			return pkg.Errorf(pkg.EINVALID, "tiers are only allowed for tiered_discount coupons", nil)
+		}
+
+		switch effect {
+		case entity.EffectFixedDiscount:
+			if err := validateTiers([]entity.Tier{{MinBasketValue: t.MinBasketValue, Discount: t.Discount}}); err != nil {
+				return err
+			}
+		case entity.EffectTieredDiscount:
+			if len(t.Tiers) == 0 {
+				return pkg.Errorf(pkg.EINVALID, "tiered_discount coupons require at least one tier", nil)
+			}
+			if !t.Discount.IsZero() || !t.MinBasketValue.IsZero() {
+				return pkg.Errorf(pkg.EINVALID, "discount and minimum basket value of tiered_discount coupons are defined by their tiers", nil)
+			}
+			if err := validateTiers(t.Tiers); err != nil {
+				return err
+			}
+		default:
+			if !t.Discount.IsZero() {
+				return pkg.Errorf(pkg.EINVALID, "discount is only allowed for fixed_discount coupons", nil)
+			}
+		}
+	}
+	return nil
+}
+
+// validateTiers checks that tiers are sorted by strictly ascending minimum basket value,
+// share a single currency, and that no discount is bigger than the minimum basket value of its tier.
+func validateTiers(tiers []entity.Tier) error {
+	for i, tier := range tiers {
+		cmp, err := tier.Discount.Compare(tier.MinBasketValue)
+		if err != nil {
+			return pkg.Errorf(pkg.EINVALID, "discount and minimum basket value must have the same currency", err)
+		}
+		if cmp > 0 {
+			return pkg.Errorf(pkg.EINVALID, "discount bigger than minimum basket value", nil)
+		}
+
+		if i == 0 {
+			continue
+		}
+		cmp, err = tier.MinBasketValue.Compare(tiers[i-1].MinBasketValue)
+		if err != nil {
+			return pkg.Errorf(pkg.EINVALID, "all tiers must have the same currency", err)
+		}
+		if cmp <= 0 {
+			return pkg.Errorf(pkg.EINVALID, "tiers must have ascending minimum basket values", nil)
+		}
+	}
+	return nil
+}
+
+// validateLabels checks the number and length of the labels and returns them normalized.
+func validateLabels(labels []string) ([]string, error) {
+	labels = normalizeLabels(labels)
+	if len(labels) > maxLabels {
+		return nil, pkg.Errorf(pkg.EINVALID, fmt.Sprintf("coupon cannot have more than %d labels", maxLabels), nil)
+	}
+	for _, l := range labels {
+		if l == "" {
+			return nil, pkg.Errorf(pkg.EINVALID, "labels cannot be empty", nil)
+		}
+		if utf8.RuneCountInString(l) > maxLabelLength {
+			return nil, pkg.Errorf(pkg.EINVALID, fmt.Sprintf("labels cannot be longer than %d characters", maxLabelLength), nil)
+		}
+	}
+	return labels, nil
+}
+
+// normalizeLabels trims and lower-cases the labels, and sorts them without duplicates.
+func normalizeLabels(labels []string) []string {
+	if len(labels) == 0 {
+		return nil
+	}
+	normalized := make([]string, len(labels))
+	for i, l := range labels {
+		normalized[i] = strings.ToLower(strings.TrimSpace(l))
+	}
+	slices.Sort(normalized)
+	return slices.Compact(normalized)
+}
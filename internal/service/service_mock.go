@@ -4,9 +4,12 @@
 package service
 
 import (
+	"context"
 	"sync"
+	"time"
 
 	"coupon_service/internal/entity"
+	"coupon_service/internal/signedcode"
 )
 
 // Ensure, that CouponServiceMock does implement CouponService.
@@ -19,15 +22,42 @@ var _ CouponService = &CouponServiceMock{}
 //
 //		// make and configure a mocked CouponService
 //		mockedCouponService := &CouponServiceMock{
-//			ApplyCouponFunc: func(code string, value int) (entity.Basket, error) {
+//			ApplyCouponFunc: func(ctx context.Context, code string, basket entity.Basket) (entity.Basket, error) {
 //				panic("mock out the ApplyCoupon method")
 //			},
-//			CreateCouponFunc: func(code string, discount int, minBasketValue int) error {
+//			CreateCouponFunc: func(ctx context.Context, coupon entity.Coupon) (entity.Coupon, error) {
 //				panic("mock out the CreateCoupon method")
 //			},
-//			GetCouponsFunc: func(strings []string) ([]entity.Coupon, error) {
+//			GetCouponsFunc: func(ctx context.Context, codes []string, filter entity.CouponFilter) ([]entity.Coupon, error) {
 //				panic("mock out the GetCoupons method")
 //			},
+//			IssueSignedCodesFunc: func(ctx context.Context, claims signedcode.Claims, count int) ([]string, error) {
+//				panic("mock out the IssueSignedCodes method")
+//			},
+//			QueryAuditFunc: func(ctx context.Context, auditFilter entity.AuditFilter) ([]entity.AuditEvent, error) {
+//				panic("mock out the QueryAudit method")
+//			},
+//			VerifyAuditFunc: func(ctx context.Context) (entity.AuditVerification, error) {
+//				panic("mock out the VerifyAudit method")
+//			},
+//			CreateAPIKeyFunc: func(ctx context.Context, aPIKey entity.APIKey) (entity.APIKey, string, error) {
+//				panic("mock out the CreateAPIKey method")
+//			},
+//			ListAPIKeysFunc: func(ctx context.Context) ([]entity.APIKey, error) {
+//				panic("mock out the ListAPIKeys method")
+//			},
+//			RevokeAPIKeyFunc: func(ctx context.Context, id string) error {
+//				panic("mock out the RevokeAPIKey method")
+//			},
+//			AuthenticateAPIKeyFunc: func(ctx context.Context, key string) (entity.APIKey, error) {
+//				panic("mock out the AuthenticateAPIKey method")
+//			},
+//			RevokeTokenFunc: func(ctx context.Context, jti string, expiresAt time.Time) (entity.TokenRevocation, error) {
+//				panic("mock out the RevokeToken method")
+//			},
+//			RevokeSubjectTokensFunc: func(ctx context.Context, subject string, issuedBefore time.Time) (entity.TokenRevocation, error) {
+//				panic("mock out the RevokeSubjectTokens method")
+//			},
 //		}
 //
 //		// use mockedCouponService in code that requires CouponService
@@ -36,51 +66,158 @@ var _ CouponService = &CouponServiceMock{}
 //	}
 type CouponServiceMock struct {
 	// ApplyCouponFunc mocks the ApplyCoupon method.
-	ApplyCouponFunc func(code string, value int) (entity.Basket, error)
+	ApplyCouponFunc func(ctx context.Context, code string, basket entity.Basket) (entity.Basket, error)
 
 	// CreateCouponFunc mocks the CreateCoupon method.
-	CreateCouponFunc func(code string, discount int, minBasketValue int) error
+	CreateCouponFunc func(ctx context.Context, coupon entity.Coupon) (entity.Coupon, error)
 
 	// GetCouponsFunc mocks the GetCoupons method.
-	GetCouponsFunc func(strings []string) ([]entity.Coupon, error)
+	GetCouponsFunc func(ctx context.Context, codes []string, filter entity.CouponFilter) ([]entity.Coupon, error)
+
+	// IssueSignedCodesFunc mocks the IssueSignedCodes method.
+	IssueSignedCodesFunc func(ctx context.Context, claims signedcode.Claims, count int) ([]string, error)
+
+	// QueryAuditFunc mocks the QueryAudit method.
+	QueryAuditFunc func(ctx context.Context, auditFilter entity.AuditFilter) ([]entity.AuditEvent, error)
+
+	// VerifyAuditFunc mocks the VerifyAudit method.
+	VerifyAuditFunc func(ctx context.Context) (entity.AuditVerification, error)
+
+	// CreateAPIKeyFunc mocks the CreateAPIKey method.
+	CreateAPIKeyFunc func(ctx context.Context, aPIKey entity.APIKey) (entity.APIKey, string, error)
+
+	// ListAPIKeysFunc mocks the ListAPIKeys method.
+	ListAPIKeysFunc func(ctx context.Context) ([]entity.APIKey, error)
+
+	// RevokeAPIKeyFunc mocks the RevokeAPIKey method.
+	RevokeAPIKeyFunc func(ctx context.Context, id string) error
+
+	// AuthenticateAPIKeyFunc mocks the AuthenticateAPIKey method.
+	AuthenticateAPIKeyFunc func(ctx context.Context, key string) (entity.APIKey, error)
+
+	// RevokeTokenFunc mocks the RevokeToken method.
+	RevokeTokenFunc func(ctx context.Context, jti string, expiresAt time.Time) (entity.TokenRevocation, error)
+
+	// RevokeSubjectTokensFunc mocks the RevokeSubjectTokens method.
+	RevokeSubjectTokensFunc func(ctx context.Context, subject string, issuedBefore time.Time) (entity.TokenRevocation, error)
 
 	// calls tracks calls to the methods.
 	calls struct {
 		// ApplyCoupon holds details about calls to the ApplyCoupon method.
 		ApplyCoupon []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
 			// Code is the code argument value.
 			Code string
-			// Value is the value argument value.
-			Value int
+			// Basket is the basket argument value.
+			Basket entity.Basket
 		}
 		// CreateCoupon holds details about calls to the CreateCoupon method.
 		CreateCoupon []struct {
-			// Code is the code argument value.
-			Code string
-			// Discount is the discount argument value.
-			Discount int
-			// MinBasketValue is the minBasketValue argument value.
-			MinBasketValue int
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Coupon is the coupon argument value.
+			Coupon entity.Coupon
 		}
 		// GetCoupons holds details about calls to the GetCoupons method.
 		GetCoupons []struct {
-			// Strings is the strings argument value.
-			Strings []string
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Codes is the codes argument value.
+			Codes []string
+			// Filter is the filter argument value.
+			Filter entity.CouponFilter
+		}
+		// IssueSignedCodes holds details about calls to the IssueSignedCodes method.
+		IssueSignedCodes []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Claims is the claims argument value.
+			Claims signedcode.Claims
+			// Count is the count argument value.
+			Count int
+		}
+		// QueryAudit holds details about calls to the QueryAudit method.
+		QueryAudit []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// AuditFilter is the auditFilter argument value.
+			AuditFilter entity.AuditFilter
+		}
+		// VerifyAudit holds details about calls to the VerifyAudit method.
+		VerifyAudit []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// CreateAPIKey holds details about calls to the CreateAPIKey method.
+		CreateAPIKey []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// APIKey is the aPIKey argument value.
+			APIKey entity.APIKey
+		}
+		// ListAPIKeys holds details about calls to the ListAPIKeys method.
+		ListAPIKeys []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+		}
+		// RevokeAPIKey holds details about calls to the RevokeAPIKey method.
+		RevokeAPIKey []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Id is the id argument value.
+			Id string
+		}
+		// AuthenticateAPIKey holds details about calls to the AuthenticateAPIKey method.
+		AuthenticateAPIKey []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Key is the key argument value.
+			Key string
+		}
+		// RevokeToken holds details about calls to the RevokeToken method.
+		RevokeToken []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Jti is the jti argument value.
+			Jti string
+			// ExpiresAt is the expiresAt argument value.
+			ExpiresAt time.Time
+		}
+		// RevokeSubjectTokens holds details about calls to the RevokeSubjectTokens method.
+		RevokeSubjectTokens []struct {
+			// Ctx is the ctx argument value.
+			Ctx context.Context
+			// Subject is the subject argument value.
+			Subject string
+			// IssuedBefore is the issuedBefore argument value.
+			IssuedBefore time.Time
 		}
 	}
-	lockApplyCoupon  sync.RWMutex
-	lockCreateCoupon sync.RWMutex
-	lockGetCoupons   sync.RWMutex
+	lockApplyCoupon         sync.RWMutex
+	lockCreateCoupon        sync.RWMutex
+	lockGetCoupons          sync.RWMutex
+	lockIssueSignedCodes    sync.RWMutex
+	lockQueryAudit          sync.RWMutex
+	lockVerifyAudit         sync.RWMutex
+	lockCreateAPIKey        sync.RWMutex
+	lockListAPIKeys         sync.RWMutex
+	lockRevokeAPIKey        sync.RWMutex
+	lockAuthenticateAPIKey  sync.RWMutex
+	lockRevokeToken         sync.RWMutex
+	lockRevokeSubjectTokens sync.RWMutex
 }
 
 // ApplyCoupon calls ApplyCouponFunc.
-func (mock *CouponServiceMock) ApplyCoupon(code string, value int) (entity.Basket, error) {
+func (mock *CouponServiceMock) ApplyCoupon(ctx context.Context, code string, basket entity.Basket) (entity.Basket, error) {
 	callInfo := struct {
-		Code  string
-		Value int
+		Ctx    context.Context
+		Code   string
+		Basket entity.Basket
 	}{
-		Code:  code,
-		Value: value,
+		Ctx:    ctx,
+		Code:   code,
+		Basket: basket,
 	}
 	mock.lockApplyCoupon.Lock()
 	mock.calls.ApplyCoupon = append(mock.calls.ApplyCoupon, callInfo)
@@ -92,7 +229,7 @@ func (mock *CouponServiceMock) ApplyCoupon(code string, value int) (entity.Baske
 		)
 		return basketOut, errOut
 	}
-	return mock.ApplyCouponFunc(code, value)
+	return mock.ApplyCouponFunc(ctx, code, basket)
 }
 
 // ApplyCouponCalls gets all the calls that were made to ApplyCoupon.
@@ -100,12 +237,14 @@ func (mock *CouponServiceMock) ApplyCoupon(code string, value int) (entity.Baske
 //
 //	len(mockedCouponService.ApplyCouponCalls())
 func (mock *CouponServiceMock) ApplyCouponCalls() []struct {
-	Code  string
-	Value int
+	Ctx    context.Context
+	Code   string
+	Basket entity.Basket
 } {
 	var calls []struct {
-		Code  string
-		Value int
+		Ctx    context.Context
+		Code   string
+		Basket entity.Basket
 	}
 	mock.lockApplyCoupon.RLock()
 	calls = mock.calls.ApplyCoupon
@@ -114,26 +253,25 @@ func (mock *CouponServiceMock) ApplyCouponCalls() []struct {
 }
 
 // CreateCoupon calls CreateCouponFunc.
-func (mock *CouponServiceMock) CreateCoupon(code string, discount int, minBasketValue int) error {
+func (mock *CouponServiceMock) CreateCoupon(ctx context.Context, coupon entity.Coupon) (entity.Coupon, error) {
 	callInfo := struct {
-		Code           string
-		Discount       int
-		MinBasketValue int
+		Ctx    context.Context
+		Coupon entity.Coupon
 	}{
-		Code:           code,
-		Discount:       discount,
-		MinBasketValue: minBasketValue,
+		Ctx:    ctx,
+		Coupon: coupon,
 	}
 	mock.lockCreateCoupon.Lock()
 	mock.calls.CreateCoupon = append(mock.calls.CreateCoupon, callInfo)
 	mock.lockCreateCoupon.Unlock()
 	if mock.CreateCouponFunc == nil {
 		var (
-			errOut error
+			couponOut entity.Coupon
+			errOut    error
 		)
-		return errOut
+		return couponOut, errOut
 	}
-	return mock.CreateCouponFunc(code, discount, minBasketValue)
+	return mock.CreateCouponFunc(ctx, coupon)
 }
 
 // CreateCouponCalls gets all the calls that were made to CreateCoupon.
@@ -141,14 +279,12 @@ func (mock *CouponServiceMock) CreateCoupon(code string, discount int, minBasket
 //
 //	len(mockedCouponService.CreateCouponCalls())
 func (mock *CouponServiceMock) CreateCouponCalls() []struct {
-	Code           string
-	Discount       int
-	MinBasketValue int
+	Ctx    context.Context
+	Coupon entity.Coupon
 } {
 	var calls []struct {
-		Code           string
-		Discount       int
-		MinBasketValue int
+		Ctx    context.Context
+		Coupon entity.Coupon
 	}
 	mock.lockCreateCoupon.RLock()
 	calls = mock.calls.CreateCoupon
@@ -157,11 +293,15 @@ func (mock *CouponServiceMock) CreateCouponCalls() []struct {
 }
 
 // GetCoupons calls GetCouponsFunc.
-func (mock *CouponServiceMock) GetCoupons(strings []string) ([]entity.Coupon, error) {
+func (mock *CouponServiceMock) GetCoupons(ctx context.Context, codes []string, filter entity.CouponFilter) ([]entity.Coupon, error) {
 	callInfo := struct {
-		Strings []string
+		Ctx    context.Context
+		Codes  []string
+		Filter entity.CouponFilter
 	}{
-		Strings: strings,
+		Ctx:    ctx,
+		Codes:  codes,
+		Filter: filter,
 	}
 	mock.lockGetCoupons.Lock()
 	mock.calls.GetCoupons = append(mock.calls.GetCoupons, callInfo)
@@ -173,7 +313,7 @@ func (mock *CouponServiceMock) GetCoupons(strings []string) ([]entity.Coupon, er
 		)
 		return couponsOut, errOut
 	}
-	return mock.GetCouponsFunc(strings)
+	return mock.GetCouponsFunc(ctx, codes, filter)
 }
 
 // GetCouponsCalls gets all the calls that were made to GetCoupons.
@@ -181,13 +321,381 @@ func (mock *CouponServiceMock) GetCoupons(strings []string) ([]entity.Coupon, er
 //
 //	len(mockedCouponService.GetCouponsCalls())
 func (mock *CouponServiceMock) GetCouponsCalls() []struct {
-	Strings []string
+	Ctx    context.Context
+	Codes  []string
+	Filter entity.CouponFilter
 } {
 	var calls []struct {
-		Strings []string
+		Ctx    context.Context
+		Codes  []string
+		Filter entity.CouponFilter
 	}
 	mock.lockGetCoupons.RLock()
 	calls = mock.calls.GetCoupons
 	mock.lockGetCoupons.RUnlock()
 	return calls
 }
+
+// IssueSignedCodes calls IssueSignedCodesFunc.
+func (mock *CouponServiceMock) IssueSignedCodes(ctx context.Context, claims signedcode.Claims, count int) ([]string, error) {
+	callInfo := struct {
+		Ctx    context.Context
+		Claims signedcode.Claims
+		Count  int
+	}{
+		Ctx:    ctx,
+		Claims: claims,
+		Count:  count,
+	}
+	mock.lockIssueSignedCodes.Lock()
+	mock.calls.IssueSignedCodes = append(mock.calls.IssueSignedCodes, callInfo)
+	mock.lockIssueSignedCodes.Unlock()
+	if mock.IssueSignedCodesFunc == nil {
+		var (
+			stringsOut []string
+			errOut     error
+		)
+		return stringsOut, errOut
+	}
+	return mock.IssueSignedCodesFunc(ctx, claims, count)
+}
+
+// IssueSignedCodesCalls gets all the calls that were made to IssueSignedCodes.
+// Check the length with:
+//
+//	len(mockedCouponService.IssueSignedCodesCalls())
+func (mock *CouponServiceMock) IssueSignedCodesCalls() []struct {
+	Ctx    context.Context
+	Claims signedcode.Claims
+	Count  int
+} {
+	var calls []struct {
+		Ctx    context.Context
+		Claims signedcode.Claims
+		Count  int
+	}
+	mock.lockIssueSignedCodes.RLock()
+	calls = mock.calls.IssueSignedCodes
+	mock.lockIssueSignedCodes.RUnlock()
+	return calls
+}
+
+// QueryAudit calls QueryAuditFunc.
+func (mock *CouponServiceMock) QueryAudit(ctx context.Context, auditFilter entity.AuditFilter) ([]entity.AuditEvent, error) {
+	callInfo := struct {
+		Ctx         context.Context
+		AuditFilter entity.AuditFilter
+	}{
+		Ctx:         ctx,
+		AuditFilter: auditFilter,
+	}
+	mock.lockQueryAudit.Lock()
+	mock.calls.QueryAudit = append(mock.calls.QueryAudit, callInfo)
+	mock.lockQueryAudit.Unlock()
+	if mock.QueryAuditFunc == nil {
+		var (
+			auditEventsOut []entity.AuditEvent
+			errOut         error
+		)
+		return auditEventsOut, errOut
+	}
+	return mock.QueryAuditFunc(ctx, auditFilter)
+}
+
+// QueryAuditCalls gets all the calls that were made to QueryAudit.
+// Check the length with:
+//
+//	len(mockedCouponService.QueryAuditCalls())
+func (mock *CouponServiceMock) QueryAuditCalls() []struct {
+	Ctx         context.Context
+	AuditFilter entity.AuditFilter
+} {
+	var calls []struct {
+		Ctx         context.Context
+		AuditFilter entity.AuditFilter
+	}
+	mock.lockQueryAudit.RLock()
+	calls = mock.calls.QueryAudit
+	mock.lockQueryAudit.RUnlock()
+	return calls
+}
+
+// VerifyAudit calls VerifyAuditFunc.
+func (mock *CouponServiceMock) VerifyAudit(ctx context.Context) (entity.AuditVerification, error) {
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockVerifyAudit.Lock()
+	mock.calls.VerifyAudit = append(mock.calls.VerifyAudit, callInfo)
+	mock.lockVerifyAudit.Unlock()
+	if mock.VerifyAuditFunc == nil {
+		var (
+			auditVerificationOut entity.AuditVerification
+			errOut               error
+		)
+		return auditVerificationOut, errOut
+	}
+	return mock.VerifyAuditFunc(ctx)
+}
+
+// VerifyAuditCalls gets all the calls that were made to VerifyAudit.
+// Check the length with:
+//
+//	len(mockedCouponService.VerifyAuditCalls())
+func (mock *CouponServiceMock) VerifyAuditCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockVerifyAudit.RLock()
+	calls = mock.calls.VerifyAudit
+	mock.lockVerifyAudit.RUnlock()
+	return calls
+}
+
+// CreateAPIKey calls CreateAPIKeyFunc.
+func (mock *CouponServiceMock) CreateAPIKey(ctx context.Context, aPIKey entity.APIKey) (entity.APIKey, string, error) {
+	callInfo := struct {
+		Ctx    context.Context
+		APIKey entity.APIKey
+	}{
+		Ctx:    ctx,
+		APIKey: aPIKey,
+	}
+	mock.lockCreateAPIKey.Lock()
+	mock.calls.CreateAPIKey = append(mock.calls.CreateAPIKey, callInfo)
+	mock.lockCreateAPIKey.Unlock()
+	if mock.CreateAPIKeyFunc == nil {
+		var (
+			aPIKeyOut entity.APIKey
+			sOut      string
+			errOut    error
+		)
+		return aPIKeyOut, sOut, errOut
+	}
+	return mock.CreateAPIKeyFunc(ctx, aPIKey)
+}
+
+// CreateAPIKeyCalls gets all the calls that were made to CreateAPIKey.
+// Check the length with:
+//
+//	len(mockedCouponService.CreateAPIKeyCalls())
+func (mock *CouponServiceMock) CreateAPIKeyCalls() []struct {
+	Ctx    context.Context
+	APIKey entity.APIKey
+} {
+	var calls []struct {
+		Ctx    context.Context
+		APIKey entity.APIKey
+	}
+	mock.lockCreateAPIKey.RLock()
+	calls = mock.calls.CreateAPIKey
+	mock.lockCreateAPIKey.RUnlock()
+	return calls
+}
+
+// ListAPIKeys calls ListAPIKeysFunc.
+func (mock *CouponServiceMock) ListAPIKeys(ctx context.Context) ([]entity.APIKey, error) {
+	callInfo := struct {
+		Ctx context.Context
+	}{
+		Ctx: ctx,
+	}
+	mock.lockListAPIKeys.Lock()
+	mock.calls.ListAPIKeys = append(mock.calls.ListAPIKeys, callInfo)
+	mock.lockListAPIKeys.Unlock()
+	if mock.ListAPIKeysFunc == nil {
+		var (
+			aPIKeysOut []entity.APIKey
+			errOut     error
+		)
+		return aPIKeysOut, errOut
+	}
+	return mock.ListAPIKeysFunc(ctx)
+}
+
+// ListAPIKeysCalls gets all the calls that were made to ListAPIKeys.
+// Check the length with:
+//
+//	len(mockedCouponService.ListAPIKeysCalls())
+func (mock *CouponServiceMock) ListAPIKeysCalls() []struct {
+	Ctx context.Context
+} {
+	var calls []struct {
+		Ctx context.Context
+	}
+	mock.lockListAPIKeys.RLock()
+	calls = mock.calls.ListAPIKeys
+	mock.lockListAPIKeys.RUnlock()
+	return calls
+}
+
+// RevokeAPIKey calls RevokeAPIKeyFunc.
+func (mock *CouponServiceMock) RevokeAPIKey(ctx context.Context, id string) error {
+	callInfo := struct {
+		Ctx context.Context
+		Id  string
+	}{
+		Ctx: ctx,
+		Id:  id,
+	}
+	mock.lockRevokeAPIKey.Lock()
+	mock.calls.RevokeAPIKey = append(mock.calls.RevokeAPIKey, callInfo)
+	mock.lockRevokeAPIKey.Unlock()
+	if mock.RevokeAPIKeyFunc == nil {
+		var (
+			errOut error
+		)
+		return errOut
+	}
+	return mock.RevokeAPIKeyFunc(ctx, id)
+}
+
+// RevokeAPIKeyCalls gets all the calls that were made to RevokeAPIKey.
+// Check the length with:
+//
+//	len(mockedCouponService.RevokeAPIKeyCalls())
+func (mock *CouponServiceMock) RevokeAPIKeyCalls() []struct {
+	Ctx context.Context
+	Id  string
+} {
+	var calls []struct {
+		Ctx context.Context
+		Id  string
+	}
+	mock.lockRevokeAPIKey.RLock()
+	calls = mock.calls.RevokeAPIKey
+	mock.lockRevokeAPIKey.RUnlock()
+	return calls
+}
+
+// AuthenticateAPIKey calls AuthenticateAPIKeyFunc.
+func (mock *CouponServiceMock) AuthenticateAPIKey(ctx context.Context, key string) (entity.APIKey, error) {
+	callInfo := struct {
+		Ctx context.Context
+		Key string
+	}{
+		Ctx: ctx,
+		Key: key,
+	}
+	mock.lockAuthenticateAPIKey.Lock()
+	mock.calls.AuthenticateAPIKey = append(mock.calls.AuthenticateAPIKey, callInfo)
+	mock.lockAuthenticateAPIKey.Unlock()
+	if mock.AuthenticateAPIKeyFunc == nil {
+		var (
+			aPIKeyOut entity.APIKey
+			errOut    error
+		)
+		return aPIKeyOut, errOut
+	}
+	return mock.AuthenticateAPIKeyFunc(ctx, key)
+}
+
+// AuthenticateAPIKeyCalls gets all the calls that were made to AuthenticateAPIKey.
+// Check the length with:
+//
+//	len(mockedCouponService.AuthenticateAPIKeyCalls())
+func (mock *CouponServiceMock) AuthenticateAPIKeyCalls() []struct {
+	Ctx context.Context
+	Key string
+} {
+	var calls []struct {
+		Ctx context.Context
+		Key string
+	}
+	mock.lockAuthenticateAPIKey.RLock()
+	calls = mock.calls.AuthenticateAPIKey
+	mock.lockAuthenticateAPIKey.RUnlock()
+	return calls
+}
+
+// RevokeToken calls RevokeTokenFunc.
+func (mock *CouponServiceMock) RevokeToken(ctx context.Context, jti string, expiresAt time.Time) (entity.TokenRevocation, error) {
+	callInfo := struct {
+		Ctx       context.Context
+		Jti       string
+		ExpiresAt time.Time
+	}{
+		Ctx:       ctx,
+		Jti:       jti,
+		ExpiresAt: expiresAt,
+	}
+	mock.lockRevokeToken.Lock()
+	mock.calls.RevokeToken = append(mock.calls.RevokeToken, callInfo)
+	mock.lockRevokeToken.Unlock()
+	if mock.RevokeTokenFunc == nil {
+		var (
+			tokenRevocationOut entity.TokenRevocation
+			errOut             error
+		)
+		return tokenRevocationOut, errOut
+	}
+	return mock.RevokeTokenFunc(ctx, jti, expiresAt)
+}
+
+// RevokeTokenCalls gets all the calls that were made to RevokeToken.
+// Check the length with:
+//
+//	len(mockedCouponService.RevokeTokenCalls())
+func (mock *CouponServiceMock) RevokeTokenCalls() []struct {
+	Ctx       context.Context
+	Jti       string
+	ExpiresAt time.Time
+} {
+	var calls []struct {
+		Ctx       context.Context
+		Jti       string
+		ExpiresAt time.Time
+	}
+	mock.lockRevokeToken.RLock()
+	calls = mock.calls.RevokeToken
+	mock.lockRevokeToken.RUnlock()
+	return calls
+}
+
+// RevokeSubjectTokens calls RevokeSubjectTokensFunc.
+func (mock *CouponServiceMock) RevokeSubjectTokens(ctx context.Context, subject string, issuedBefore time.Time) (entity.TokenRevocation, error) {
+	callInfo := struct {
+		Ctx          context.Context
+		Subject      string
+		IssuedBefore time.Time
+	}{
+		Ctx:          ctx,
+		Subject:      subject,
+		IssuedBefore: issuedBefore,
+	}
+	mock.lockRevokeSubjectTokens.Lock()
+	mock.calls.RevokeSubjectTokens = append(mock.calls.RevokeSubjectTokens, callInfo)
+	mock.lockRevokeSubjectTokens.Unlock()
+	if mock.RevokeSubjectTokensFunc == nil {
+		var (
+			tokenRevocationOut entity.TokenRevocation
+			errOut             error
+		)
+		return tokenRevocationOut, errOut
+	}
+	return mock.RevokeSubjectTokensFunc(ctx, subject, issuedBefore)
+}
+
+// RevokeSubjectTokensCalls gets all the calls that were made to RevokeSubjectTokens.
+// Check the length with:
+//
+//	len(mockedCouponService.RevokeSubjectTokensCalls())
+func (mock *CouponServiceMock) RevokeSubjectTokensCalls() []struct {
+	Ctx          context.Context
+	Subject      string
+	IssuedBefore time.Time
+} {
+	var calls []struct {
+		Ctx          context.Context
+		Subject      string
+		IssuedBefore time.Time
+	}
+	mock.lockRevokeSubjectTokens.RLock()
+	calls = mock.calls.RevokeSubjectTokens
+	mock.lockRevokeSubjectTokens.RUnlock()
+	return calls
+}
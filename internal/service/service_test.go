@@ -1,72 +1,190 @@
 package service
 
 import (
+	"context"
 	"errors"
+	"math"
+	"strings"
 	"testing"
+	"time"
 
 	"coupon_service/internal/entity"
+	"coupon_service/internal/identity"
 	"coupon_service/internal/repository"
+	"coupon_service/internal/repository/memdb"
+	"coupon_service/internal/revocation"
+	"coupon_service/internal/signedcode"
 	"coupon_service/pkg"
 
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
+// auditKey keys the audit chains of the tests.
+var auditKey = []byte("audit-key-of-at-least-32-bytes!!")
+
 func TestService_ApplyCoupon(t *testing.T) {
 	tests := []struct {
 		name         string
 		code         string
-		value        int
+		basket       entity.Basket
 		findCoupon   entity.Coupon
 		findErr      error
 		expectedErr  error
 		expectedResp entity.Basket
 	}{
 		{
-			name:  "success",
-			code:  "ABC123",
-			value: 200,
+			name:   "success",
+			code:   "ABC123",
+			basket: entity.Basket{Value: entity.NewMoney(200, "EUR")},
 			findCoupon: entity.Coupon{
-				Code:           "ABC123",
-				Discount:       20,
-				MinBasketValue: 100,
+				Code:  "ABC123",
+				Terms: []entity.Terms{{Discount: entity.NewMoney(20, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
 			},
 			expectedResp: entity.Basket{
-				Value:                 200,
-				AppliedDiscount:       20,
+				Value:                 entity.NewMoney(200, "EUR"),
+				AppliedDiscount:       entity.NewMoney(20, "EUR"),
+				ApplicationSuccessful: true,
+			},
+		},
+		{
+			name:   "code normalized before lookup",
+			code:   "abc-123",
+			basket: entity.Basket{Value: entity.NewMoney(200, "EUR")},
+			findCoupon: entity.Coupon{
+				Code:  "ABC123",
+				Terms: []entity.Terms{{Discount: entity.NewMoney(20, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
+			},
+			expectedResp: entity.Basket{
+				Value:                 entity.NewMoney(200, "EUR"),
+				AppliedDiscount:       entity.NewMoney(20, "EUR"),
 				ApplicationSuccessful: true,
 			},
 		},
 		{
 			name:        "coupon not found",
 			code:        "ABC123",
-			value:       100,
+			basket:      entity.Basket{Value: entity.NewMoney(100, "EUR")},
 			findErr:     pkg.Errorf(pkg.ENOTFOUND, "coupon not found", nil),
 			expectedErr: pkg.Errorf(pkg.ENOTFOUND, "coupon not found", nil),
 		},
 		{
-			name:  "basket value below coupon minimum value",
-			code:  "ABC123",
-			value: 50,
+			name:   "basket value below coupon minimum value",
+			code:   "ABC123",
+			basket: entity.Basket{Value: entity.NewMoney(50, "EUR")},
+			findCoupon: entity.Coupon{
+				Code:  "ABC123",
+				Terms: []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
+			},
+			expectedErr: pkg.Errorf(pkg.EINVALID, "basket value below minimum required for coupon", nil),
+		},
+		{
+			name:   "terms selected by basket currency",
+			code:   "ABC123",
+			basket: entity.Basket{Value: entity.NewMoney(50000, "PLN")},
+			findCoupon: entity.Coupon{
+				Code: "ABC123",
+				Terms: []entity.Terms{
+					{Discount: entity.NewMoney(500, "EUR"), MinBasketValue: entity.NewMoney(5000, "EUR")},
+					{Discount: entity.NewMoney(2000, "PLN"), MinBasketValue: entity.NewMoney(20000, "PLN")},
+				},
+			},
+			expectedResp: entity.Basket{
+				Value:                 entity.NewMoney(50000, "PLN"),
+				AppliedDiscount:       entity.NewMoney(2000, "PLN"),
+				ApplicationSuccessful: true,
+			},
+		},
+		{
+			name:   "highest tier reached by the basket",
+			code:   "ABC123",
+			basket: entity.Basket{Value: entity.NewMoney(15000, "EUR")},
+			findCoupon: entity.Coupon{
+				Code:   "ABC123",
+				Effect: entity.EffectTieredDiscount,
+				Terms: []entity.Terms{{
+					MinBasketValue: entity.NewMoney(5000, "EUR"),
+					Discount:       entity.Money{Currency: "EUR"},
+					Tiers: []entity.Tier{
+						{MinBasketValue: entity.NewMoney(5000, "EUR"), Discount: entity.NewMoney(500, "EUR")},
+						{MinBasketValue: entity.NewMoney(10000, "EUR"), Discount: entity.NewMoney(1500, "EUR")},
+						{MinBasketValue: entity.NewMoney(20000, "EUR"), Discount: entity.NewMoney(4000, "EUR")},
+					},
+				}},
+			},
+			expectedResp: entity.Basket{
+				Value:                 entity.NewMoney(15000, "EUR"),
+				AppliedDiscount:       entity.NewMoney(1500, "EUR"),
+				ApplicationSuccessful: true,
+			},
+		},
+		{
+			name:   "basket exactly on the highest tier",
+			code:   "ABC123",
+			basket: entity.Basket{Value: entity.NewMoney(20000, "EUR")},
 			findCoupon: entity.Coupon{
-				Code:           "ABC123",
-				Discount:       10,
-				MinBasketValue: 100,
+				Code:   "ABC123",
+				Effect: entity.EffectTieredDiscount,
+				Terms: []entity.Terms{{
+					MinBasketValue: entity.NewMoney(5000, "EUR"),
+					Discount:       entity.Money{Currency: "EUR"},
+					Tiers: []entity.Tier{
+						{MinBasketValue: entity.NewMoney(5000, "EUR"), Discount: entity.NewMoney(500, "EUR")},
+						{MinBasketValue: entity.NewMoney(10000, "EUR"), Discount: entity.NewMoney(1500, "EUR")},
+						{MinBasketValue: entity.NewMoney(20000, "EUR"), Discount: entity.NewMoney(4000, "EUR")},
+					},
+				}},
+			},
+			expectedResp: entity.Basket{
+				Value:                 entity.NewMoney(20000, "EUR"),
+				AppliedDiscount:       entity.NewMoney(4000, "EUR"),
+				ApplicationSuccessful: true,
+			},
+		},
+		{
+			name:   "basket below the lowest tier",
+			code:   "ABC123",
+			basket: entity.Basket{Value: entity.NewMoney(4999, "EUR")},
+			findCoupon: entity.Coupon{
+				Code:   "ABC123",
+				Effect: entity.EffectTieredDiscount,
+				Terms: []entity.Terms{{
+					MinBasketValue: entity.NewMoney(5000, "EUR"),
+					Discount:       entity.Money{Currency: "EUR"},
+					Tiers: []entity.Tier{
+						{MinBasketValue: entity.NewMoney(5000, "EUR"), Discount: entity.NewMoney(500, "EUR")},
+						{MinBasketValue: entity.NewMoney(10000, "EUR"), Discount: entity.NewMoney(1500, "EUR")},
+						{MinBasketValue: entity.NewMoney(20000, "EUR"), Discount: entity.NewMoney(4000, "EUR")},
+					},
+				}},
 			},
 			expectedErr: pkg.Errorf(pkg.EINVALID, "basket value below minimum required for coupon", nil),
 		},
+		{
+			name:   "basket currency not supported by coupon",
+			code:   "ABC123",
+			basket: entity.Basket{Value: entity.NewMoney(200, "PLN")},
+			findCoupon: entity.Coupon{
+				Code: "ABC123",
+				Terms: []entity.Terms{
+					{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")},
+					{Discount: entity.NewMoney(250, "CZK"), MinBasketValue: entity.NewMoney(2500, "CZK")},
+				},
+			},
+			expectedErr: pkg.Errorf(pkg.EINVALID, "coupon does not support basket currency PLN, supported currencies: EUR, CZK", nil),
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			repoMock := &repository.CouponRepositoryMock{
-				FindByCodeFunc: func(code string) (entity.Coupon, error) {
-					assert.Equal(t, tt.code, code)
+				FindByCodeFunc: func(_, code string) (entity.Coupon, error) {
+					assert.Equal(t, pkg.NormalizeCode(tt.code), code)
 					return tt.findCoupon, tt.findErr
 				},
 			}
 			svc := New(repoMock)
-			basket, err := svc.ApplyCoupon(tt.code, tt.value)
+			basket, err := svc.ApplyCoupon(context.Background(), tt.code, tt.basket)
 			if tt.expectedErr != nil {
 				assert.Error(t, err)
 				assert.Equal(t, tt.expectedErr.Error(), err.Error())
@@ -78,133 +196,706 @@ func TestService_ApplyCoupon(t *testing.T) {
 	}
 }
 
+func TestService_ApplyCoupon_PersonalCoupons(t *testing.T) {
+	personal := entity.Coupon{
+		Code:  "ALICE10",
+		Owner: "alice",
+		Terms: []entity.Terms{{Discount: entity.NewMoney(20, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
+	}
+	notFound := pkg.Errorf(pkg.ENOTFOUND, "coupon not found", nil)
+
+	tests := []struct {
+		name          string
+		userID        string
+		code          string
+		findErr       error
+		suggestions   []string
+		expectSuggest bool
+		expectedErr   error
+	}{
+		{name: "owner applies personal coupon", userID: "alice", code: "ALICE10"},
+		{
+			name:          "other user applies personal coupon",
+			userID:        "bob",
+			code:          "ALICE10",
+			expectSuggest: true,
+			expectedErr:   notFound,
+		},
+		{
+			name:          "unknown code with suggestions",
+			userID:        "alice",
+			code:          "ALICE1O",
+			findErr:       notFound,
+			suggestions:   []string{"ALICE10"},
+			expectSuggest: true,
+			expectedErr:   pkg.Errorf(pkg.ENOTFOUND, "coupon not found", nil).WithDetail("suggestions", []string{"ALICE10"}),
+		},
+		{
+			name:          "unknown code without suggestions",
+			userID:        "alice",
+			code:          "ZZZZZZ",
+			findErr:       notFound,
+			expectSuggest: true,
+			expectedErr:   notFound,
+		},
+		{
+			name:        "anonymous caller gets no suggestions",
+			code:        "ALICE1O",
+			findErr:     notFound,
+			expectedErr: notFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suggested := false
+			repoMock := &repository.CouponRepositoryMock{
+				FindByCodeFunc: func(_, code string) (entity.Coupon, error) {
+					if tt.findErr != nil {
+						return entity.Coupon{}, tt.findErr
+					}
+					return personal, nil
+				},
+				SuggestCodesFunc: func(_, code, owner string, limit int) ([]string, error) {
+					suggested = true
+					assert.Equal(t, tt.code, code)
+					assert.Equal(t, tt.userID, owner)
+					assert.Equal(t, maxSuggestions, limit)
+					return tt.suggestions, nil
+				},
+			}
+			svc := New(repoMock)
+			ctx := context.Background()
+			if tt.userID != "" {
+				ctx = identity.NewContext(ctx, identity.Principal{UserID: tt.userID})
+			}
+
+			_, err := svc.ApplyCoupon(ctx, tt.code, entity.Basket{Value: entity.NewMoney(200, "EUR")})
+			if tt.expectedErr != nil {
+				assert.Error(t, err)
+				assert.Equal(t, tt.expectedErr.Error(), err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectSuggest, suggested)
+		})
+	}
+}
+
+func TestService_ApplyCoupon_CheckCharacter(t *testing.T) {
+	check, _ := pkg.CheckCharacter("K7PQ2MXZ9")
+	valid := "K7PQ2MXZ9" + string(check)
+	mistyped := "K7PQ2MXZ8" + string(check)
+
+	tests := []struct {
+		name        string
+		code        string
+		expectFind  bool
+		expectedErr error
+	}{
+		{name: "valid check character", code: valid, expectFind: true},
+		{name: "valid check character, lower case", code: strings.ToLower(valid), expectFind: true},
+		{
+			name:        "mistyped code",
+			code:        mistyped,
+			expectedErr: pkg.Errorf(pkg.EMISTYPED, "coupon code appears to be mistyped, please check it", nil),
+		},
+		{name: "code not shaped like a generated code", code: "SAVE10", expectFind: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found := false
+			repoMock := &repository.CouponRepositoryMock{
+				FindByCodeFunc: func(_, code string) (entity.Coupon, error) {
+					found = true
+					return entity.Coupon{
+						Code:  code,
+						Terms: []entity.Terms{{Discount: entity.NewMoney(20, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
+					}, nil
+				},
+			}
+			policy := pkg.DefaultCodePolicy()
+			policy.CheckCharacter = true
+			svc := New(repoMock, WithCodePolicy(policy))
+
+			_, err := svc.ApplyCoupon(context.Background(), tt.code, entity.Basket{Value: entity.NewMoney(200, "EUR")})
+			if tt.expectedErr != nil {
+				assert.Error(t, err)
+				assert.Equal(t, tt.expectedErr.Error(), err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.expectFind, found)
+		})
+	}
+}
+
+func TestService_ApplyCoupon_Effects(t *testing.T) {
+	eur := func(amount int64) entity.Money { return entity.NewMoney(amount, "EUR") }
+	terms := []entity.Terms{{Discount: eur(0), MinBasketValue: eur(1000)}}
+
+	tests := []struct {
+		name         string
+		coupon       entity.Coupon
+		rounding     entity.RoundingMode
+		lines        []entity.BasketLine
+		value        entity.Money // the total of the lines when zero
+		expectedErr  error
+		expectedDisc entity.Money
+		expectedLine []entity.BasketLine
+	}{
+		{
+			name:   "free shipping",
+			coupon: entity.Coupon{Effect: entity.EffectFreeShipping, Terms: terms},
+			lines: []entity.BasketLine{
+				{SKU: "A", Type: entity.LineProduct, Quantity: 2, UnitPrice: eur(1000)},
+				{SKU: "SHIP", Type: entity.LineShipping, Quantity: 1, UnitPrice: eur(499)},
+			},
+			expectedDisc: eur(499),
+			expectedLine: []entity.BasketLine{
+				{SKU: "A", Type: entity.LineProduct, Quantity: 2, UnitPrice: eur(1000), AppliedDiscount: eur(0)},
+				{SKU: "SHIP", Type: entity.LineShipping, Quantity: 1, UnitPrice: eur(499), AppliedDiscount: eur(499), Effect: entity.EffectFreeShipping},
+			},
+		},
+		{
+			name:   "free shipping without shipping line",
+			coupon: entity.Coupon{Effect: entity.EffectFreeShipping, Terms: terms},
+			lines: []entity.BasketLine{
+				{SKU: "A", Type: entity.LineProduct, Quantity: 2, UnitPrice: eur(1000)},
+			},
+			expectedErr: pkg.Errorf(pkg.EINVALID, "basket has no shipping line", nil),
+		},
+		{
+			name: "buy 2 get 1 free on qualifying SKUs",
+			coupon: entity.Coupon{
+				Effect:   entity.EffectBuyXGetY,
+				Terms:    terms,
+				BuyXGetY: &entity.BuyXGetY{SKUs: []string{"A"}, Buy: 2, Get: 1, DiscountPercent: 100},
+			},
+			lines: []entity.BasketLine{
+				{SKU: "B", Type: entity.LineProduct, Quantity: 1, UnitPrice: eur(500)},
+				{SKU: "A", Type: entity.LineProduct, Quantity: 3, UnitPrice: eur(1000)},
+			},
+			expectedDisc: eur(1000),
+			expectedLine: []entity.BasketLine{
+				{SKU: "B", Type: entity.LineProduct, Quantity: 1, UnitPrice: eur(500), AppliedDiscount: eur(0)},
+				{SKU: "A", Type: entity.LineProduct, Quantity: 3, UnitPrice: eur(1000), AppliedDiscount: eur(1000), Effect: entity.EffectBuyXGetY},
+			},
+		},
+		{
+			name: "buy 1 get 1 at half price rounds with banker's rounding",
+			coupon: entity.Coupon{
+				Effect:   entity.EffectBuyXGetY,
+				Terms:    terms,
+				BuyXGetY: &entity.BuyXGetY{Buy: 1, Get: 1, DiscountPercent: 50},
+			},
+			rounding: entity.RoundHalfEven,
+			lines: []entity.BasketLine{
+				{SKU: "A", Type: entity.LineProduct, Quantity: 1, UnitPrice: eur(1999)},
+				{SKU: "B", Type: entity.LineProduct, Quantity: 1, UnitPrice: eur(1001)},
+			},
+			expectedDisc: eur(500),
+			expectedLine: []entity.BasketLine{
+				{SKU: "A", Type: entity.LineProduct, Quantity: 1, UnitPrice: eur(1999), AppliedDiscount: eur(0)},
+				{SKU: "B", Type: entity.LineProduct, Quantity: 1, UnitPrice: eur(1001), AppliedDiscount: eur(500), Effect: entity.EffectBuyXGetY},
+			},
+		},
+		{
+			name: "buy 1 get 1 at half price rounds half up",
+			coupon: entity.Coupon{
+				Effect:   entity.EffectBuyXGetY,
+				Terms:    terms,
+				BuyXGetY: &entity.BuyXGetY{Buy: 1, Get: 1, DiscountPercent: 50},
+			},
+			rounding: entity.RoundHalfUp,
+			lines: []entity.BasketLine{
+				{SKU: "A", Type: entity.LineProduct, Quantity: 1, UnitPrice: eur(1999)},
+				{SKU: "B", Type: entity.LineProduct, Quantity: 1, UnitPrice: eur(1001)},
+			},
+			expectedDisc: eur(501),
+			expectedLine: []entity.BasketLine{
+				{SKU: "A", Type: entity.LineProduct, Quantity: 1, UnitPrice: eur(1999), AppliedDiscount: eur(0)},
+				{SKU: "B", Type: entity.LineProduct, Quantity: 1, UnitPrice: eur(1001), AppliedDiscount: eur(501), Effect: entity.EffectBuyXGetY},
+			},
+		},
+		{
+			name: "not enough qualifying products",
+			coupon: entity.Coupon{
+				Effect:   entity.EffectBuyXGetY,
+				Terms:    terms,
+				BuyXGetY: &entity.BuyXGetY{SKUs: []string{"A"}, Buy: 2, Get: 1, DiscountPercent: 100},
+			},
+			lines: []entity.BasketLine{
+				{SKU: "A", Type: entity.LineProduct, Quantity: 2, UnitPrice: eur(1000)},
+				{SKU: "B", Type: entity.LineProduct, Quantity: 1, UnitPrice: eur(1000)},
+			},
+			expectedErr: pkg.Errorf(pkg.EINVALID, "basket does not contain enough qualifying products for coupon", nil),
+		},
+		{
+			name:   "cheapest item free",
+			coupon: entity.Coupon{Effect: entity.EffectCheapestItemFree, Terms: terms},
+			lines: []entity.BasketLine{
+				{SKU: "A", Type: entity.LineProduct, Quantity: 2, UnitPrice: eur(1500)},
+				{SKU: "B", Type: entity.LineProduct, Quantity: 3, UnitPrice: eur(700)},
+				{SKU: "SHIP", Type: entity.LineShipping, Quantity: 1, UnitPrice: eur(0)},
+			},
+			expectedDisc: eur(700),
+			expectedLine: []entity.BasketLine{
+				{SKU: "A", Type: entity.LineProduct, Quantity: 2, UnitPrice: eur(1500), AppliedDiscount: eur(0)},
+				{SKU: "B", Type: entity.LineProduct, Quantity: 3, UnitPrice: eur(700), AppliedDiscount: eur(700), Effect: entity.EffectCheapestItemFree},
+				{SKU: "SHIP", Type: entity.LineShipping, Quantity: 1, UnitPrice: eur(0), AppliedDiscount: eur(0)},
+			},
+		},
+		{
+			name:   "line currency differs from basket currency",
+			coupon: entity.Coupon{Effect: entity.EffectCheapestItemFree, Terms: terms},
+			lines: []entity.BasketLine{
+				{SKU: "A", Type: entity.LineProduct, Quantity: 2, UnitPrice: entity.NewMoney(1500, "PLN")},
+			},
+			expectedErr: pkg.Errorf(pkg.EINVALID, "basket line currency does not match basket currency", nil),
+		},
+		{
+			name:   "lines exceeding basket value",
+			coupon: entity.Coupon{Effect: entity.EffectCheapestItemFree, Terms: terms},
+			lines: []entity.BasketLine{
+				{SKU: "A", Type: entity.LineProduct, Quantity: 1, UnitPrice: eur(100000)},
+			},
+			value:       eur(5000),
+			expectedErr: pkg.Errorf(pkg.EINVALID, "basket lines do not add up to basket value", nil),
+		},
+		{
+			name:   "lines below basket value",
+			coupon: entity.Coupon{Effect: entity.EffectCheapestItemFree, Terms: terms},
+			lines: []entity.BasketLine{
+				{SKU: "A", Type: entity.LineProduct, Quantity: 1, UnitPrice: eur(1000)},
+			},
+			value:       eur(5000),
+			expectedErr: pkg.Errorf(pkg.EINVALID, "basket lines do not add up to basket value", nil),
+		},
+		{
+			name:   "line quantity overflowing its total",
+			coupon: entity.Coupon{Effect: entity.EffectCheapestItemFree, Terms: terms},
+			lines: []entity.BasketLine{
+				{SKU: "A", Type: entity.LineProduct, Quantity: 1 << 40, UnitPrice: eur(1 << 40)},
+			},
+			value:       eur(5000),
+			expectedErr: pkg.Errorf(pkg.EINVALID, "basket line quantity or unit price out of bounds", nil),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoMock := &repository.CouponRepositoryMock{
+				FindByCodeFunc: func(_, code string) (entity.Coupon, error) {
+					return tt.coupon, nil
+				},
+			}
+			value := tt.value
+			if value.IsZero() {
+				value = eur(0)
+				for _, l := range tt.lines {
+					value.Amount += l.Total().Amount
+				}
+			}
+			svc := New(repoMock, WithRounding(tt.rounding))
+			basket, err := svc.ApplyCoupon(context.Background(), "ABC123", entity.Basket{Value: value, Lines: tt.lines})
+			if tt.expectedErr != nil {
+				assert.Error(t, err)
+				assert.Equal(t, tt.expectedErr.Error(), err.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.True(t, basket.ApplicationSuccessful)
+				assert.Equal(t, tt.expectedDisc, basket.AppliedDiscount)
+				assert.Equal(t, tt.expectedLine, basket.Lines)
+			}
+		})
+	}
+}
+
 func TestService_CreateCoupon(t *testing.T) {
 	tests := []struct {
 		name           string
 		code           string
-		discount       int
-		minBasketValue int
+		normalizedCode string
+		policy         *pkg.CodePolicy
+		effect         entity.Effect
+		buyXGetY       *entity.BuyXGetY
+		terms          []entity.Terms
 		findErr        error
 		saveErr        error
 		expectedErr    error
 	}{
 		{
-			name:           "success",
-			code:           "ABC123",
-			discount:       10,
-			minBasketValue: 100,
-			findErr:        pkg.Errorf(pkg.ECONFLICT, "coupon already exists", nil),
+			name:    "success",
+			code:    "ABC123",
+			terms:   []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
+			findErr: pkg.Errorf(pkg.ECONFLICT, "coupon already exists", nil),
+		},
+		{
+			name:        "code too short",
+			code:        "ABC",
+			terms:       []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
+			expectedErr: pkg.ValidationErrorf("invalid coupon code", pkg.FieldError{Field: "code", Message: "minimum length of code is 6 characters"}),
+		},
+		{
+			name:        "code not alphanumeric",
+			code:        "ABCD$12",
+			terms:       []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
+			expectedErr: pkg.ValidationErrorf("invalid coupon code", pkg.FieldError{Field: "code", Message: "code must contain only ASCII letters and numbers"}),
+		},
+		{
+			name:        "code with underscore",
+			code:        "ABCD_12",
+			terms:       []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
+			expectedErr: pkg.ValidationErrorf("invalid coupon code", pkg.FieldError{Field: "code", Message: "code must contain only ASCII letters and numbers"}),
+		},
+		{
+			name:        "code too long",
+			code:        strings.Repeat("A", 33),
+			terms:       []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
+			expectedErr: pkg.ValidationErrorf("invalid coupon code", pkg.FieldError{Field: "code", Message: "maximum length of code is 32 characters"}),
 		},
 		{
-			name:           "code too short",
-			code:           "ABC",
-			discount:       10,
-			minBasketValue: 100,
-			expectedErr:    pkg.Errorf(pkg.EINVALID, "minimum length of code is 6 characters", nil),
+			name:        "code with cyrillic look-alike letters",
+			code:        "SAVE10АBC",
+			terms:       []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
+			expectedErr: pkg.ValidationErrorf("invalid coupon code", pkg.FieldError{Field: "code", Message: "code must contain only ASCII letters and numbers"}),
 		},
 		{
-			name:           "code not alphanumeric",
-			code:           "ABCD$12",
-			discount:       10,
-			minBasketValue: 100,
-			expectedErr:    pkg.Errorf(pkg.EINVALID, "code must contain only number and letters", nil),
+			name:        "reserved code",
+			code:        "admin123",
+			policy:      &pkg.CodePolicy{MinLength: 6, MaxLength: 32, ASCIIOnly: true, Reserved: []string{"ADMIN123"}},
+			terms:       []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
+			expectedErr: pkg.ValidationErrorf("invalid coupon code", pkg.FieldError{Field: "code", Message: "code is reserved"}),
 		},
 		{
-			name:           "code with space",
-			code:           "ABCD 12",
-			discount:       10,
-			minBasketValue: 100,
-			expectedErr:    pkg.Errorf(pkg.EINVALID, "code must contain only number and letters", nil),
+			name:        "code with blocked word",
+			code:        "FREEMONEY10",
+			policy:      &pkg.CodePolicy{MinLength: 6, MaxLength: 32, ASCIIOnly: true, BlockedWords: []string{"money"}},
+			terms:       []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
+			expectedErr: pkg.ValidationErrorf("invalid coupon code", pkg.FieldError{Field: "code", Message: "code contains the blocked word MONEY"}),
 		},
 		{
-			name:           "discount greater than minBasketValue",
-			code:           "ABC123",
-			discount:       200,
-			minBasketValue: 100,
-			expectedErr:    pkg.Errorf(pkg.EINVALID, "discount bigger than minimum basket value", nil),
+			name:    "generated code",
+			code:    "",
+			terms:   []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
+			findErr: pkg.Errorf(pkg.ENOTFOUND, "coupon not found", nil),
 		},
 		{
-			name:           "coupon already exists",
-			code:           "ABC123",
-			discount:       10,
-			minBasketValue: 100,
+			name:        "generated codes all taken",
+			code:        "",
+			terms:       []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
+			findErr:     nil,
+			expectedErr: pkg.Errorf(pkg.ECONFLICT, "could not generate an unused coupon code", nil),
+		},
+		{
+			name:           "code normalized before validation and lookup",
+			code:           " save-10 abc ",
+			normalizedCode: "SAVE10ABC",
+			terms:          []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
+			findErr:        pkg.Errorf(pkg.ENOTFOUND, "coupon not found", nil),
+		},
+		{
+			name:           "code too short once normalized",
+			code:           "AB-C 1",
+			normalizedCode: "ABC1",
+			terms:          []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
+			expectedErr:    pkg.ValidationErrorf("invalid coupon code", pkg.FieldError{Field: "code", Message: "minimum length of code is 6 characters"}),
+		},
+		{
+			name:           "lowercase duplicate of existing code",
+			code:           "save10abc",
+			normalizedCode: "SAVE10ABC",
+			terms:          []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
 			findErr:        nil,
 			expectedErr:    pkg.Errorf(pkg.ECONFLICT, "coupon already exists", nil),
 		},
 		{
-			name:           "save error",
-			code:           "ABC123",
-			discount:       10,
-			minBasketValue: 100,
-			findErr:        pkg.Errorf(pkg.ECONFLICT, "coupon already exists", nil),
-			saveErr:        pkg.Errorf(pkg.EINTERNAL, "save coupon error", errors.New("db error")),
-			expectedErr:    pkg.Errorf(pkg.EINTERNAL, "save coupon error", errors.New("db error")),
+			name:        "discount greater than minBasketValue",
+			code:        "ABC123",
+			terms:       []entity.Terms{{Discount: entity.NewMoney(200, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
+			expectedErr: pkg.Errorf(pkg.EINVALID, "discount bigger than minimum basket value", nil),
+		},
+		{
+			name:  "discount and minBasketValue in different currencies",
+			code:  "ABC123",
+			terms: []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "CZK")}},
+			expectedErr: pkg.Errorf(pkg.EINVALID, "discount and minimum basket value must have the same currency",
+				pkg.Errorf(pkg.EINVALID, "currency mismatch: EUR and CZK", nil)),
+		},
+		{
+			name:   "tiered discount",
+			code:   "ABC123",
+			effect: entity.EffectTieredDiscount,
+			terms: []entity.Terms{{
+				Tiers: []entity.Tier{
+					{MinBasketValue: entity.NewMoney(5000, "EUR"), Discount: entity.NewMoney(500, "EUR")},
+					{MinBasketValue: entity.NewMoney(10000, "EUR"), Discount: entity.NewMoney(1500, "EUR")},
+				},
+			}},
+			findErr: pkg.Errorf(pkg.ENOTFOUND, "coupon not found", nil),
+		},
+		{
+			name:   "tiers not ascending",
+			code:   "ABC123",
+			effect: entity.EffectTieredDiscount,
+			terms: []entity.Terms{{
+				Tiers: []entity.Tier{
+					{MinBasketValue: entity.NewMoney(10000, "EUR"), Discount: entity.NewMoney(1500, "EUR")},
+					{MinBasketValue: entity.NewMoney(5000, "EUR"), Discount: entity.NewMoney(500, "EUR")},
+				},
+			}},
+			expectedErr: pkg.Errorf(pkg.EINVALID, "tiers must have ascending minimum basket values", nil),
+		},
+		{
+			name:   "tier discount bigger than its minimum basket value",
+			code:   "ABC123",
+			effect: entity.EffectTieredDiscount,
+			terms: []entity.Terms{{
+				Tiers: []entity.Tier{
+					{MinBasketValue: entity.NewMoney(5000, "EUR"), Discount: entity.NewMoney(500, "EUR")},
+					{MinBasketValue: entity.NewMoney(10000, "EUR"), Discount: entity.NewMoney(15000, "EUR")},
+				},
+			}},
+			expectedErr: pkg.Errorf(pkg.EINVALID, "discount bigger than minimum basket value", nil),
+		},
+		{
+			name:   "tiers in different currencies",
+			code:   "ABC123",
+			effect: entity.EffectTieredDiscount,
+			terms: []entity.Terms{{
+				Tiers: []entity.Tier{
+					{MinBasketValue: entity.NewMoney(5000, "EUR"), Discount: entity.NewMoney(500, "EUR")},
+					{MinBasketValue: entity.NewMoney(10000, "PLN"), Discount: entity.NewMoney(1500, "PLN")},
+				},
+			}},
+			expectedErr: pkg.Errorf(pkg.EINVALID, "all tiers must have the same currency",
+				pkg.Errorf(pkg.EINVALID, "currency mismatch: PLN and EUR", nil)),
+		},
+		{
+			name:        "tiered discount without tiers",
+			code:        "ABC123",
+			effect:      entity.EffectTieredDiscount,
+			terms:       []entity.Terms{{MinBasketValue: entity.NewMoney(100, "EUR")}},
+			expectedErr: pkg.Errorf(pkg.EINVALID, "tiered_discount coupons require at least one tier", nil),
+		},
+		{
+			name:   "tiers on a fixed discount",
+			code:   "ABC123",
+			effect: entity.EffectFixedDiscount,
+			terms: []entity.Terms{{
+				Discount:       entity.NewMoney(10, "EUR"),
+				MinBasketValue: entity.NewMoney(100, "EUR"),
+				Tiers:          []entity.Tier{{MinBasketValue: entity.NewMoney(100, "EUR"), Discount: entity.NewMoney(10, "EUR")}},
+			}},
+			expectedErr: pkg.Errorf(pkg.EINVALID, "tiers are only allowed for tiered_discount coupons", nil),
+		},
+		{
+			name:        "coupon already exists",
+			code:        "ABC123",
+			terms:       []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
+			findErr:     nil,
+			expectedErr: pkg.Errorf(pkg.ECONFLICT, "coupon already exists", nil),
+		},
+		{
+			name:        "save error",
+			code:        "ABC123",
+			terms:       []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
+			findErr:     pkg.Errorf(pkg.ECONFLICT, "coupon already exists", nil),
+			saveErr:     pkg.Errorf(pkg.EINTERNAL, "save coupon error", errors.New("db error")),
+			expectedErr: pkg.Errorf(pkg.EINTERNAL, "save coupon error", errors.New("db error")),
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			normalizedCode := tt.code
+			if tt.normalizedCode != "" {
+				normalizedCode = tt.normalizedCode
+			}
 			repoMock := &repository.CouponRepositoryMock{
-				FindByCodeFunc: func(code string) (entity.Coupon, error) {
-					assert.Equal(t, tt.code, code)
+				FindByCodeFunc: func(_, code string) (entity.Coupon, error) {
+					if tt.code != "" {
+						assert.Equal(t, normalizedCode, code)
+					}
 					return entity.Coupon{}, tt.findErr
 				},
 				SaveFunc: func(coupon entity.Coupon) error {
 					_, err := uuid.Parse(coupon.ID)
 					assert.NoError(t, err)
-					assert.Equal(t, tt.code, coupon.Code)
-					assert.Equal(t, tt.discount, coupon.Discount)
-					assert.Equal(t, tt.minBasketValue, coupon.MinBasketValue)
+					if tt.code != "" {
+						assert.Equal(t, normalizedCode, coupon.Code)
+					} else {
+						assert.Len(t, coupon.Code, 10)
+						for _, r := range coupon.Code {
+							assert.Contains(t, pkg.GeneratedCodeAlphabet, string(r))
+						}
+					}
+					for i, terms := range coupon.Terms {
+						if len(terms.Tiers) > 0 {
+							assert.Equal(t, tt.terms[i].Tiers, terms.Tiers)
+							assert.Equal(t, terms.Tiers[0].MinBasketValue, terms.MinBasketValue)
+						} else {
+							assert.Equal(t, tt.terms[i], terms)
+						}
+					}
+					if tt.effect != "" {
+						assert.Equal(t, tt.effect, coupon.Effect)
+					} else {
+						assert.Equal(t, entity.EffectFixedDiscount, coupon.Effect)
+					}
 					return tt.saveErr
 				},
 			}
-			svc := New(repoMock)
-			err := svc.CreateCoupon(tt.code, tt.discount, tt.minBasketValue)
+			var opts []Option
+			if tt.policy != nil {
+				opts = append(opts, WithCodePolicy(*tt.policy))
+			}
+			svc := New(repoMock, opts...)
+			coupon, err := svc.CreateCoupon(context.Background(), entity.Coupon{Code: tt.code, Effect: tt.effect, Terms: tt.terms, BuyXGetY: tt.buyXGetY})
 			if tt.expectedErr != nil {
 				assert.Error(t, err)
 				assert.Equal(t, tt.expectedErr.Error(), err.Error())
 			} else {
 				assert.NoError(t, err)
+				assert.NotEmpty(t, coupon.ID)
+				assert.NotEmpty(t, coupon.Code)
+			}
+		})
+	}
+}
+
+func TestService_CreateCoupon_Metadata(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	terms := []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}}
+	tests := []struct {
+		name           string
+		description    string
+		labels         []string
+		expectedLabels []string
+		expectedErr    error
+	}{
+		{
+			name:           "labels are normalized",
+			description:    "Summer sale",
+			labels:         []string{" Summer", "newsletter", "SUMMER"},
+			expectedLabels: []string{"newsletter", "summer"},
+		},
+		{
+			name: "no labels",
+		},
+		{
+			name:        "description too long",
+			description: strings.Repeat("a", maxDescriptionLength+1),
+			expectedErr: pkg.Errorf(pkg.EINVALID, "description cannot be longer than 500 characters", nil),
+		},
+		{
+			name:        "empty label",
+			labels:      []string{"summer", " "},
+			expectedErr: pkg.Errorf(pkg.EINVALID, "labels cannot be empty", nil),
+		},
+		{
+			name:        "label too long",
+			labels:      []string{strings.Repeat("a", maxLabelLength+1)},
+			expectedErr: pkg.Errorf(pkg.EINVALID, "labels cannot be longer than 50 characters", nil),
+		},
+		{
+			name:        "too many labels",
+			labels:      strings.Split("a,b,c,d,e,f,g,h,i,j,k,l,m,n,o,p,q,r,s,t,u", ","),
+			expectedErr: pkg.Errorf(pkg.EINVALID, "coupon cannot have more than 20 labels", nil),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repoMock := &repository.CouponRepositoryMock{
+				FindByCodeFunc: func(string, string) (entity.Coupon, error) {
+					return entity.Coupon{}, pkg.Errorf(pkg.ENOTFOUND, "coupon not found", nil)
+				},
+			}
+			svc := New(repoMock)
+			svc.now = func() time.Time { return now }
+			ctx := identity.NewContext(context.Background(), identity.Principal{UserID: "admin1"})
+
+			coupon, err := svc.CreateCoupon(ctx, entity.Coupon{Code: "SUMMER10", Terms: terms, Description: tt.description, Labels: tt.labels})
+			if tt.expectedErr != nil {
+				assert.Equal(t, tt.expectedErr.Error(), err.Error())
+				assert.Empty(t, repoMock.SaveCalls())
+				return
 			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.description, coupon.Description)
+			assert.Equal(t, tt.expectedLabels, coupon.Labels)
+			assert.Equal(t, now, coupon.CreatedAt)
+			assert.Equal(t, "admin1", coupon.CreatedBy)
+			assert.Equal(t, now, coupon.UpdatedAt)
+			assert.Equal(t, "admin1", coupon.UpdatedBy)
+			assert.Equal(t, coupon, repoMock.SaveCalls()[0].Coupon)
 		})
 	}
 }
 
 func TestService_GetCoupons(t *testing.T) {
 	tests := []struct {
-		name        string
-		codes       []string
-		findCoupons []entity.Coupon
-		findErrs    []error
-		expectedErr error
+		name            string
+		userID          string
+		permissions     []string
+		codes           []string
+		filter          entity.CouponFilter
+		findCoupons     []entity.Coupon
+		findErrs        []error
+		expectedCoupons []entity.Coupon
+		expectedErr     error
 	}{
 		{
 			name:  "all found",
-			codes: []string{"ABC123", "DEF456"},
+			codes: []string{"ABC123", "def-456"},
 			findCoupons: []entity.Coupon{
 				{
-					ID:             "uuid-123",
-					Code:           "ABC123",
-					Discount:       10,
-					MinBasketValue: 100,
+					ID:    "uuid-123",
+					Code:  "ABC123",
+					Terms: []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
 				},
 				{ID: "uuid-456",
-					Code:           "DEF456",
-					Discount:       10,
-					MinBasketValue: 100,
+					Code:  "DEF456",
+					Terms: []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
 				},
 			},
 			findErrs: []error{nil, nil},
 		},
+		{
+			name:   "filtered by labels and creator",
+			codes:  []string{"ABC123", "DEF456", "GHI789"},
+			filter: entity.CouponFilter{Labels: []string{" Summer", "newsletter"}, CreatedBy: "admin1"},
+			findCoupons: []entity.Coupon{
+				{ID: "uuid-123", Code: "ABC123", Labels: []string{"newsletter", "summer"}, CreatedBy: "admin1"},
+				{ID: "uuid-456", Code: "DEF456", Labels: []string{"summer"}, CreatedBy: "admin1"},
+				{ID: "uuid-789", Code: "GHI789", Labels: []string{"newsletter", "summer"}, CreatedBy: "admin2"},
+			},
+			findErrs: []error{nil, nil, nil},
+			expectedCoupons: []entity.Coupon{
+				{ID: "uuid-123", Code: "ABC123", Labels: []string{"newsletter", "summer"}, CreatedBy: "admin1"},
+			},
+		},
+		{
+			name:   "none matching the filter",
+			codes:  []string{"ABC123"},
+			filter: entity.CouponFilter{Labels: []string{"winter"}},
+			findCoupons: []entity.Coupon{
+				{ID: "uuid-123", Code: "ABC123", Labels: []string{"summer"}},
+			},
+			findErrs:        []error{nil},
+			expectedCoupons: []entity.Coupon{},
+		},
 		{
 			name:  "error in the second coupon",
 			codes: []string{"ABC123", ""},
 			findCoupons: []entity.Coupon{
 				{
-					ID:             "uuid-123",
-					Code:           "ABC123",
-					Discount:       10,
-					MinBasketValue: 100,
+					ID:    "uuid-123",
+					Code:  "ABC123",
+					Terms: []entity.Terms{{Discount: entity.NewMoney(10, "EUR"), MinBasketValue: entity.NewMoney(100, "EUR")}},
 				},
 				{},
 			},
@@ -214,27 +905,583 @@ func TestService_GetCoupons(t *testing.T) {
 			},
 			expectedErr: pkg.Errorf(pkg.ENOTFOUND, "coupon not found", nil),
 		},
+		{
+			name:        "own personal coupon",
+			userID:      "alice",
+			codes:       []string{"ABC123"},
+			findCoupons: []entity.Coupon{{ID: "uuid-123", Code: "ABC123", Owner: "alice"}},
+			findErrs:    []error{nil},
+		},
+		{
+			name:        "personal coupon of another user",
+			userID:      "bob",
+			codes:       []string{"ABC123"},
+			findCoupons: []entity.Coupon{{ID: "uuid-123", Code: "ABC123", Owner: "alice"}},
+			findErrs:    []error{nil},
+			expectedErr: pkg.Errorf(pkg.ENOTFOUND, "coupon not found", nil),
+		},
+		{
+			name:        "personal coupon read by a coupon manager",
+			userID:      "admin1",
+			permissions: []string{"coupons:create", "coupons:read"},
+			codes:       []string{"ABC123"},
+			findCoupons: []entity.Coupon{{ID: "uuid-123", Code: "ABC123", Owner: "alice", CreatedBy: "admin1"}},
+			findErrs:    []error{nil},
+		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			call := 0
 			repoMock := &repository.CouponRepositoryMock{
-				FindByCodeFunc: func(code string) (entity.Coupon, error) {
+				FindByCodeFunc: func(_, code string) (entity.Coupon, error) {
 					defer func() { call++ }()
-					assert.Equal(t, tt.codes[call], code)
+					assert.Equal(t, pkg.NormalizeCode(tt.codes[call]), code)
 					return tt.findCoupons[call], tt.findErrs[call]
 				},
 			}
 			svc := New(repoMock)
-			coupons, err := svc.GetCoupons(tt.codes)
+			ctx := identity.NewContext(context.Background(), identity.Principal{UserID: tt.userID, Permissions: tt.permissions})
+			coupons, err := svc.GetCoupons(ctx, tt.codes, tt.filter)
 			if tt.expectedErr != nil {
 				assert.Error(t, err)
 				assert.Equal(t, tt.expectedErr.Error(), err.Error())
 			} else {
 				assert.NoError(t, err)
-				assert.Equal(t, tt.findCoupons, coupons)
+				expected := tt.expectedCoupons
+				if expected == nil {
+					expected = tt.findCoupons
+				}
+				assert.Equal(t, expected, coupons)
 			}
 		})
 	}
 }
+
+func TestService_ApplyCoupon_SignedCodes(t *testing.T) {
+	codec, err := signedcode.New(map[uint8][]byte{1: []byte(strings.Repeat("k", 32))}, 1)
+	assert.NoError(t, err)
+	code, err := codec.Encode(signedcode.Claims{
+		CampaignID: 7,
+		Discount:   entity.NewMoney(500, "EUR"),
+		ExpiresOn:  time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC),
+		Serial:     12,
+	})
+	assert.NoError(t, err)
+	beforeExpiry := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	forged := code[:len(code)-1] + "A"
+	if forged == code {
+		forged = code[:len(code)-1] + "B"
+	}
+
+	tests := []struct {
+		name         string
+		code         string
+		basket       entity.Basket
+		now          time.Time
+		redeemErr    error
+		expectRedeem bool
+		expectedErr  error
+		expectedResp entity.Basket
+	}{
+		{
+			name:         "success",
+			code:         strings.ToLower(code),
+			basket:       entity.Basket{Value: entity.NewMoney(2000, "EUR")},
+			now:          beforeExpiry,
+			expectRedeem: true,
+			expectedResp: entity.Basket{
+				Value:                 entity.NewMoney(2000, "EUR"),
+				AppliedDiscount:       entity.NewMoney(500, "EUR"),
+				ApplicationSuccessful: true,
+			},
+		},
+		{
+			name:         "already redeemed",
+			code:         code,
+			basket:       entity.Basket{Value: entity.NewMoney(2000, "EUR")},
+			now:          beforeExpiry,
+			redeemErr:    pkg.Errorf(pkg.ECONFLICT, "coupon already redeemed", nil),
+			expectRedeem: true,
+			expectedErr:  pkg.Errorf(pkg.ECONFLICT, "coupon already redeemed", nil),
+		},
+		{
+			name:        "expired",
+			code:        code,
+			basket:      entity.Basket{Value: entity.NewMoney(2000, "EUR")},
+			now:         time.Date(2027, 1, 2, 0, 0, 0, 0, time.UTC),
+			expectedErr: pkg.Errorf(pkg.EINVALID, "coupon expired", nil),
+		},
+		{
+			name:        "not redeemed when the basket does not qualify",
+			code:        code,
+			basket:      entity.Basket{Value: entity.NewMoney(400, "EUR")},
+			now:         beforeExpiry,
+			expectedErr: pkg.Errorf(pkg.EINVALID, "basket value below minimum required for coupon", nil),
+		},
+		{
+			name:        "forged code",
+			code:        forged,
+			basket:      entity.Basket{Value: entity.NewMoney(2000, "EUR")},
+			now:         beforeExpiry,
+			expectedErr: pkg.Errorf(pkg.ENOTFOUND, "coupon not found", nil),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			redeemed := false
+			ledgerMock := &repository.RedemptionLedgerMock{
+				RedeemFunc: func(_ string, campaignID, serial uint32) error {
+					redeemed = true
+					assert.Equal(t, uint32(7), campaignID)
+					assert.Equal(t, uint32(12), serial)
+					return tt.redeemErr
+				},
+			}
+			repoMock := &repository.CouponRepositoryMock{
+				FindByCodeFunc: func(_, code string) (entity.Coupon, error) {
+					t.Error("signed codes should not be looked up")
+					return entity.Coupon{}, nil
+				},
+			}
+			svc := New(repoMock, WithSignedCodes(codec, ledgerMock))
+			svc.now = func() time.Time { return tt.now }
+
+			basket, err := svc.ApplyCoupon(context.Background(), tt.code, tt.basket)
+			if tt.expectedErr != nil {
+				assert.Error(t, err)
+				assert.Equal(t, tt.expectedErr.Error(), err.Error())
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedResp, basket)
+			}
+			assert.Equal(t, tt.expectRedeem, redeemed)
+		})
+	}
+}
+
+func TestService_ApplyCoupon_SignedCodes_CheckCharacter(t *testing.T) {
+	codec, err := signedcode.New(map[uint8][]byte{1: []byte(strings.Repeat("k", 32))}, 1)
+	assert.NoError(t, err)
+	// a signed code made of the characters of generated codes, whose last character is not their check character
+	var code string
+	for days := 0; code == "" && days < 1000; days++ {
+		c, err := codec.Encode(signedcode.Claims{
+			CampaignID: 7,
+			Discount:   entity.NewMoney(500, "EUR"),
+			ExpiresOn:  time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC).AddDate(0, 0, days),
+			Serial:     12,
+		})
+		assert.NoError(t, err)
+		if !strings.ContainsAny(c, "IO") && !pkg.ValidCheckCharacter(c) {
+			code = c
+		}
+	}
+	assert.NotEmpty(t, code)
+
+	policy := pkg.DefaultCodePolicy()
+	policy.CheckCharacter = true
+	policy.GeneratedLength = signedcode.Length
+	svc := New(&repository.CouponRepositoryMock{}, WithCodePolicy(policy), WithSignedCodes(codec, &repository.RedemptionLedgerMock{}))
+	svc.now = func() time.Time { return time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC) }
+
+	// signed codes are verified by their signature, not taken for mistyped generated codes
+	basket, err := svc.ApplyCoupon(context.Background(), code, entity.Basket{Value: entity.NewMoney(2000, "EUR")})
+	assert.NoError(t, err)
+	assert.Equal(t, entity.NewMoney(500, "EUR"), basket.AppliedDiscount)
+	coupons, err := svc.GetCoupons(context.Background(), []string{code}, entity.CouponFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, coupons, 1)
+}
+
+func TestService_IssueSignedCodes(t *testing.T) {
+	codec, err := signedcode.New(map[uint8][]byte{1: []byte(strings.Repeat("k", 32))}, 1)
+	assert.NoError(t, err)
+	svc := New(&repository.CouponRepositoryMock{}, WithSignedCodes(codec, &repository.RedemptionLedgerMock{}))
+	svc.now = func() time.Time { return time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC) }
+
+	claims := signedcode.Claims{
+		CampaignID: 7,
+		Discount:   entity.NewMoney(500, "EUR"),
+		ExpiresOn:  time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC),
+		Serial:     100,
+	}
+	codes, err := svc.IssueSignedCodes(context.Background(), claims, 3)
+	assert.NoError(t, err)
+	assert.Len(t, codes, 3)
+	for i, code := range codes {
+		decoded, err := codec.Decode(code, "")
+		assert.NoError(t, err)
+		assert.Equal(t, uint32(100+i), decoded.Serial)
+	}
+
+	coupons, err := svc.GetCoupons(context.Background(), codes[:1], entity.CouponFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, "signed-7-100", coupons[0].ID)
+	assert.Equal(t, []entity.Terms{{Discount: entity.NewMoney(500, "EUR"), MinBasketValue: entity.NewMoney(500, "EUR")}}, coupons[0].Terms)
+
+	claims.Serial = math.MaxUint32
+	_, err = svc.IssueSignedCodes(context.Background(), claims, 2)
+	assert.Equal(t, pkg.Errorf(pkg.EINVALID, "serials out of range", nil).Error(), err.Error())
+
+	claims.Serial = 1
+	claims.ExpiresOn = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err = svc.IssueSignedCodes(context.Background(), claims, 1)
+	assert.Equal(t, pkg.Errorf(pkg.EINVALID, "expiry date is in the past", nil).Error(), err.Error())
+
+	_, err = New(&repository.CouponRepositoryMock{}).IssueSignedCodes(context.Background(), claims, 1)
+	assert.Equal(t, pkg.ENOTIMPLEMENTED, pkg.ErrorCode(err))
+}
+
+func TestService_Tenants(t *testing.T) {
+	codec, err := signedcode.New(map[uint8][]byte{1: []byte(strings.Repeat("k", 32))}, 1)
+	assert.NoError(t, err)
+	svc := New(memdb.NewRepository(), WithSignedCodes(codec, memdb.NewLedger()))
+	svc.now = func() time.Time { return time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC) }
+	acme := identity.NewContext(context.Background(), identity.Principal{UserID: "alice", TenantID: "acme"})
+	globex := identity.NewContext(context.Background(), identity.Principal{UserID: "alice", TenantID: "globex"})
+	terms := []entity.Terms{{Discount: entity.NewMoney(500, "EUR"), MinBasketValue: entity.NewMoney(1000, "EUR")}}
+	basket := entity.Basket{Value: entity.NewMoney(2000, "EUR")}
+
+	coupon, err := svc.CreateCoupon(acme, entity.Coupon{Code: "SUMMER", Terms: terms})
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", coupon.TenantID)
+
+	// the coupons of a tenant are invisible to the others, which may reuse their codes
+	_, err = svc.ApplyCoupon(globex, "SUMMER", basket)
+	assert.Equal(t, pkg.ENOTFOUND, pkg.ErrorCode(err))
+	_, err = svc.GetCoupons(globex, []string{"SUMMER"}, entity.CouponFilter{})
+	assert.Equal(t, pkg.ENOTFOUND, pkg.ErrorCode(err))
+	_, err = svc.CreateCoupon(globex, entity.Coupon{Code: "SUMMER", Terms: terms})
+	assert.NoError(t, err)
+	_, err = svc.CreateCoupon(acme, entity.Coupon{Code: "SUMMER", Terms: terms})
+	assert.Equal(t, pkg.ECONFLICT, pkg.ErrorCode(err))
+	got, err := svc.GetCoupons(acme, []string{"SUMMER"}, entity.CouponFilter{})
+	assert.NoError(t, err)
+	assert.Equal(t, []entity.Coupon{coupon}, got)
+
+	// signed codes are only valid in the tenant they were issued for, which redeems them
+	codes, err := svc.IssueSignedCodes(acme, signedcode.Claims{
+		CampaignID: 7,
+		Discount:   entity.NewMoney(500, "EUR"),
+		ExpiresOn:  time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC),
+	}, 1)
+	assert.NoError(t, err)
+	_, err = svc.ApplyCoupon(globex, codes[0], basket)
+	assert.Equal(t, pkg.ENOTFOUND, pkg.ErrorCode(err))
+	applied, err := svc.ApplyCoupon(acme, codes[0], basket)
+	assert.NoError(t, err)
+	assert.True(t, applied.ApplicationSuccessful)
+}
+
+func TestService_AuditLog(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	codec, err := signedcode.New(map[uint8][]byte{1: []byte(strings.Repeat("k", 32))}, 1)
+	assert.NoError(t, err)
+
+	var events []entity.AuditEvent
+	auditMock := &repository.AuditRepositoryMock{
+		AppendFunc: func(e entity.AuditEvent) error {
+			events = append(events, e)
+			return nil
+		},
+	}
+	repoMock := &repository.CouponRepositoryMock{
+		FindByCodeFunc: func(string, string) (entity.Coupon, error) {
+			return entity.Coupon{}, pkg.Errorf(pkg.ENOTFOUND, "coupon not found", nil)
+		},
+	}
+	svc := New(repoMock, WithSignedCodes(codec, &repository.RedemptionLedgerMock{}), WithAuditLog(auditMock, auditKey))
+	svc.now = func() time.Time { return now }
+
+	ctx := identity.NewContext(context.Background(), identity.Principal{UserID: "admin1", Roles: []string{"admin"}})
+	ctx = identity.WithRequestID(ctx, "req-1")
+
+	coupon, err := svc.CreateCoupon(ctx, entity.Coupon{Code: "summer", Terms: []entity.Terms{{Discount: entity.NewMoney(500, "EUR"), MinBasketValue: entity.NewMoney(1000, "EUR")}}})
+	assert.NoError(t, err)
+	_, err = svc.IssueSignedCodes(ctx, signedcode.Claims{
+		CampaignID: 7,
+		Discount:   entity.NewMoney(500, "EUR"),
+		ExpiresOn:  time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC),
+	}, 2)
+	assert.NoError(t, err)
+
+	assert.Len(t, events, 2)
+	assert.Equal(t, entity.AuditCouponCreated, events[0].Action)
+	assert.Equal(t, "SUMMER", events[0].CouponCode)
+	assert.Equal(t, "admin1", events[0].Actor)
+	assert.Equal(t, "req-1", events[0].RequestID)
+	assert.Equal(t, now, events[0].Time)
+	assert.Nil(t, events[0].Before)
+	assert.Contains(t, string(events[0].After), coupon.ID)
+	assert.Equal(t, entity.AuditSignedCodesIssued, events[1].Action)
+	assert.Contains(t, string(events[1].After), `"Count":2`)
+
+	// failed changes are not recorded
+	_, err = svc.CreateCoupon(ctx, entity.Coupon{Code: "winter"})
+	assert.Error(t, err)
+	assert.Len(t, events, 2)
+
+	auditMock.QueryFunc = func(f entity.AuditFilter) ([]entity.AuditEvent, error) {
+		assert.Equal(t, "SUMMER", f.CouponCode)
+		// callers only query the events of their tenant
+		assert.Equal(t, "", f.TenantID)
+		assert.False(t, f.AnyTenant)
+		return events[:1], nil
+	}
+	got, err := svc.QueryAudit(ctx, entity.AuditFilter{CouponCode: "summer", AnyTenant: true})
+	assert.NoError(t, err)
+	assert.Equal(t, events[:1], got)
+
+	_, err = svc.QueryAudit(ctx, entity.AuditFilter{From: now, To: now.Add(-time.Hour)})
+	assert.Equal(t, pkg.EINVALID, pkg.ErrorCode(err))
+
+	_, err = New(repoMock).QueryAudit(ctx, entity.AuditFilter{})
+	assert.Equal(t, pkg.ENOTIMPLEMENTED, pkg.ErrorCode(err))
+}
+
+func TestService_VerifyAudit(t *testing.T) {
+	first := entity.AuditEvent{ID: "1", Actor: "admin1"}.Chain("", auditKey)
+	second := entity.AuditEvent{ID: "2", Actor: "admin1"}.Chain(first.Hash, auditKey)
+	tampered := second
+	tampered.Actor = "admin2"
+
+	tests := []struct {
+		name   string
+		events []entity.AuditEvent
+		head   string
+		want   entity.AuditVerification
+	}{
+		{name: "valid", events: []entity.AuditEvent{first, second}, head: second.Hash, want: entity.AuditVerification{Valid: true, Checked: 2}},
+		{
+			name:   "tampered",
+			events: []entity.AuditEvent{first, tampered},
+			head:   second.Hash,
+			want:   entity.AuditVerification{Checked: 1, BrokenEventID: "2", Reason: "hash does not match the event content"},
+		},
+		{
+			name:   "truncated",
+			events: []entity.AuditEvent{first},
+			head:   second.Hash,
+			want:   entity.AuditVerification{Checked: 1, BrokenEventID: "1", Reason: "chain ends before its head, the last events were removed"},
+		},
+		{
+			name:   "chained with another key",
+			events: []entity.AuditEvent{entity.AuditEvent{ID: "1", Actor: "admin1"}.Chain("", []byte("another-key"))},
+			want:   entity.AuditVerification{BrokenEventID: "1", Reason: "hash does not match the event content"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auditMock := &repository.AuditRepositoryMock{
+				QueryFunc: func(f entity.AuditFilter) ([]entity.AuditEvent, error) {
+					assert.Equal(t, entity.AuditFilter{TenantID: "acme"}, f)
+					return tt.events, nil
+				},
+				HeadFunc: func(tenantID string) (string, error) {
+					assert.Equal(t, "acme", tenantID)
+					return tt.head, nil
+				},
+			}
+			ctx := identity.NewContext(context.Background(), identity.Principal{UserID: "admin1", TenantID: "acme"})
+			got, err := New(&repository.CouponRepositoryMock{}, WithAuditLog(auditMock, auditKey)).VerifyAudit(ctx)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestService_APIKeys(t *testing.T) {
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	var events []entity.AuditEvent
+	auditMock := &repository.AuditRepositoryMock{
+		AppendFunc: func(e entity.AuditEvent) error {
+			events = append(events, e)
+			return nil
+		},
+	}
+	svc := New(&repository.CouponRepositoryMock{}, WithAPIKeys(memdb.NewAPIKeys()), WithAuditLog(auditMock, auditKey))
+	svc.now = func() time.Time { return now }
+	ctx := identity.NewContext(context.Background(), identity.Principal{UserID: "admin1", Roles: []string{"admin"}})
+
+	invalid := []struct {
+		name    string
+		key     entity.APIKey
+		wantErr string
+	}{
+		{name: "no name", key: entity.APIKey{Name: " ", Scopes: []string{"coupons:apply"}}, wantErr: "name cannot be empty"},
+		{name: "name too long", key: entity.APIKey{Name: strings.Repeat("a", 101), Scopes: []string{"coupons:apply"}}, wantErr: "name cannot be longer than 100 characters"},
+		{name: "no scopes", key: entity.APIKey{Name: "checkout"}, wantErr: "minimum of one scope required"},
+		{name: "expired", key: entity.APIKey{Name: "checkout", Scopes: []string{"coupons:apply"}, ExpiresAt: now}, wantErr: "expiry is in the past"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := svc.CreateAPIKey(ctx, tt.key)
+			assert.Equal(t, pkg.EINVALID, pkg.ErrorCode(err))
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+
+	key, secret, err := svc.CreateAPIKey(ctx, entity.APIKey{Name: " checkout ", Scopes: []string{"coupons:apply"}, ExpiresAt: now.Add(time.Hour)})
+	assert.NoError(t, err)
+	assert.Equal(t, "checkout", key.Name)
+	assert.Equal(t, "admin1", key.CreatedBy)
+	assert.Equal(t, now, key.CreatedAt)
+	assert.True(t, strings.HasPrefix(secret, "cs_"+key.ID+"."))
+	assert.NotContains(t, key.Hash, strings.TrimPrefix(secret, "cs_"+key.ID+"."))
+	assert.Len(t, events, 1)
+	assert.Equal(t, entity.AuditAPIKeyCreated, events[0].Action)
+	assert.NotContains(t, string(events[0].After), key.Hash)
+
+	// the key is authenticated and its use recorded
+	got, err := svc.AuthenticateAPIKey(ctx, secret)
+	assert.NoError(t, err)
+	assert.Equal(t, key.ID, got.ID)
+	assert.Equal(t, []string{"coupons:apply"}, got.Scopes)
+	keys, err := svc.ListAPIKeys(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.Equal(t, now, keys[0].LastUsedAt)
+
+	for _, presented := range []string{"", secret + "x", "cs_" + key.ID, "cs_unknown.secret", strings.TrimPrefix(secret, "cs_")} {
+		_, err := svc.AuthenticateAPIKey(ctx, presented)
+		assert.Equal(t, pkg.EUNAUTHORIZED, pkg.ErrorCode(err), presented)
+		assert.ErrorContains(t, err, "invalid API key")
+	}
+
+	svc.now = func() time.Time { return now.Add(time.Hour) }
+	_, err = svc.AuthenticateAPIKey(ctx, secret)
+	assert.ErrorContains(t, err, "API key has expired")
+	svc.now = func() time.Time { return now }
+
+	// revoked keys are rejected
+	assert.NoError(t, svc.RevokeAPIKey(ctx, key.ID))
+	assert.Equal(t, entity.AuditAPIKeyRevoked, events[1].Action)
+	assert.Nil(t, events[1].After)
+	_, err = svc.AuthenticateAPIKey(ctx, secret)
+	assert.ErrorContains(t, err, "invalid API key")
+	assert.Equal(t, pkg.ENOTFOUND, pkg.ErrorCode(svc.RevokeAPIKey(ctx, key.ID)))
+
+	// the keys of other tenants can be neither listed nor revoked
+	other := identity.NewContext(context.Background(), identity.Principal{UserID: "admin2", Roles: []string{"admin"}, TenantID: "acme"})
+	key, _, err = svc.CreateAPIKey(other, entity.APIKey{Name: "checkout", Scopes: []string{"coupons:apply"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "acme", key.TenantID)
+	keys, err = svc.ListAPIKeys(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+	assert.Equal(t, pkg.ENOTFOUND, pkg.ErrorCode(svc.RevokeAPIKey(ctx, key.ID)))
+	assert.NoError(t, svc.RevokeAPIKey(other, key.ID))
+
+	_, _, err = New(&repository.CouponRepositoryMock{}).CreateAPIKey(ctx, entity.APIKey{Name: "checkout", Scopes: []string{"coupons:apply"}})
+	assert.Equal(t, pkg.ENOTIMPLEMENTED, pkg.ErrorCode(err))
+}
+
+func TestService_AuditFailure(t *testing.T) {
+	failing := false
+	auditMock := &repository.AuditRepositoryMock{
+		AppendFunc: func(entity.AuditEvent) error {
+			if failing {
+				return errors.New("disk full")
+			}
+			return nil
+		},
+	}
+	repoMock := &repository.CouponRepositoryMock{
+		FindByCodeFunc: func(string, string) (entity.Coupon, error) {
+			return entity.Coupon{}, pkg.Errorf(pkg.ENOTFOUND, "coupon not found", nil)
+		},
+	}
+	apiKeys := memdb.NewAPIKeys()
+	revocations := revocation.NewMemoryStore()
+	svc := New(repoMock, WithAPIKeys(apiKeys), WithRevocations(revocations, 24*time.Hour), WithAuditLog(auditMock, auditKey))
+	ctx := identity.NewContext(context.Background(), identity.Principal{UserID: "admin1", Roles: []string{"admin"}})
+	key, _, err := svc.CreateAPIKey(ctx, entity.APIKey{Name: "checkout", Scopes: []string{"coupons:apply"}})
+	assert.NoError(t, err)
+
+	// changes that cannot be recorded are not made
+	failing = true
+	_, err = svc.CreateCoupon(ctx, entity.Coupon{Code: "summer", Terms: []entity.Terms{{Discount: entity.NewMoney(500, "EUR"), MinBasketValue: entity.NewMoney(1000, "EUR")}}})
+	assert.ErrorContains(t, err, "failed to record audit event")
+	assert.Empty(t, repoMock.SaveCalls())
+
+	_, _, err = svc.CreateAPIKey(ctx, entity.APIKey{Name: "backoffice", Scopes: []string{"coupons:read"}})
+	assert.ErrorContains(t, err, "failed to record audit event")
+	assert.ErrorContains(t, svc.RevokeAPIKey(ctx, key.ID), "failed to record audit event")
+	keys, err := svc.ListAPIKeys(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.Equal(t, key.ID, keys[0].ID)
+
+	_, err = svc.RevokeToken(ctx, "token-1", time.Time{})
+	assert.ErrorContains(t, err, "failed to record audit event")
+	revoked, err := revocations.TokenRevoked(ctx, "", "token-1")
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+	_, err = svc.RevokeSubjectTokens(ctx, "user1", time.Time{})
+	assert.ErrorContains(t, err, "failed to record audit event")
+	before, err := revocations.SubjectRevokedBefore(ctx, "", "user1")
+	assert.NoError(t, err)
+	assert.Zero(t, before)
+}
+
+func TestService_RevokeTokens(t *testing.T) {
+	// the memory store checks the expiry of revoked tokens against the wall clock
+	now := time.Now().UTC()
+	var events []entity.AuditEvent
+	auditMock := &repository.AuditRepositoryMock{
+		AppendFunc: func(e entity.AuditEvent) error {
+			events = append(events, e)
+			return nil
+		},
+	}
+	store := revocation.NewMemoryStore()
+	svc := New(&repository.CouponRepositoryMock{}, WithRevocations(store, 24*time.Hour), WithAuditLog(auditMock, auditKey))
+	svc.now = func() time.Time { return now }
+	ctx := identity.NewContext(context.Background(), identity.Principal{UserID: "admin1", Roles: []string{"admin"}, TenantID: "acme"})
+
+	r, err := svc.RevokeToken(ctx, "token-1", now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, entity.TokenRevocation{TokenID: "token-1", ExpiresAt: now.Add(time.Hour)}, r)
+	revoked, err := store.TokenRevoked(ctx, "acme", "token-1")
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+	// the tokens of other tenants are left alone
+	revoked, err = store.TokenRevoked(ctx, "globex", "token-1")
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	// tokens whose expiry is not given are remembered for the longest token lifetime
+	r, err = svc.RevokeToken(ctx, "token-2", time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(24*time.Hour), r.ExpiresAt)
+
+	r, err = svc.RevokeSubjectTokens(ctx, "user1", time.Time{})
+	assert.NoError(t, err)
+	assert.Equal(t, entity.TokenRevocation{Subject: "user1", IssuedBefore: now}, r)
+	before, err := store.SubjectRevokedBefore(ctx, "acme", "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, now, before)
+	before, err = store.SubjectRevokedBefore(ctx, "globex", "user1")
+	assert.NoError(t, err)
+	assert.Zero(t, before)
+
+	assert.Len(t, events, 3)
+	assert.Equal(t, entity.AuditTokenRevoked, events[0].Action)
+	assert.Equal(t, entity.AuditSubjectRevoked, events[2].Action)
+	assert.Equal(t, "admin1", events[2].Actor)
+
+	_, err = svc.RevokeToken(ctx, " ", now.Add(time.Hour))
+	assert.ErrorContains(t, err, "jti cannot be empty")
+	_, err = svc.RevokeToken(ctx, "token-3", now)
+	assert.ErrorContains(t, err, "token has already expired")
+	_, err = svc.RevokeSubjectTokens(ctx, "", now)
+	assert.ErrorContains(t, err, "subject cannot be empty")
+	_, err = svc.RevokeSubjectTokens(ctx, "user1", now.Add(time.Minute))
+	assert.ErrorContains(t, err, "issued_before cannot be in the future")
+	assert.Len(t, events, 3)
+
+	_, err = New(&repository.CouponRepositoryMock{}).RevokeToken(ctx, "token-1", now.Add(time.Hour))
+	assert.Equal(t, pkg.ENOTIMPLEMENTED, pkg.ErrorCode(err))
+}
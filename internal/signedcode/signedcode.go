@@ -0,0 +1,198 @@
+// Package signedcode encodes coupons into self-contained codes signed with a server key,
+// so they can be validated without storing a coupon for each of them.
+//
+// A signed code is Prefix followed by the base32 encoding of its claims and a truncated
+// HMAC-SHA256 of them. The ID of the signing key is part of the claims, which allows rotating
+// keys: new codes are signed with the active key while codes signed with any other configured
+// key stay valid until that key is removed.
+//
+// Codes can be bound to a tenant, whose ID is signed along with the claims without being encoded,
+// so that a code only verifies for the tenant it was issued for.
+package signedcode
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"strconv"
+	"strings"
+	"time"
+
+	"coupon_service/internal/entity"
+	"coupon_service/pkg"
+)
+
+// Prefix starts every signed code.
+const Prefix = "S"
+
+const (
+	claimsSize = 17
+	macSize    = 8
+	// Length is the length of signed codes.
+	Length = len(Prefix) + (claimsSize+macSize)*8/5
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Claims are the parameters encoded in a signed code.
+type Claims struct {
+	// KeyID identifies the key the code is signed with. It is set by Encode.
+	KeyID      uint8
+	CampaignID uint32
+	// Discount is the fixed discount the code grants. Baskets must be worth at least the discount.
+	Discount entity.Money
+	// ExpiresOn is the last day, in UTC, the code can be applied.
+	ExpiresOn time.Time
+	// Serial tells apart the codes of a campaign. Each serial can be redeemed once.
+	Serial uint32
+	// TenantID is the tenant the code is issued for, empty for the default tenant. It is signed but not encoded.
+	TenantID string
+}
+
+// Expired reports whether the code has expired at the given time.
+func (c Claims) Expired(now time.Time) bool {
+	return now.UTC().After(c.ExpiresOn.AddDate(0, 0, 1))
+}
+
+// Codec signs and verifies codes with a set of keys.
+type Codec struct {
+	keys   map[uint8][]byte
+	active uint8
+}
+
+// New creates a Codec verifying codes with keys and signing them with the active key.
+func New(keys map[uint8][]byte, active uint8) (*Codec, error) {
+	if _, ok := keys[active]; !ok {
+		return nil, pkg.Errorf(pkg.EINVALID, "active signing key "+strconv.Itoa(int(active))+" is not configured", nil)
+	}
+	return &Codec{keys: keys, active: active}, nil
+}
+
+// ParseKeys parses signing keys given as comma separated "<key id>:<base64 secret>" pairs,
+// where key IDs range from 0 to 255.
+func ParseKeys(specs []string) (map[uint8][]byte, error) {
+	keys := make(map[uint8][]byte, len(specs))
+	for _, spec := range specs {
+		id, secret, ok := strings.Cut(strings.TrimSpace(spec), ":")
+		if !ok {
+			return nil, pkg.Errorf(pkg.EINVALID, "signing key must be formatted as <key id>:<base64 secret>", nil)
+		}
+		keyID, err := strconv.ParseUint(id, 10, 8)
+		if err != nil {
+			return nil, pkg.Errorf(pkg.EINVALID, "invalid signing key id "+id, err)
+		}
+		key, err := base64.StdEncoding.DecodeString(secret)
+		if err != nil {
+			return nil, pkg.Errorf(pkg.EINVALID, "invalid secret of signing key "+id, err)
+		}
+		if len(key) < 32 {
+			return nil, pkg.Errorf(pkg.EINVALID, "secret of signing key "+id+" must be at least 32 bytes long", nil)
+		}
+		if _, ok := keys[uint8(keyID)]; ok {
+			return nil, pkg.Errorf(pkg.EINVALID, "duplicated signing key id "+id, nil)
+		}
+		keys[uint8(keyID)] = key
+	}
+	return keys, nil
+}
+
+// Looks reports whether code has the shape of a signed code. The code must be normalized.
+func Looks(code string) bool {
+	return len(code) == Length && strings.HasPrefix(code, Prefix)
+}
+
+// Encode returns the code of the claims signed with the active key.
+func (c *Codec) Encode(claims Claims) (string, error) {
+	currency, err := packCurrency(claims.Discount.Currency)
+	if err != nil {
+		return "", err
+	}
+	if claims.Discount.Amount <= 0 || claims.Discount.Amount > 1<<32-1 {
+		return "", pkg.Errorf(pkg.EINVALID, "discount out of range", nil)
+	}
+	days := claims.ExpiresOn.UTC().Unix() / int64(24*time.Hour/time.Second)
+	if days < 0 || days > 1<<16-1 {
+		return "", pkg.Errorf(pkg.EINVALID, "expiry date out of range", nil)
+	}
+
+	buf := make([]byte, claimsSize, claimsSize+macSize)
+	buf[0] = c.active
+	binary.BigEndian.PutUint32(buf[1:], claims.CampaignID)
+	binary.BigEndian.PutUint32(buf[5:], uint32(claims.Discount.Amount))
+	binary.BigEndian.PutUint16(buf[9:], currency)
+	binary.BigEndian.PutUint16(buf[11:], uint16(days))
+	binary.BigEndian.PutUint32(buf[13:], claims.Serial)
+	buf = append(buf, sign(c.keys[c.active], buf, claims.TenantID)...)
+	return Prefix + encoding.EncodeToString(buf), nil
+}
+
+// Decode verifies the signature of a normalized code issued for the tenant and returns its claims. Codes that are
+// malformed, signed with an unknown key, issued for another tenant or whose signature does not match are reported
+// as not found, so they cannot be told apart from unknown codes.
+func (c *Codec) Decode(code, tenantID string) (Claims, error) {
+	notFound := pkg.Errorf(pkg.ENOTFOUND, "coupon not found", nil)
+	if !Looks(code) {
+		return Claims{}, notFound
+	}
+	buf, err := encoding.DecodeString(strings.TrimPrefix(code, Prefix))
+	if err != nil || len(buf) != claimsSize+macSize {
+		return Claims{}, notFound
+	}
+	key, ok := c.keys[buf[0]]
+	if !ok {
+		return Claims{}, notFound
+	}
+	if !hmac.Equal(sign(key, buf[:claimsSize], tenantID), buf[claimsSize:]) {
+		return Claims{}, notFound
+	}
+
+	currency, err := unpackCurrency(binary.BigEndian.Uint16(buf[9:]))
+	if err != nil {
+		return Claims{}, notFound
+	}
+	days := int64(binary.BigEndian.Uint16(buf[11:]))
+	return Claims{
+		KeyID:      buf[0],
+		CampaignID: binary.BigEndian.Uint32(buf[1:]),
+		Discount:   entity.NewMoney(int64(binary.BigEndian.Uint32(buf[5:])), currency),
+		ExpiresOn:  time.Unix(days*int64(24*time.Hour/time.Second), 0).UTC(),
+		Serial:     binary.BigEndian.Uint32(buf[13:]),
+		TenantID:   tenantID,
+	}, nil
+}
+
+// sign returns the truncated MAC of the claims and of the tenant. The claims having a fixed size, the tenant
+// is appended unambiguously, and codes of the default tenant are signed as the claims alone.
+func sign(key, claims []byte, tenantID string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(claims)
+	mac.Write([]byte(tenantID))
+	return mac.Sum(nil)[:macSize]
+}
+
+// packCurrency packs the three letters of an ISO 4217 code in 15 bits.
+func packCurrency(currency string) (uint16, error) {
+	if !pkg.IsCurrencyCode(currency) {
+		return 0, pkg.Errorf(pkg.EINVALID, "currency must be an ISO 4217 code", nil)
+	}
+	var packed uint16
+	for i := 0; i < 3; i++ {
+		packed = packed<<5 | uint16(currency[i]-'A')
+	}
+	return packed, nil
+}
+
+func unpackCurrency(packed uint16) (string, error) {
+	letters := make([]byte, 3)
+	for i := 2; i >= 0; i-- {
+		letter := packed & 0x1f
+		if letter > 'Z'-'A' {
+			return "", pkg.Errorf(pkg.EINVALID, "invalid currency", nil)
+		}
+		letters[i] = 'A' + byte(letter)
+		packed >>= 5
+	}
+	return string(letters), nil
+}
@@ -0,0 +1,168 @@
+package signedcode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"coupon_service/internal/entity"
+	"coupon_service/pkg"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	key1 = bytes.Repeat([]byte{1}, 32)
+	key2 = bytes.Repeat([]byte{2}, 32)
+)
+
+func testClaims() Claims {
+	return Claims{
+		CampaignID: 42,
+		Discount:   entity.NewMoney(500, "EUR"),
+		ExpiresOn:  time.Date(2026, 12, 31, 0, 0, 0, 0, time.UTC),
+		Serial:     1_000_000,
+	}
+}
+
+func TestCodec_EncodeDecode(t *testing.T) {
+	codec, err := New(map[uint8][]byte{1: key1}, 1)
+	assert.NoError(t, err)
+
+	code, err := codec.Encode(testClaims())
+	assert.NoError(t, err)
+	assert.Len(t, code, Length)
+	assert.True(t, Looks(code))
+	assert.Equal(t, code, pkg.NormalizeCode(code))
+
+	claims, err := codec.Decode(code, "")
+	assert.NoError(t, err)
+	want := testClaims()
+	want.KeyID = 1
+	assert.Equal(t, want, claims)
+}
+
+func TestCodec_KeyRotation(t *testing.T) {
+	old, err := New(map[uint8][]byte{1: key1}, 1)
+	assert.NoError(t, err)
+	oldCode, err := old.Encode(testClaims())
+	assert.NoError(t, err)
+
+	rotated, err := New(map[uint8][]byte{1: key1, 2: key2}, 2)
+	assert.NoError(t, err)
+	newCode, err := rotated.Encode(testClaims())
+	assert.NoError(t, err)
+	assert.NotEqual(t, oldCode, newCode)
+
+	// codes of the previous key stay valid while the key is configured
+	claims, err := rotated.Decode(oldCode, "")
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(1), claims.KeyID)
+	claims, err = rotated.Decode(newCode, "")
+	assert.NoError(t, err)
+	assert.Equal(t, uint8(2), claims.KeyID)
+
+	// and are rejected once it is removed
+	retired, err := New(map[uint8][]byte{2: key2}, 2)
+	assert.NoError(t, err)
+	_, err = retired.Decode(oldCode, "")
+	assert.Equal(t, pkg.ENOTFOUND, pkg.ErrorCode(err))
+}
+
+func TestCodec_DecodeTampered(t *testing.T) {
+	codec, err := New(map[uint8][]byte{1: key1}, 1)
+	assert.NoError(t, err)
+	code, err := codec.Encode(testClaims())
+	assert.NoError(t, err)
+
+	for i := len(Prefix); i < len(code); i++ {
+		replacement := byte('A')
+		if code[i] == 'A' {
+			replacement = 'B'
+		}
+		tampered := code[:i] + string(replacement) + code[i+1:]
+		_, err := codec.Decode(tampered, "")
+		assert.Equal(t, pkg.ENOTFOUND, pkg.ErrorCode(err), tampered)
+	}
+
+	other, err := New(map[uint8][]byte{1: key2}, 1)
+	assert.NoError(t, err)
+	_, err = other.Decode(code, "")
+	assert.Equal(t, pkg.ENOTFOUND, pkg.ErrorCode(err))
+
+	_, err = codec.Decode("SAVE10", "")
+	assert.Equal(t, pkg.ENOTFOUND, pkg.ErrorCode(err))
+}
+
+func TestCodec_Tenants(t *testing.T) {
+	codec, err := New(map[uint8][]byte{1: key1}, 1)
+	assert.NoError(t, err)
+	claims := testClaims()
+	claims.TenantID = "brand-a"
+	code, err := codec.Encode(claims)
+	assert.NoError(t, err)
+
+	decoded, err := codec.Decode(code, "brand-a")
+	assert.NoError(t, err)
+	assert.Equal(t, "brand-a", decoded.TenantID)
+
+	// codes only verify for the tenant they were issued for
+	for _, tenant := range []string{"", "brand-b", "brand-", "brand-aa"} {
+		_, err = codec.Decode(code, tenant)
+		assert.Equal(t, pkg.ENOTFOUND, pkg.ErrorCode(err), tenant)
+	}
+	defaultCode, err := codec.Encode(testClaims())
+	assert.NoError(t, err)
+	_, err = codec.Decode(defaultCode, "brand-a")
+	assert.Equal(t, pkg.ENOTFOUND, pkg.ErrorCode(err))
+}
+
+func TestCodec_EncodeInvalid(t *testing.T) {
+	codec, err := New(map[uint8][]byte{1: key1}, 1)
+	assert.NoError(t, err)
+
+	claims := testClaims()
+	claims.Discount = entity.NewMoney(0, "EUR")
+	_, err = codec.Encode(claims)
+	assert.Error(t, err)
+
+	claims = testClaims()
+	claims.Discount = entity.NewMoney(500, "EURO")
+	_, err = codec.Encode(claims)
+	assert.Error(t, err)
+
+	claims = testClaims()
+	claims.ExpiresOn = time.Date(2200, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err = codec.Encode(claims)
+	assert.Error(t, err)
+}
+
+func TestNew_ActiveKeyMissing(t *testing.T) {
+	_, err := New(map[uint8][]byte{1: key1}, 2)
+	assert.Error(t, err)
+}
+
+func TestParseKeys(t *testing.T) {
+	secret := base64.StdEncoding.EncodeToString(key1)
+
+	keys, err := ParseKeys([]string{"1:" + secret, " 7:" + base64.StdEncoding.EncodeToString(key2)})
+	assert.NoError(t, err)
+	assert.Equal(t, map[uint8][]byte{1: key1, 7: key2}, keys)
+
+	for _, specs := range [][]string{
+		{secret},
+		{"256:" + secret},
+		{"1:not base64"},
+		{"1:" + base64.StdEncoding.EncodeToString([]byte("short"))},
+		{"1:" + secret, "1:" + secret},
+	} {
+		_, err := ParseKeys(specs)
+		assert.Error(t, err, specs)
+	}
+}
+
+func TestClaims_Expired(t *testing.T) {
+	claims := testClaims()
+	assert.False(t, claims.Expired(time.Date(2026, 12, 31, 23, 59, 0, 0, time.UTC)))
+	assert.True(t, claims.Expired(time.Date(2027, 1, 1, 0, 0, 1, 0, time.UTC)))
+}
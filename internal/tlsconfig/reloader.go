@@ -0,0 +1,91 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"coupon_service/pkg"
+)
+
+// checkEvery bounds how often the certificate files are checked for changes.
+const checkEvery = 10 * time.Second
+
+// fileVersion identifies the content of a file by its modification time and size.
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// Reloader serves a certificate loaded from a key pair of files, reloaded when they change, e.g. when a renewed
+// certificate is written by cert-manager or certbot. Files are checked on handshakes, at most every checkEvery.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	now      func() time.Time
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	versions  [2]fileVersion
+	checkedAt time.Time
+}
+
+// NewReloader loads the certificate of the key pair of files.
+func NewReloader(certFile, keyFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, now: time.Now}
+	versions, err := r.stat()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(versions); err != nil {
+		return nil, err
+	}
+	r.checkedAt = r.now()
+	return r, nil
+}
+
+// GetCertificate returns the current certificate, to be used as tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now := r.now(); now.Sub(r.checkedAt) >= checkEvery {
+		r.checkedAt = now
+		// the current certificate keeps being served while the files cannot be loaded, e.g. while they are written
+		versions, err := r.stat()
+		if err == nil && versions != r.versions {
+			err = r.load(versions)
+			if err == nil {
+				log.Printf("Reloaded the TLS certificate from %s", r.certFile)
+			}
+		}
+		if err != nil {
+			log.Printf("failed to reload the TLS certificate: %v", err)
+		}
+	}
+	return r.cert, nil
+}
+
+func (r *Reloader) stat() ([2]fileVersion, error) {
+	var versions [2]fileVersion
+	for i, path := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(path)
+		if err != nil {
+			return versions, pkg.Errorf(pkg.EINTERNAL, "failed to read the TLS certificate", err)
+		}
+		versions[i] = fileVersion{modTime: info.ModTime(), size: info.Size()}
+	}
+	return versions, nil
+}
+
+func (r *Reloader) load(versions [2]fileVersion) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return pkg.Errorf(pkg.EINTERNAL, "invalid TLS certificate or key", err)
+	}
+	r.cert = &cert
+	r.versions = versions
+	return nil
+}
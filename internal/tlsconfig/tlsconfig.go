@@ -0,0 +1,119 @@
+// Package tlsconfig builds the TLS configuration of the server: its certificate, reloaded when the files change
+// so that renewed certificates are served without a restart, the accepted protocol versions and cipher suites,
+// and the verification of client certificates for mutual TLS.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"coupon_service/pkg"
+)
+
+// Options configure the TLS of the server.
+type Options struct {
+	// CertFile and KeyFile are the PEM encoded certificate chain and private key of the server.
+	CertFile string
+	KeyFile  string
+	// MinVersion is the lowest accepted TLS version, 1.2 or 1.3.
+	MinVersion string
+	// CipherPolicy selects the cipher suites of TLS 1.2: modern for forward secret AEAD suites only,
+	// compatible for the defaults of Go. The suites of TLS 1.3 are not configurable.
+	CipherPolicy string
+	// ClientCAFile holds the PEM encoded certificates of the authorities issuing client certificates.
+	// Client certificates are not requested when empty.
+	ClientCAFile string
+	// ClientAuth is optional to verify the client certificates presented, or require to reject clients without one.
+	ClientAuth string
+}
+
+// modernCipherSuites are the TLS 1.2 suites with forward secrecy and authenticated encryption.
+var modernCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256,
+}
+
+// New returns the TLS configuration of the server, serving the certificate of the options reloaded on change.
+func New(o Options) (*tls.Config, error) {
+	minVersion, err := ParseMinVersion(o.MinVersion)
+	if err != nil {
+		return nil, err
+	}
+	cipherSuites, err := CipherSuites(o.CipherPolicy)
+	if err != nil {
+		return nil, err
+	}
+	reloader, err := NewReloader(o.CertFile, o.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   cipherSuites,
+		GetCertificate: reloader.GetCertificate,
+	}
+	if o.ClientCAFile == "" {
+		return cfg, nil
+	}
+	if cfg.ClientAuth, err = ParseClientAuth(o.ClientAuth); err != nil {
+		return nil, err
+	}
+	if cfg.ClientCAs, err = loadCertPool(o.ClientCAFile); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// ParseMinVersion returns the TLS version of s, 1.2 when empty. Older versions are not accepted.
+func ParseMinVersion(s string) (uint16, error) {
+	switch s {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+	return 0, pkg.Errorf(pkg.EINTERNAL, fmt.Sprintf("unsupported minimum TLS version %q, expected 1.2 or 1.3", s), nil)
+}
+
+// CipherSuites returns the TLS 1.2 cipher suites of the policy, modern when empty. Nil stands for the defaults of Go.
+func CipherSuites(policy string) ([]uint16, error) {
+	switch strings.ToLower(policy) {
+	case "", "modern":
+		return modernCipherSuites, nil
+	case "compatible":
+		return nil, nil
+	}
+	return nil, pkg.Errorf(pkg.EINTERNAL, fmt.Sprintf("unknown cipher policy %q, expected modern or compatible", policy), nil)
+}
+
+// ParseClientAuth returns how client certificates are verified, optional when empty.
+func ParseClientAuth(s string) (tls.ClientAuthType, error) {
+	switch strings.ToLower(s) {
+	case "", "optional":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	}
+	return tls.NoClientCert, pkg.Errorf(pkg.EINTERNAL, fmt.Sprintf("unknown client authentication %q, expected optional or require", s), nil)
+}
+
+// loadCertPool returns the pool of the PEM encoded certificates of the file.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, pkg.Errorf(pkg.EINTERNAL, "failed to read the client CA file", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, pkg.Errorf(pkg.EINTERNAL, "no certificate found in the client CA file", nil)
+	}
+	return pool, nil
+}
@@ -0,0 +1,176 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// issue returns a certificate of the subject with its key, self-signed when parent is nil.
+func issue(t *testing.T, cn string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn, Organization: []string{"Shop"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		DNSNames:              []string{"localhost"},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert, key
+}
+
+// writeKeyPair writes the PEM encoded certificate and key to cert.pem and key.pem in dir.
+func writeKeyPair(t *testing.T, dir string, cert *x509.Certificate, key *ecdsa.PrivateKey) (string, string) {
+	t.Helper()
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+	return certFile, keyFile
+}
+
+func TestParseOptions(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		want    uint16
+		wantErr bool
+	}{
+		{in: "", want: tls.VersionTLS12},
+		{in: "1.2", want: tls.VersionTLS12},
+		{in: "1.3", want: tls.VersionTLS13},
+		{in: "1.1", wantErr: true},
+	} {
+		got, err := ParseMinVersion(tt.in)
+		assert.Equal(t, tt.wantErr, err != nil, tt.in)
+		assert.Equal(t, tt.want, got, tt.in)
+	}
+
+	suites, err := CipherSuites("modern")
+	assert.NoError(t, err)
+	for _, id := range suites {
+		assert.NotContains(t, tls.CipherSuiteName(id), "CBC")
+	}
+	suites, err = CipherSuites("compatible")
+	assert.NoError(t, err)
+	assert.Nil(t, suites)
+	_, err = CipherSuites("legacy")
+	assert.ErrorContains(t, err, "unknown cipher policy")
+
+	auth, err := ParseClientAuth("require")
+	assert.NoError(t, err)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, auth)
+	auth, err = ParseClientAuth("")
+	assert.NoError(t, err)
+	assert.Equal(t, tls.VerifyClientCertIfGiven, auth)
+	_, err = ParseClientAuth("none")
+	assert.Error(t, err)
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	first, firstKey := issue(t, "first", false, nil, nil)
+	certFile, keyFile := writeKeyPair(t, dir, first, firstKey)
+
+	r, err := NewReloader(certFile, keyFile)
+	require.NoError(t, err)
+	now := time.Now()
+	r.now = func() time.Time { return now }
+	r.checkedAt = now
+
+	served := func() string {
+		cert, err := r.GetCertificate(nil)
+		require.NoError(t, err)
+		return cert.Leaf.Subject.CommonName
+	}
+	assert.Equal(t, "first", served())
+
+	// renewed certificates are picked up once the files are checked again
+	second, secondKey := issue(t, "second", false, nil, nil)
+	writeKeyPair(t, dir, second, secondKey)
+	later := now.Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, later, later))
+	assert.Equal(t, "first", served())
+	now = now.Add(checkEvery)
+	assert.Equal(t, "second", served())
+
+	// the current certificate is kept while the files are invalid
+	require.NoError(t, os.WriteFile(keyFile, []byte("partially written"), 0o600))
+	now = now.Add(checkEvery)
+	assert.Equal(t, "second", served())
+
+	_, err = NewReloader(certFile, keyFile)
+	assert.ErrorContains(t, err, "invalid TLS certificate or key")
+	_, err = NewReloader(filepath.Join(dir, "missing.pem"), keyFile)
+	assert.ErrorContains(t, err, "failed to read the TLS certificate")
+}
+
+func TestNew_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := issue(t, "Shop CA", true, nil, nil)
+	server, serverKey := issue(t, "localhost", false, ca, caKey)
+	client, clientKey := issue(t, "checkout", false, ca, caKey)
+	certFile, keyFile := writeKeyPair(t, dir, server, serverKey)
+	caFile := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0o600))
+
+	cfg, err := New(Options{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.2", ClientCAFile: caFile, ClientAuth: "require"})
+	require.NoError(t, err)
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.TLS.VerifiedChains[0][0].Subject.String()))
+	}))
+	srv.TLS = cfg
+	srv.StartTLS()
+	defer srv.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	get := func(certs ...tls.Certificate) (*http.Response, error) {
+		c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs, ServerName: "localhost"}}}
+		return c.Get(srv.URL)
+	}
+
+	resp, err := get(tls.Certificate{Certificate: [][]byte{client.Raw}, PrivateKey: clientKey})
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "CN=checkout,O=Shop", string(body))
+
+	// clients without a certificate are rejected during the handshake
+	_, err = get()
+	assert.Error(t, err)
+
+	_, err = New(Options{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile + ".missing"})
+	assert.ErrorContains(t, err, "failed to read the client CA file")
+}
@@ -0,0 +1,40 @@
+package pkg
+
+import "strings"
+
+// CheckCharacter returns the Luhn mod N check character of payload over GeneratedCodeAlphabet,
+// which detects any single mistyped character and most transpositions of adjacent characters.
+// It reports false when payload contains characters outside of the alphabet.
+func CheckCharacter(payload string) (byte, bool) {
+	sum, ok := luhnSum(payload, 2)
+	if !ok {
+		return 0, false
+	}
+	n := len(GeneratedCodeAlphabet)
+	return GeneratedCodeAlphabet[(n-sum%n)%n], true
+}
+
+// ValidCheckCharacter reports whether the last character of code is the check character of the rest of it.
+func ValidCheckCharacter(code string) bool {
+	if len(code) < 2 {
+		return false
+	}
+	sum, ok := luhnSum(code, 1)
+	return ok && sum%len(GeneratedCodeAlphabet) == 0
+}
+
+// luhnSum computes the Luhn mod N sum of s from right to left, starting with the given factor.
+func luhnSum(s string, factor int) (int, bool) {
+	n := len(GeneratedCodeAlphabet)
+	sum := 0
+	for i := len(s) - 1; i >= 0; i-- {
+		codePoint := strings.IndexByte(GeneratedCodeAlphabet, s[i])
+		if codePoint < 0 {
+			return 0, false
+		}
+		addend := factor * codePoint
+		sum += addend/n + addend%n
+		factor = 3 - factor
+	}
+	return sum, true
+}
@@ -0,0 +1,25 @@
+package pkg
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+var codeFolder = cases.Fold()
+
+// NormalizeCode returns the canonical form of a coupon code, so that codes differing only
+// in case, Unicode representation (NFKC), whitespace or dashes are treated as the same code.
+// Canonical codes are upper case, e.g. " save-10 abc " becomes "SAVE10ABC".
+func NormalizeCode(code string) string {
+	code = norm.NFKC.String(code)
+	code = strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || unicode.Is(unicode.Pd, r) {
+			return -1
+		}
+		return r
+	}, code)
+	return strings.ToUpper(codeFolder.String(code))
+}
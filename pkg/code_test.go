@@ -0,0 +1,29 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeCode(t *testing.T) {
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{name: "already normalized", code: "SAVE10ABC", want: "SAVE10ABC"},
+		{name: "lower case", code: "save10abc", want: "SAVE10ABC"},
+		{name: "surrounding and inner spaces", code: "  save 10 abc\t", want: "SAVE10ABC"},
+		{name: "dashes", code: "SAVE-10–ABC", want: "SAVE10ABC"},
+		{name: "full width characters", code: "ＳＡＶＥ１０", want: "SAVE10"},
+		{name: "sharp s is case folded", code: "straße", want: "STRASSE"},
+		{name: "empty", code: " - ", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, NormalizeCode(tt.code))
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package pkg
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// GeneratedCodeAlphabet is the alphabet of generated coupon codes.
+// It leaves out characters that are easily confused with each other (0/O, 1/I).
+const GeneratedCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// maxGenerationAttempts bounds how many candidates Generate draws before giving up.
+const maxGenerationAttempts = 100
+
+// DefaultProfanity is the list of words generated codes never contain unless configured otherwise.
+var DefaultProfanity = []string{"ASS", "COCK", "CUNT", "DICK", "FAG", "FUCK", "KKK", "NAZI", "PISS", "SEX", "SHIT", "XXX"}
+
+// leetReplacer maps digits commonly used to disguise letters, so the profanity filter also catches e.g. "5H1T".
+var leetReplacer = strings.NewReplacer("0", "O", "1", "I", "3", "E", "4", "A", "5", "S", "7", "T", "8", "B")
+
+// CodePolicy defines which coupon codes are acceptable and how codes are generated.
+// Codes are expected to be normalized with NormalizeCode before being checked.
+type CodePolicy struct {
+	MinLength int
+	MaxLength int
+	// ASCIIOnly restricts codes to A-Z and 0-9, rejecting look-alike letters of other scripts.
+	ASCIIOnly bool
+	// Reserved codes cannot be used as coupon codes.
+	Reserved []string
+	// BlockedWords cannot appear anywhere in a coupon code.
+	BlockedWords []string
+	// Profanity words never appear in generated codes.
+	Profanity []string
+	// GeneratedLength is the length of generated codes.
+	GeneratedLength int
+	// CheckCharacter makes the last character of generated codes a check character (see CheckCharacter),
+	// so mistyped generated codes are told apart from unknown ones.
+	CheckCharacter bool
+}
+
+// DefaultCodePolicy returns the policy used when none is configured.
+func DefaultCodePolicy() CodePolicy {
+	return CodePolicy{
+		MinLength:       6,
+		MaxLength:       32,
+		ASCIIOnly:       true,
+		Profanity:       DefaultProfanity,
+		GeneratedLength: 10,
+	}
+}
+
+// Validate checks the code against the policy and reports every violation as a field-level error of the given field.
+func (p CodePolicy) Validate(field, code string) error {
+	var fields []FieldError
+	violation := func(msg string) {
+		fields = append(fields, FieldError{Field: field, Message: msg})
+	}
+
+	length := len([]rune(code))
+	if length < p.MinLength {
+		violation(fmt.Sprintf("minimum length of code is %d characters", p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violation(fmt.Sprintf("maximum length of code is %d characters", p.MaxLength))
+	}
+
+	if p.ASCIIOnly {
+		if !IsASCIIAlphaNumericOnly(code) {
+			violation("code must contain only ASCII letters and numbers")
+		}
+	} else if !IsAlphaNumericOnly(code) {
+		violation("code must contain only number and letters")
+	}
+
+	for _, reserved := range p.Reserved {
+		if NormalizeCode(reserved) == code {
+			violation("code is reserved")
+			break
+		}
+	}
+
+	if word, ok := containsWord(code, p.BlockedWords, false); ok {
+		violation("code contains the blocked word " + word)
+	}
+
+	// codes shaped like generated ones must carry a valid check character, otherwise they
+	// would be reported as mistyped when applied
+	if p.CheckCharacter && p.looksGenerated(code) && !ValidCheckCharacter(code) {
+		violation("code looks like a generated code but its check character is invalid")
+	}
+
+	if len(fields) > 0 {
+		return ValidationErrorf("invalid coupon code", fields...)
+	}
+	return nil
+}
+
+// VerifyCheckCharacter returns an EMISTYPED error when check characters are enabled and the code
+// looks like a generated code whose check character does not match, so mistyped codes can be
+// reported without looking them up.
+func (p CodePolicy) VerifyCheckCharacter(code string) error {
+	if p.CheckCharacter && p.looksGenerated(code) && !ValidCheckCharacter(code) {
+		return Errorf(EMISTYPED, "coupon code appears to be mistyped, please check it", nil)
+	}
+	return nil
+}
+
+// looksGenerated reports whether code has the length and alphabet of generated codes.
+func (p CodePolicy) looksGenerated(code string) bool {
+	if len(code) != p.GeneratedLength {
+		return false
+	}
+	for i := 0; i < len(code); i++ {
+		if strings.IndexByte(GeneratedCodeAlphabet, code[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Generate returns a random code of GeneratedLength characters drawn from GeneratedCodeAlphabet
+// that satisfies the policy and contains no profanity. With CheckCharacter set, the last
+// character is the check character of the others.
+func (p CodePolicy) Generate() (string, error) {
+	if p.GeneratedLength <= 0 || (p.CheckCharacter && p.GeneratedLength < 2) {
+		return "", Errorf(EINTERNAL, "generated code length is too short", nil)
+	}
+
+	alphabetSize := big.NewInt(int64(len(GeneratedCodeAlphabet)))
+	code := make([]byte, p.GeneratedLength)
+	random := code
+	if p.CheckCharacter {
+		random = code[:len(code)-1]
+	}
+	for range maxGenerationAttempts {
+		for i := range random {
+			n, err := rand.Int(rand.Reader, alphabetSize)
+			if err != nil {
+				return "", Errorf(EINTERNAL, "failed to generate code", err)
+			}
+			random[i] = GeneratedCodeAlphabet[n.Int64()]
+		}
+		if p.CheckCharacter {
+			code[len(code)-1], _ = CheckCharacter(string(random))
+		}
+
+		if _, profane := containsWord(string(code), p.Profanity, true); profane {
+			continue
+		}
+		if p.Validate("code", string(code)) != nil {
+			continue
+		}
+		return string(code), nil
+	}
+	return "", Errorf(EINTERNAL, "could not generate a code satisfying the code policy", nil)
+}
+
+// containsWord returns the first word found in code. With leet set, digits of the code
+// standing in for letters are also decoded before matching.
+func containsWord(code string, words []string, leet bool) (string, bool) {
+	decoded := code
+	if leet {
+		decoded = leetReplacer.Replace(code)
+	}
+	for _, word := range words {
+		word = NormalizeCode(word)
+		if word == "" {
+			continue
+		}
+		if strings.Contains(code, word) || strings.Contains(decoded, word) {
+			return word, true
+		}
+	}
+	return "", false
+}
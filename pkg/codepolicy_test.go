@@ -0,0 +1,138 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodePolicy_Validate(t *testing.T) {
+	policy := DefaultCodePolicy()
+	policy.Reserved = []string{"admin-123"}
+	policy.BlockedWords = []string{"money"}
+
+	tests := []struct {
+		name    string
+		code    string
+		wantErr error
+	}{
+		{name: "valid", code: "SAVE10ABC"},
+		{
+			name:    "too short",
+			code:    "ABC",
+			wantErr: ValidationErrorf("invalid coupon code", FieldError{Field: "code", Message: "minimum length of code is 6 characters"}),
+		},
+		{
+			name:    "too long",
+			code:    strings.Repeat("A", 33),
+			wantErr: ValidationErrorf("invalid coupon code", FieldError{Field: "code", Message: "maximum length of code is 32 characters"}),
+		},
+		{
+			name:    "cyrillic look-alike",
+			code:    "SAVE10АBC",
+			wantErr: ValidationErrorf("invalid coupon code", FieldError{Field: "code", Message: "code must contain only ASCII letters and numbers"}),
+		},
+		{
+			name:    "reserved",
+			code:    "ADMIN123",
+			wantErr: ValidationErrorf("invalid coupon code", FieldError{Field: "code", Message: "code is reserved"}),
+		},
+		{
+			name:    "blocked word",
+			code:    "FREEMONEY",
+			wantErr: ValidationErrorf("invalid coupon code", FieldError{Field: "code", Message: "code contains the blocked word MONEY"}),
+		},
+		{
+			name: "several violations",
+			code: "$$",
+			wantErr: ValidationErrorf("invalid coupon code",
+				FieldError{Field: "code", Message: "minimum length of code is 6 characters"},
+				FieldError{Field: "code", Message: "code must contain only ASCII letters and numbers"}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := policy.Validate("code", tt.code)
+			if tt.wantErr != nil {
+				assert.Equal(t, tt.wantErr.Error(), err.Error())
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCodePolicy_Generate(t *testing.T) {
+	policy := DefaultCodePolicy()
+	for range 100 {
+		code, err := policy.Generate()
+		assert.NoError(t, err)
+		assert.Len(t, code, policy.GeneratedLength)
+		assert.NoError(t, policy.Validate("code", code))
+		_, profane := containsWord(code, policy.Profanity, true)
+		assert.False(t, profane)
+	}
+
+	_, err := CodePolicy{}.Generate()
+	assert.Error(t, err)
+}
+
+func TestContainsWord(t *testing.T) {
+	_, ok := containsWord("AB5H1TCD", []string{"shit"}, true)
+	assert.True(t, ok)
+	_, ok = containsWord("AB5H1TCD", []string{"shit"}, false)
+	assert.False(t, ok)
+}
+
+func TestCodePolicy_CheckCharacter(t *testing.T) {
+	policy := DefaultCodePolicy()
+	policy.CheckCharacter = true
+
+	for range 100 {
+		code, err := policy.Generate()
+		assert.NoError(t, err)
+		assert.Len(t, code, policy.GeneratedLength)
+		assert.True(t, ValidCheckCharacter(code))
+		assert.NoError(t, policy.VerifyCheckCharacter(code))
+	}
+
+	check, _ := CheckCharacter("K7PQ2MXZ9")
+	code := "K7PQ2MXZ9" + string(check)
+	assert.NoError(t, policy.Validate("code", code))
+
+	mistyped := "K7QP2MXZ9" + string(check)
+	err := policy.VerifyCheckCharacter(mistyped)
+	assert.Equal(t, EMISTYPED, ErrorCode(err))
+	assert.Equal(t,
+		ValidationErrorf("invalid coupon code", FieldError{Field: "code", Message: "code looks like a generated code but its check character is invalid"}).Error(),
+		policy.Validate("code", mistyped).Error())
+
+	// codes not shaped like generated codes are left to the repository
+	assert.NoError(t, policy.VerifyCheckCharacter("SAVE10"))
+	policy.CheckCharacter = false
+	assert.NoError(t, policy.VerifyCheckCharacter(mistyped))
+}
+
+func TestValidCheckCharacter(t *testing.T) {
+	check, ok := CheckCharacter("ABCDEFGHJ")
+	assert.True(t, ok)
+	code := "ABCDEFGHJ" + string(check)
+	assert.True(t, ValidCheckCharacter(code))
+
+	// every single character substitution is detected
+	for i := range code {
+		for j := 0; j < len(GeneratedCodeAlphabet); j++ {
+			if GeneratedCodeAlphabet[j] == code[i] {
+				continue
+			}
+			mistyped := code[:i] + string(GeneratedCodeAlphabet[j]) + code[i+1:]
+			assert.False(t, ValidCheckCharacter(mistyped), mistyped)
+		}
+	}
+
+	_, ok = CheckCharacter("ABC0")
+	assert.False(t, ok)
+	assert.False(t, ValidCheckCharacter("A"))
+}
@@ -0,0 +1,37 @@
+package pkg
+
+import "strings"
+
+// confusableReplacer folds characters commonly mistaken for one another when codes are read from print.
+var confusableReplacer = strings.NewReplacer("O", "0", "I", "1", "L", "1", "S", "5", "B", "8")
+
+// CodeDistance returns the number of edits (insertions, deletions, substitutions and transpositions
+// of adjacent characters) turning code a into code b. Codes are normalized first and characters easily
+// confused with one another, such as O and 0, count as equal.
+func CodeDistance(a, b string) int {
+	ra := []rune(confusableReplacer.Replace(NormalizeCode(a)))
+	rb := []rune(confusableReplacer.Replace(NormalizeCode(b)))
+
+	// optimal string alignment distance, keeping the last three rows of the matrix
+	prev2 := make([]int, len(rb)+1)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				curr[j] = min(curr[j], prev2[j-2]+1)
+			}
+		}
+		prev2, prev, curr = prev, curr, prev2
+	}
+	return prev[len(rb)]
+}
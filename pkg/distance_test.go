@@ -0,0 +1,34 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCodeDistance(t *testing.T) {
+	tests := []struct {
+		name string
+		a    string
+		b    string
+		want int
+	}{
+		{name: "equal", a: "SAVE10", b: "SAVE10", want: 0},
+		{name: "not normalized", a: "save-10", b: "SAVE10", want: 0},
+		{name: "O and 0 confusion", a: "SAVE1O", b: "SAVE10", want: 0},
+		{name: "I and 1 confusion", a: "SAVEI0", b: "SAVE10", want: 0},
+		{name: "substitution", a: "SAVE20", b: "SAVE10", want: 1},
+		{name: "transposition", a: "SAEV10", b: "SAVE10", want: 1},
+		{name: "insertion", a: "SAVE100", b: "SAVE10", want: 1},
+		{name: "deletion", a: "SAV10", b: "SAVE10", want: 1},
+		{name: "several edits", a: "XAVE1099", b: "SAVE10", want: 3},
+		{name: "empty", a: "", b: "SAVE10", want: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, CodeDistance(tt.a, tt.b))
+			assert.Equal(t, tt.want, CodeDistance(tt.b, tt.a))
+		})
+	}
+}
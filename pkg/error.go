@@ -21,6 +21,7 @@ const (
 	ETOOMANYREQUESTS     = "too_many_requests"
 	EFORBIDDEN           = "forbidden"
 	ECANCELED            = "canceled"
+	EMISTYPED            = "mistyped"
 )
 
 // Lookup of application error codes to HTTP status codes.
@@ -35,6 +36,7 @@ var codes = map[string]int{
 	ETOOMANYREQUESTS:     http.StatusTooManyRequests,
 	EFORBIDDEN:           http.StatusForbidden,
 	ECANCELED:            499,
+	EMISTYPED:            http.StatusUnprocessableEntity,
 }
 
 // Error represents a structured application error.
@@ -47,6 +49,18 @@ type Error struct {
 
 	// Human-readable error message.
 	Message string `json:"message"`
+
+	// Optional field-level validation errors.
+	Fields []FieldError `json:"fields,omitempty"`
+
+	// Optional additional information helping the client to recover from the error.
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// FieldError describes why the value of a single input field is invalid.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
 
 // Error() implements the error interface.
@@ -112,6 +126,24 @@ func Errorf(code, msg string, rawErr error) *Error {
 	}
 }
 
+// WithDetail sets an additional detail of the error and returns the error.
+func (e *Error) WithDetail(key string, value any) *Error {
+	if e.Details == nil {
+		e.Details = make(map[string]any)
+	}
+	e.Details[key] = value
+	return e
+}
+
+// ValidationErrorf returns an EINVALID error reporting the given field-level errors.
+func ValidationErrorf(msg string, fields ...FieldError) *Error {
+	return &Error{
+		Code:    EINVALID,
+		Message: msg,
+		Fields:  fields,
+	}
+}
+
 // ErrorStatusCode returns the associated HTTP status code for an arh.WriteError code.
 func ErrorStatusCode(code string) int {
 	if v, ok := codes[code]; ok {
@@ -11,3 +11,26 @@ func IsAlphaNumericOnly(s string) bool {
 	}
 	return true
 }
+
+// IsASCIIAlphaNumericOnly returns true if the input contains only ASCII letters and digits.
+func IsASCIIAlphaNumericOnly(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII || (!unicode.IsLetter(r) && !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
+
+// IsCurrencyCode returns true if the input looks like an ISO 4217 alphabetic code (three upper case letters).
+func IsCurrencyCode(s string) bool {
+	if len(s) != 3 {
+		return false
+	}
+	for _, r := range s {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}